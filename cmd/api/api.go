@@ -3,12 +3,36 @@ package api
 import (
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "github.com/yahyaammar-dev/pacebe/docs"
+	"github.com/yahyaammar-dev/pacebe/services/address"
+	"github.com/yahyaammar-dev/pacebe/services/booking"
+	"github.com/yahyaammar-dev/pacebe/services/cart"
+	"github.com/yahyaammar-dev/pacebe/services/category"
+	"github.com/yahyaammar-dev/pacebe/services/export"
+	"github.com/yahyaammar-dev/pacebe/services/importer"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
+	"github.com/yahyaammar-dev/pacebe/services/invoice"
+	"github.com/yahyaammar-dev/pacebe/services/media"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/services/payment"
+	"github.com/yahyaammar-dev/pacebe/services/price"
+	"github.com/yahyaammar-dev/pacebe/services/product"
+	"github.com/yahyaammar-dev/pacebe/services/professional"
+	"github.com/yahyaammar-dev/pacebe/services/promotion"
+	"github.com/yahyaammar-dev/pacebe/services/returns"
+	"github.com/yahyaammar-dev/pacebe/services/review"
+	"github.com/yahyaammar-dev/pacebe/services/shipping"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/services/tax"
 	"github.com/yahyaammar-dev/pacebe/services/user"
+	"github.com/yahyaammar-dev/pacebe/services/warehouse"
+	"github.com/yahyaammar-dev/pacebe/services/wishlist"
 	"gorm.io/gorm"
 )
 
@@ -34,7 +58,7 @@ func (s *APIServer) Run() error {
 		"HX-Request", "HX-Trigger", "HX-Target", "HX-Current-URL",
 	})
 	originsOk := handlers.AllowedOrigins([]string{"*"})
-	methodsOk := handlers.AllowedMethods([]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodOptions})
+	methodsOk := handlers.AllowedMethods([]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions})
 
 	// swagger
 	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
@@ -43,10 +67,131 @@ func (s *APIServer) Run() error {
 		httpSwagger.DocExpansion("list"),
 	))
 
+	// uploaded files
+	blobStorage, err := storage.New()
+	if err != nil {
+		return err
+	}
+	if local, ok := blobStorage.(*storage.Local); ok && strings.HasPrefix(local.BaseURL(), "/") {
+		router.PathPrefix(local.BaseURL() + "/").Handler(local.Handler())
+	}
+
 	userStore := user.NewStore(s.db)
 	userHandler := user.NewHandler(userStore)
 	userHandler.RegisterRoutes(subRouter)
 
+	currencyStore := money.NewStore(s.db)
+	currencyHandler := money.NewHandler(currencyStore, userStore)
+	currencyHandler.RegisterRoutes(subRouter)
+
+	productStore := product.NewStore(s.db)
+	productHandler := product.NewHandler(productStore, userStore, currencyStore)
+	productHandler.RegisterRoutes(subRouter)
+
+	priceHandler := price.NewHandler(price.NewStore(s.db), productStore, userStore)
+	priceHandler.RegisterRoutes(subRouter)
+
+	reviewStore := review.NewStore(s.db)
+	reviewHandler := review.NewHandler(reviewStore, productStore, userStore)
+	reviewHandler.RegisterRoutes(subRouter)
+
+	categoryStore := category.NewStore(s.db)
+	categoryHandler := category.NewHandler(categoryStore, userStore)
+	categoryHandler.RegisterRoutes(subRouter)
+
+	promotionStore := promotion.NewStore(s.db)
+	promotionHandler := promotion.NewHandler(promotionStore, userStore)
+	promotionHandler.RegisterRoutes(subRouter)
+	taxStore := tax.NewStore(s.db)
+	taxHandler := tax.NewHandler(taxStore, userStore)
+	taxHandler.RegisterRoutes(subRouter)
+	taxProvider, err := tax.New(taxStore)
+	if err != nil {
+		return err
+	}
+
+	shippingStore := shipping.NewStore(s.db)
+
+	pricing := cart.NewPricing(productStore, promotion.NewEngine(promotionStore, categoryStore), tax.NewEngine(taxProvider), shipping.NewEngine(shippingStore))
+
+	addressStore := address.NewStore(s.db)
+	addressHandler := address.NewHandler(addressStore, userStore)
+	addressHandler.RegisterRoutes(subRouter)
+
+	cartStore := cart.NewStore(s.db)
+	cartHandler := cart.NewHandler(cartStore, pricing, addressStore, userStore)
+	cartHandler.RegisterRoutes(subRouter)
+	cartHandler.RegisterListeners()
+
+	orderStore := order.NewStore(s.db)
+	orderHandler := order.NewHandler(orderStore, cartStore, addressStore, pricing, userStore)
+	orderHandler.RegisterRoutes(subRouter)
+
+	tracker := shipping.NewTracker(shippingStore, orderStore, shipping.NewCarriers())
+	tracker.RegisterListeners()
+	shippingHandler := shipping.NewHandler(shippingStore, orderStore, tracker, userStore)
+	shippingHandler.RegisterRoutes(subRouter)
+
+	paymentProvider, err := payment.New()
+	if err != nil {
+		return err
+	}
+	paymentStore := payment.NewStore(s.db)
+	paymentProcessor := payment.NewProcessor(paymentStore, orderStore, paymentProvider)
+	paymentProcessor.RegisterListeners()
+	paymentHandler := payment.NewHandler(paymentStore, orderStore, paymentProcessor, paymentProvider, userStore)
+	paymentHandler.RegisterRoutes(subRouter)
+
+	invoiceStore := invoice.NewStore(s.db)
+	invoiceIssuer := invoice.NewIssuer(invoiceStore, orderStore, userStore, blobStorage, invoice.SellerFromEnv())
+	invoiceIssuer.RegisterListeners()
+	if err := invoiceIssuer.MovePrivate(); err != nil {
+		log.Printf("moving invoice PDFs under the private prefix failed: %v", err)
+	}
+	invoiceHandler := invoice.NewHandler(invoiceStore, orderStore, invoiceIssuer, blobStorage, userStore)
+	invoiceHandler.RegisterRoutes(subRouter)
+
+	returnStore := returns.NewStore(s.db)
+	returnHandler := returns.NewHandler(returnStore, orderStore, returns.NewDesk(returnStore, orderStore, paymentProcessor), userStore)
+	returnHandler.RegisterRoutes(subRouter)
+
+	wishlistHandler := wishlist.NewHandler(wishlist.NewStore(s.db), productStore, userStore)
+	wishlistHandler.RegisterRoutes(subRouter)
+	wishlistHandler.RegisterListeners()
+
+	professionalStore := professional.NewStore(s.db)
+	professionalHandler := professional.NewHandler(professionalStore, userStore)
+	professionalHandler.RegisterRoutes(subRouter)
+
+	bookingHandler := booking.NewHandler(booking.NewStore(s.db), professionalStore, userStore)
+	bookingHandler.RegisterRoutes(subRouter)
+
+	imageStore := media.NewStore(s.db)
+	imageHandler := media.NewHandler(imageStore, productStore, userStore, blobStorage)
+	imageHandler.RegisterRoutes(subRouter)
+
+	exporter := export.NewExporter(s.db, currencyStore)
+	exportStore := export.NewStore(s.db)
+	exportWorker := export.NewWorker(exportStore, exporter, blobStorage, userStore)
+	if err := exportWorker.Start(); err != nil {
+		return err
+	}
+	exportHandler := export.NewHandler(exporter, exportStore, exportWorker, blobStorage, userStore)
+	exportHandler.RegisterRoutes(subRouter)
+
+	inventoryStore := inventory.NewStore(s.db)
+	inventoryHandler := inventory.NewHandler(inventoryStore, userStore)
+	inventoryHandler.RegisterRoutes(subRouter)
+
+	warehouseStore := warehouse.NewStore(s.db)
+	warehouseHandler := warehouse.NewHandler(warehouseStore, userStore)
+	warehouseHandler.RegisterRoutes(subRouter)
+
+	importStore := importer.NewStore(s.db)
+	productImporter := importer.NewImporter(productStore, categoryStore, inventoryStore, warehouseStore, importStore)
+	importHandler := importer.NewHandler(importStore, productImporter, userStore)
+	importHandler.RegisterRoutes(subRouter)
+
 	log.Println("Listening on", s.addr)
 
 	return http.ListenAndServe(s.addr, handlers.CORS(originsOk, headersOk, methodsOk)(router))
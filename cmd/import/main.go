@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yahyaammar-dev/pacebe/db"
+	"github.com/yahyaammar-dev/pacebe/services/category"
+	"github.com/yahyaammar-dev/pacebe/services/importer"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
+	"github.com/yahyaammar-dev/pacebe/services/product"
+	"github.com/yahyaammar-dev/pacebe/services/warehouse"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Imports products from a CSV or XLSX file into the database of the API, e.g.
+//
+//	go run ./cmd/import -file products.xlsx -dry-run
+func main() {
+	file := flag.String("file", "", "CSV or XLSX file with the product export header")
+	dryRun := flag.Bool("dry-run", false, "only validate the rows, nothing is saved")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rows, format, err := importer.ReadRows(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbInstance, err := db.NewSQLiteStorage()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobStore := importer.NewStore(dbInstance)
+	runner := importer.NewImporter(product.NewStore(dbInstance), category.NewStore(dbInstance),
+		inventory.NewStore(dbInstance), warehouse.NewStore(dbInstance), jobStore)
+
+	job := &types.ImportJob{
+		Status:   importer.StatusQueued,
+		DryRun:   *dryRun,
+		FileName: *file,
+		Format:   format,
+	}
+	if err := jobStore.CreateImportJob(job); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := runner.Run(job, rows); err != nil {
+		log.Fatalf("import %d failed: %v", job.ID, err)
+	}
+
+	mode := "imported"
+	if job.DryRun {
+		mode = "validated (dry run)"
+	}
+	fmt.Printf("import %d %s %d rows: %d created, %d updated, %d failed\n",
+		job.ID, mode, job.ProcessedRows, job.Created, job.Updated, job.Failed)
+	for _, rowError := range job.Errors {
+		if rowError.Column != "" {
+			fmt.Printf("row %d, %s: %s\n", rowError.Row, rowError.Column, rowError.Message)
+		} else {
+			fmt.Printf("row %d: %s\n", rowError.Row, rowError.Message)
+		}
+	}
+
+	if job.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,12 +3,20 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/yahyaammar-dev/pacebe/cmd/api"
 	"github.com/yahyaammar-dev/pacebe/configs"
 	"github.com/yahyaammar-dev/pacebe/db"
 	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/importer"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
 	"github.com/yahyaammar-dev/pacebe/services/logger"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/services/price"
+	"github.com/yahyaammar-dev/pacebe/services/shipping"
+	"github.com/yahyaammar-dev/pacebe/services/tax"
 	"github.com/yahyaammar-dev/pacebe/types"
 	"gorm.io/gorm"
 )
@@ -28,7 +36,50 @@ func main() {
 	initStorage(dbInstance)
 
 	// Migrations
-	dbInstance.AutoMigrate(&types.User{}, &types.Role{}, &types.Product{})
+	dbInstance.AutoMigrate(&types.User{}, &types.Role{}, &types.Product{}, &types.Category{}, &types.CategoryAttribute{},
+		&types.ProductOption{}, &types.ProductOptionValue{}, &types.ProductVariant{},
+		&types.ProductPrice{}, &types.ExchangeRate{}, &types.StockMovement{}, &types.StockReservation{},
+		&types.Warehouse{}, &types.StockLevel{}, &types.ProductImage{}, &types.ImportJob{},
+		&types.ExportJob{}, &types.Cart{}, &types.CartItem{},
+		&types.Order{}, &types.OrderItem{}, &types.OrderTransition{},
+		&types.Payment{}, &types.WebhookEvent{}, &types.Refund{},
+		&types.Promotion{}, &types.PromotionRedemption{}, &types.TaxRate{},
+		&types.ShippingZone{}, &types.ShippingMethod{}, &types.Shipment{}, &types.ShipmentUpdate{},
+		&types.Address{}, &types.Invoice{}, &types.InvoiceSequence{},
+		&types.Return{}, &types.ReturnItem{}, &types.ReturnTransition{}, &types.Review{},
+		&types.WishlistItem{}, &types.StockSubscription{}, &types.PriceChange{}, &types.ScheduledPrice{},
+		&types.ProfessionalProfile{}, &types.ProfessionalService{},
+		&types.BookingCalendar{}, &types.AvailabilityRule{}, &types.AvailabilityException{}, &types.Booking{})
+	if err := db.MigrateProductCategories(dbInstance); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateProductPrices(dbInstance, money.BaseCurrency()); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateOpeningStock(dbInstance); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateWarehouses(dbInstance); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := importer.FailInterruptedJobs(dbInstance); err != nil {
+		log.Fatal(err)
+	}
+
+	// exchange rates
+	if rates, err := money.LoadRatesFile(money.RatesFilePath()); err != nil {
+		log.Printf("Exchange rates not loaded: %v", err)
+	} else if err := money.NewStore(dbInstance).SaveExchangeRates(rates); err != nil {
+		log.Fatal(err)
+	}
+
+	// tax rates
+	if rates, err := tax.LoadRatesFile(tax.RatesFilePath()); err != nil {
+		log.Printf("Tax rates not loaded: %v", err)
+	} else if err := tax.NewStore(dbInstance).ReplaceTaxRates(rates); err != nil {
+		log.Fatal(err)
+	}
 
 	// Seeders
 	// seeder := db.NewSeeder(dbInstance)
@@ -42,6 +93,18 @@ func main() {
 	// events and listeners
 	event.NewListener()
 
+	// stock reservations
+	go inventory.ExpireReservationsEvery(inventory.NewStore(dbInstance), time.Minute)
+
+	// unpaid orders
+	go order.CancelPendingOrdersEvery(order.NewStore(dbInstance), time.Minute)
+
+	// scheduled prices
+	go price.ApplyScheduledPricesEvery(price.NewStore(dbInstance), time.Minute)
+
+	// shipment tracking
+	go shipping.TrackShipmentsEvery(shipping.NewTracker(shipping.NewStore(dbInstance), order.NewStore(dbInstance), shipping.NewCarriers()), time.Minute)
+
 	// sockets
 	// socketServer := socket.NewConnection()
 	// go func() {
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"log"
+	"math"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+	"gorm.io/gorm"
+)
+
+// MigrateProductCategories moves the old free text products.category column into the categories table.
+// Spellings that only differ in case, spacing or punctuation end up in the same category.
+func MigrateProductCategories(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&types.Product{}, "category") {
+		return nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var names []string
+		result := tx.Table("products").
+			Distinct("category").
+			Where("category IS NOT NULL AND TRIM(category) <> ''").
+			Pluck("category", &names)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, name := range names {
+			slug := utils.Slugify(name)
+			if slug == "" {
+				continue
+			}
+
+			category := types.Category{
+				Name: strings.Join(strings.Fields(name), " "),
+				Slug: slug,
+			}
+			if err := tx.Where("slug = ?", slug).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+
+			result := tx.Table("products").
+				Where("category = ? AND category_id IS NULL", name).
+				Update("category_id", category.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			log.Printf("DB: moved %d products from %q to category %s", result.RowsAffected, name, slug)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.Migrator().DropColumn(&types.Product{}, "category")
+}
+
+// MigrateOpeningStock books the stock products and variants had before the inventory ledger existed
+// as opening receipts, so the ledger adds up to the current counters
+func MigrateOpeningStock(db *gorm.DB) error {
+	var movements int64
+	if err := db.Model(&types.StockMovement{}).Count(&movements).Error; err != nil {
+		return err
+	}
+	if movements > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var products []types.Product
+		if err := tx.Select("id, stock, reserved").Where("stock > 0").Find(&products).Error; err != nil {
+			return err
+		}
+
+		var variants []types.ProductVariant
+		if err := tx.Select("id, product_id, stock, reserved").Where("stock > 0").Find(&variants).Error; err != nil {
+			return err
+		}
+
+		opening := make([]types.StockMovement, 0, len(products)+len(variants))
+		for _, product := range products {
+			opening = append(opening, types.StockMovement{
+				ProductID: product.ID,
+				Type:      "receipt",
+				Quantity:  product.Stock,
+				Available: product.Stock,
+				Reserved:  product.Reserved,
+				Note:      "opening balance",
+			})
+		}
+		for _, variant := range variants {
+			variantID := variant.ID
+			opening = append(opening, types.StockMovement{
+				ProductID: variant.ProductID,
+				VariantID: &variantID,
+				Type:      "receipt",
+				Quantity:  variant.Stock,
+				Available: variant.Stock,
+				Reserved:  variant.Reserved,
+				Note:      "opening balance",
+			})
+		}
+
+		if len(opening) == 0 {
+			return nil
+		}
+
+		log.Printf("DB: booking %d opening stock balances", len(opening))
+		return tx.CreateInBatches(&opening, 500).Error
+	})
+}
+
+// MigrateWarehouses creates the main warehouse on the first run and moves all stock, movements
+// and reservations that don't belong to a warehouse into it
+func MigrateWarehouses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.Warehouse{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		primary := types.Warehouse{Name: "Main warehouse", Code: "MAIN", Active: true}
+		if err := tx.Create(&primary).Error; err != nil {
+			return err
+		}
+
+		levels := tx.Exec(`INSERT INTO stock_levels (warehouse_id, product_id, variant_id, stock, reserved, updated_at)
+			SELECT ?, id, NULL, stock, reserved, CURRENT_TIMESTAMP FROM products WHERE stock > 0 OR reserved > 0`, primary.ID)
+		if levels.Error != nil {
+			return levels.Error
+		}
+
+		variantLevels := tx.Exec(`INSERT INTO stock_levels (warehouse_id, product_id, variant_id, stock, reserved, updated_at)
+			SELECT ?, product_id, id, stock, reserved, CURRENT_TIMESTAMP FROM product_variants WHERE stock > 0 OR reserved > 0`, primary.ID)
+		if variantLevels.Error != nil {
+			return variantLevels.Error
+		}
+
+		for _, model := range []any{&types.StockMovement{}, &types.StockReservation{}} {
+			result := tx.Model(model).
+				Where("warehouse_id IS NULL OR warehouse_id = 0").
+				Update("warehouse_id", primary.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		log.Printf("DB: moved the stock of %d products and %d variants into warehouse %s",
+			levels.RowsAffected, variantLevels.RowsAffected, primary.Code)
+		return nil
+	})
+}
+
+// MigrateProductPrices moves the old float products.price column into integer minor units of the given currency
+func MigrateProductPrices(db *gorm.DB, currency string) error {
+	if !db.Migrator().HasColumn(&types.Product{}, "price") {
+		return nil
+	}
+
+	result := db.Exec(
+		"UPDATE products SET price_amount = CAST(ROUND(price * ?) AS INTEGER), price_currency = ?",
+		math.Pow10(money.Exponent(currency)), currency,
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+	log.Printf("DB: moved %d product prices to %s minor units", result.RowsAffected, currency)
+
+	return db.Migrator().DropColumn(&types.Product{}, "price")
+}
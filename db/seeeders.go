@@ -8,6 +8,7 @@ import (
 
 	"github.com/yahyaammar-dev/pacebe/services/auth"
 	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
 	"gorm.io/gorm"
 )
 
@@ -88,126 +89,166 @@ func (s *Seeder) AssignRoles() {
 	}
 }
 
+func (s *Seeder) CreateCategories() error {
+	tree := []struct {
+		Name     string
+		Children []string
+	}{
+		{Name: "Electronics", Children: []string{"Audio", "Computers", "Cameras", "Wearables", "Gaming"}},
+		{Name: "Home", Children: []string{"Home Appliances", "Smart Home"}},
+	}
+
+	for i, root := range tree {
+		parent := types.Category{Name: root.Name, Slug: utils.Slugify(root.Name), Position: i}
+		if err := s.db.Where("slug = ?", parent.Slug).FirstOrCreate(&parent).Error; err != nil {
+			return err
+		}
+
+		for j, name := range root.Children {
+			child := types.Category{Name: name, Slug: utils.Slugify(name), ParentID: &parent.ID, Position: j}
+			if err := s.db.Where("slug = ?", child.Slug).FirstOrCreate(&child).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (s *Seeder) CreateProducts() error {
+	if err := s.CreateCategories(); err != nil {
+		return err
+	}
+
+	var rows []types.Category
+	if err := s.db.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	categories := make(map[string]*uint, len(rows))
+	for i := range rows {
+		categories[rows[i].Slug] = &rows[i].ID
+	}
+
 	products := []types.Product{
 		{
 			Name:        "Sony WH-1000XM5",
 			Description: "Noise-cancelling over-ear headphones",
-			Price:       349.99,
+			Price:       types.Money{Amount: 34999, Currency: "USD"},
 			Stock:       75,
-			Category:    "Audio",
+			CategoryID:  categories["audio"],
 			ImageURL:    "https://example.com/sony-headphones.jpg",
 		},
 		{
 			Name:        "Dell XPS 13",
 			Description: "Ultra-thin and lightweight laptop",
-			Price:       1299.99,
+			Price:       types.Money{Amount: 129999, Currency: "USD"},
 			Stock:       40,
-			Category:    "Computers",
+			CategoryID:  categories["computers"],
 			ImageURL:    "https://example.com/dell-xps.jpg",
 		},
 		{
 			Name:        "Google Pixel 8",
 			Description: "Flagship Android phone with great camera",
-			Price:       799.99,
+			Price:       types.Money{Amount: 79999, Currency: "USD"},
 			Stock:       55,
-			Category:    "Electronics",
+			CategoryID:  categories["electronics"],
 			ImageURL:    "https://example.com/pixel8.jpg",
 		},
 		{
 			Name:        "Apple Watch Series 9",
 			Description: "Smartwatch with health and fitness tracking",
-			Price:       399.99,
+			Price:       types.Money{Amount: 39999, Currency: "USD"},
 			Stock:       80,
-			Category:    "Wearables",
+			CategoryID:  categories["wearables"],
 			ImageURL:    "https://example.com/apple-watch.jpg",
 		},
 		{
 			Name:        "Samsung QLED 4K TV",
 			Description: "65-inch 4K QLED Smart TV",
-			Price:       1499.99,
+			Price:       types.Money{Amount: 149999, Currency: "USD"},
 			Stock:       25,
-			Category:    "Home Appliances",
+			CategoryID:  categories["home-appliances"],
 			ImageURL:    "https://example.com/samsung-tv.jpg",
 		},
 		{
 			Name:        "Amazon Echo Dot",
 			Description: "Smart speaker with Alexa",
-			Price:       49.99,
+			Price:       types.Money{Amount: 4999, Currency: "USD"},
 			Stock:       200,
-			Category:    "Smart Home",
+			CategoryID:  categories["smart-home"],
 			ImageURL:    "https://example.com/echo-dot.jpg",
 		},
 		{
 			Name:        "PlayStation 5",
 			Description: "Next-gen gaming console",
-			Price:       499.99,
+			Price:       types.Money{Amount: 49999, Currency: "USD"},
 			Stock:       20,
-			Category:    "Gaming",
+			CategoryID:  categories["gaming"],
 			ImageURL:    "https://example.com/ps5.jpg",
 		},
 		{
 			Name:        "Xbox Series X",
 			Description: "Powerful gaming console",
-			Price:       499.99,
+			Price:       types.Money{Amount: 49999, Currency: "USD"},
 			Stock:       15,
-			Category:    "Gaming",
+			CategoryID:  categories["gaming"],
 			ImageURL:    "https://example.com/xbox-series-x.jpg",
 		},
 		{
 			Name:        "Nintendo Switch",
 			Description: "Hybrid gaming console",
-			Price:       299.99,
+			Price:       types.Money{Amount: 29999, Currency: "USD"},
 			Stock:       50,
-			Category:    "Gaming",
+			CategoryID:  categories["gaming"],
 			ImageURL:    "https://example.com/nintendo-switch.jpg",
 		},
 		{
 			Name:        "Canon EOS R5",
 			Description: "Mirrorless camera with 8K video",
-			Price:       3899.99,
+			Price:       types.Money{Amount: 389999, Currency: "USD"},
 			Stock:       10,
-			Category:    "Cameras",
+			CategoryID:  categories["cameras"],
 			ImageURL:    "https://example.com/canon-r5.jpg",
 		},
 		{
 			Name:        "Bose QuietComfort 45",
 			Description: "Noise-cancelling headphones",
-			Price:       329.99,
+			Price:       types.Money{Amount: 32999, Currency: "USD"},
 			Stock:       60,
-			Category:    "Audio",
+			CategoryID:  categories["audio"],
 			ImageURL:    "https://example.com/bose-qc45.jpg",
 		},
 		{
 			Name:        "LG UltraFine 4K Monitor",
 			Description: "27-inch 4K monitor",
-			Price:       699.99,
+			Price:       types.Money{Amount: 69999, Currency: "USD"},
 			Stock:       35,
-			Category:    "Computers",
+			CategoryID:  categories["computers"],
 			ImageURL:    "https://example.com/lg-monitor.jpg",
 		},
 		{
 			Name:        "Fitbit Charge 5",
 			Description: "Advanced fitness tracker",
-			Price:       179.99,
+			Price:       types.Money{Amount: 17999, Currency: "USD"},
 			Stock:       90,
-			Category:    "Wearables",
+			CategoryID:  categories["wearables"],
 			ImageURL:    "https://example.com/fitbit-charge5.jpg",
 		},
 		{
 			Name:        "Instant Pot Duo",
 			Description: "7-in-1 multi-functional pressure cooker",
-			Price:       99.99,
+			Price:       types.Money{Amount: 9999, Currency: "USD"},
 			Stock:       120,
-			Category:    "Home Appliances",
+			CategoryID:  categories["home-appliances"],
 			ImageURL:    "https://example.com/instant-pot.jpg",
 		},
 		{
 			Name:        "Dyson V11 Vacuum",
 			Description: "Cordless vacuum cleaner",
-			Price:       599.99,
+			Price:       types.Money{Amount: 59999, Currency: "USD"},
 			Stock:       40,
-			Category:    "Home Appliances",
+			CategoryID:  categories["home-appliances"],
 			ImageURL:    "https://example.com/dyson-v11.jpg",
 		},
 	}
@@ -0,0 +1,205 @@
+package address
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.AddressStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.AddressStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/addresses", auth.WithJWTAuth(h.handleGetAddresses, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/addresses", auth.WithJWTAuth(h.handleCreateAddress, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/addresses/{id:[0-9]+}", auth.WithJWTAuth(h.handleGetAddress, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/addresses/{id:[0-9]+}", auth.WithJWTAuth(h.handleUpdateAddress, h.userStore)).Methods("PUT")
+	router.HandleFunc("/me/addresses/{id:[0-9]+}", auth.WithJWTAuth(h.handleDeleteAddress, h.userStore)).Methods("DELETE")
+}
+
+// @Summary My addresses
+// @Description Lists the address book of the logged in user, the default addresses first
+// @Tags Addresses
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.Address
+// @Router /me/addresses [get]
+func (h *Handler) handleGetAddresses(w http.ResponseWriter, r *http.Request) {
+	addresses, err := h.store.GetAddressesByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, addresses)
+}
+
+// @Summary My address
+// @Description Returns an address of the logged in user
+// @Tags Addresses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} types.Address
+// @Failure 404 {object} map[string]string "Address not found"
+// @Router /me/addresses/{id} [get]
+func (h *Handler) handleGetAddress(w http.ResponseWriter, r *http.Request) {
+	address, err := h.myAddress(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, address)
+}
+
+// @Summary Add address
+// @Description Adds an address to the book of the logged in user. Postal codes and regions are checked against
+// @Description the rules of the country, the first address becomes the default for shipping and billing.
+// @Tags Addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param addressPayload body types.AddressPayload true "Address payload"
+// @Success 201 {object} types.Address
+// @Failure 400 {object} map[string]string "Invalid address"
+// @Router /me/addresses [post]
+func (h *Handler) handleCreateAddress(w http.ResponseWriter, r *http.Request) {
+	var payload types.AddressPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	address := types.Address{UserID: auth.GetUserIDFromContext(r.Context())}
+	applyPayload(&address, payload)
+	if err := Check(&address); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreateAddress(&address); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, address)
+}
+
+// @Summary Update address
+// @Description Replaces an address of the logged in user, orders placed with it keep the address they were placed with
+// @Tags Addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Param addressPayload body types.AddressPayload true "Address payload"
+// @Success 200 {object} types.Address
+// @Failure 400 {object} map[string]string "Invalid address"
+// @Failure 404 {object} map[string]string "Address not found"
+// @Router /me/addresses/{id} [put]
+func (h *Handler) handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
+	address, err := h.myAddress(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.AddressPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	applyPayload(address, payload)
+	if err := Check(address); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateAddress(address); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, address)
+}
+
+// @Summary Delete address
+// @Description Deletes an address of the logged in user
+// @Tags Addresses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} map[string]string "Address deleted"
+// @Failure 404 {object} map[string]string "Address not found"
+// @Router /me/addresses/{id} [delete]
+func (h *Handler) handleDeleteAddress(w http.ResponseWriter, r *http.Request) {
+	address, err := h.myAddress(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteAddress(address.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Address deleted"})
+}
+
+// myAddress loads the address of the path, addresses of other users are not found
+func (h *Handler) myAddress(r *http.Request) (*types.Address, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	address, err := h.store.GetAddressByID(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if address.UserID != auth.GetUserIDFromContext(r.Context()) {
+		return nil, fmt.Errorf("address not found")
+	}
+	return address, nil
+}
+
+func applyPayload(address *types.Address, payload types.AddressPayload) {
+	address.Name = payload.Name
+	address.Company = payload.Company
+	address.Line1 = payload.Line1
+	address.Line2 = payload.Line2
+	address.City = payload.City
+	address.Region = payload.Region
+	address.PostalCode = payload.PostalCode
+	address.Country = payload.Country
+	address.Phone = payload.Phone
+	address.DefaultShipping = payload.DefaultShipping
+	address.DefaultBilling = payload.DefaultBilling
+}
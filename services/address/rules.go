@@ -0,0 +1,77 @@
+package address
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// rule is what a country asks of its addresses. Countries without a rule take any postal code or none.
+type rule struct {
+	// postalCode matches the postal codes of the country, they are required when it is set
+	postalCode *regexp.Regexp
+	example    string
+	// region is required, e.g. the state in the US
+	region bool
+}
+
+var rules = map[string]rule{
+	"AT": {postalCode: regexp.MustCompile(`^\d{4}$`), example: "1010"},
+	"AU": {postalCode: regexp.MustCompile(`^\d{4}$`), example: "2000", region: true},
+	"BE": {postalCode: regexp.MustCompile(`^\d{4}$`), example: "1000"},
+	"BR": {postalCode: regexp.MustCompile(`^\d{5}-?\d{3}$`), example: "01310-100", region: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), example: "K1A 0B1", region: true},
+	"CH": {postalCode: regexp.MustCompile(`^\d{4}$`), example: "8001"},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`), example: "10115"},
+	"DK": {postalCode: regexp.MustCompile(`^\d{4}$`), example: "1050"},
+	"ES": {postalCode: regexp.MustCompile(`^\d{5}$`), example: "28001"},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`), example: "75001"},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`), example: "SW1A 1AA"},
+	"IN": {postalCode: regexp.MustCompile(`^\d{6}$`), example: "110001", region: true},
+	"IT": {postalCode: regexp.MustCompile(`^\d{5}$`), example: "00118"},
+	"JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), example: "100-0001", region: true},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`), example: "1012 AB"},
+	"PK": {postalCode: regexp.MustCompile(`^\d{5}$`), example: "54000"},
+	"PL": {postalCode: regexp.MustCompile(`^\d{2}-\d{3}$`), example: "00-001"},
+	"SE": {postalCode: regexp.MustCompile(`^\d{3} ?\d{2}$`), example: "111 22"},
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), example: "10001", region: true},
+}
+
+// Check tidies the address up and tells what it lacks for its country
+func Check(address *types.Address) error {
+	address.Country = strings.ToUpper(address.Country)
+	address.Region = strings.TrimSpace(address.Region)
+	address.PostalCode = strings.ToUpper(strings.TrimSpace(address.PostalCode))
+
+	r, ok := rules[address.Country]
+	if !ok {
+		return nil
+	}
+	if r.region && address.Region == "" {
+		return fmt.Errorf("addresses in %s need a region", address.Country)
+	}
+	if r.postalCode != nil && !r.postalCode.MatchString(address.PostalCode) {
+		return fmt.Errorf("invalid postal code %q for %s, e.g. %s", address.PostalCode, address.Country, r.example)
+	}
+	return nil
+}
+
+// Snapshot copies the address for an order
+func Snapshot(address *types.Address) *types.OrderAddress {
+	if address == nil {
+		return nil
+	}
+	return &types.OrderAddress{
+		Name:       address.Name,
+		Company:    address.Company,
+		Line1:      address.Line1,
+		Line2:      address.Line2,
+		City:       address.City,
+		Region:     address.Region,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+		Phone:      address.Phone,
+	}
+}
@@ -0,0 +1,120 @@
+package address
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetAddressesByUserID lists the address book of the user, the defaults first
+func (s *Store) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	var addresses []types.Address
+	result := s.db.Where("user_id = ?", userID).
+		Order("default_shipping DESC, default_billing DESC, id").
+		Find(&addresses)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return addresses, nil
+}
+
+func (s *Store) GetAddressByID(id uint) (*types.Address, error) {
+	var address types.Address
+	result := s.db.First(&address, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("address not found")
+		}
+		return nil, result.Error
+	}
+	return &address, nil
+}
+
+// GetDefaultAddresses returns the default shipping and billing address of the user, nil for the ones
+// the user didn't set
+func (s *Store) GetDefaultAddresses(userID int) (*types.Address, *types.Address, error) {
+	var addresses []types.Address
+	result := s.db.Where("user_id = ? AND (default_shipping = ? OR default_billing = ?)", userID, true, true).
+		Find(&addresses)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	var shipping, billing *types.Address
+	for i := range addresses {
+		if addresses[i].DefaultShipping {
+			shipping = &addresses[i]
+		}
+		if addresses[i].DefaultBilling {
+			billing = &addresses[i]
+		}
+	}
+	return shipping, billing, nil
+}
+
+// CreateAddress adds the address to the book of its user. The first address of a user becomes the
+// default for shipping and billing.
+func (s *Store) CreateAddress(address *types.Address) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.Address{}).Where("user_id = ?", address.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.DefaultShipping = true
+			address.DefaultBilling = true
+		}
+
+		if err := tx.Create(address).Error; err != nil {
+			return err
+		}
+		return clearDefaults(tx, address)
+	})
+}
+
+func (s *Store) UpdateAddress(address *types.Address) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(address).Error; err != nil {
+			return err
+		}
+		return clearDefaults(tx, address)
+	})
+}
+
+func (s *Store) DeleteAddress(id uint) error {
+	result := s.db.Delete(&types.Address{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("address not found")
+	}
+	return nil
+}
+
+// clearDefaults takes the default flags the address holds off the other addresses of its user
+func clearDefaults(tx *gorm.DB, address *types.Address) error {
+	others := func() *gorm.DB {
+		return tx.Model(&types.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID)
+	}
+	if address.DefaultShipping {
+		if err := others().Update("default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.DefaultBilling {
+		if err := others().Update("default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
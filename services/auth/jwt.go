@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -20,46 +21,82 @@ const UserKey contextKey = "userID"
 
 func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenString := utils.GetTokenFromRequest(r)
-
-		token, err := validateJWT(tokenString)
+		u, err := userFromToken(utils.GetTokenFromRequest(r), store)
 		if err != nil {
-			log.Printf("failed to validate token: %v", err)
+			log.Println(err)
 			permissionDenied(w)
 			return
 		}
 
-		if !token.Valid {
-			log.Println("invalid token")
-			permissionDenied(w)
-			return
-		}
+		// Add the user to the context
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, UserKey, u.ID)
+		r = r.WithContext(ctx)
 
-		claims := token.Claims.(jwt.MapClaims)
-		str := claims["userID"].(string)
+		// Call the function if the token is valid
+		handlerFunc(w, r)
+	}
+}
 
-		userID, err := strconv.Atoi(str)
-		if err != nil {
-			log.Printf("failed to convert userID to int: %v", err)
-			permissionDenied(w)
+// WithOptionalJWTAuth lets guests through without a user in the context, a token that is sent has to be valid
+func WithOptionalJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if utils.GetTokenFromRequest(r) == "" {
+			handlerFunc(w, r)
 			return
 		}
+		WithJWTAuth(handlerFunc, store)(w, r)
+	}
+}
+
+func userFromToken(tokenString string, store types.UserStore) (*types.User, error) {
+	token, err := validateJWT(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate token: %v", err)
+	}
 
-		u, err := store.GetUserByID(userID)
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	str, ok := claims["userID"].(string)
+	if !ok {
+		return nil, fmt.Errorf("token has no userID")
+	}
+
+	userID, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert userID to int: %v", err)
+	}
+
+	u, err := store.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %v", err)
+	}
+	return u, nil
+}
+
+// WithRoles only lets authenticated users through that hold at least one of the given roles
+func WithRoles(handlerFunc http.HandlerFunc, store types.UserStore, roles ...string) http.HandlerFunc {
+	return WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		u, err := store.GetUserByID(GetUserIDFromContext(r.Context()))
 		if err != nil {
 			log.Printf("failed to get user by id: %v", err)
 			permissionDenied(w)
 			return
 		}
 
-		// Add the user to the context
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, UserKey, u.ID)
-		r = r.WithContext(ctx)
+		for _, role := range u.Roles {
+			if slices.Contains(roles, role.Name) {
+				handlerFunc(w, r)
+				return
+			}
+		}
 
-		// Call the function if the token is valid
-		handlerFunc(w, r)
-	}
+		log.Printf("user %d is missing one of the roles %v", u.ID, roles)
+		permissionDenied(w)
+	}, store)
 }
 
 func CreateJWT(secret []byte, userID int) (string, error) {
@@ -0,0 +1,216 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	_ "time/tzdata" // calendars resolve their time zone on hosts without a zoneinfo database
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// ErrPolicy is returned when the calendar of the professional doesn't allow a cancellation or reschedule
+var ErrPolicy = errors.New("not allowed by the booking policy")
+
+// window is a span of open hours
+type window struct {
+	start, end time.Time
+}
+
+// Slots lists the starts from from to to at which a service of duration minutes fits the open hours of
+// the calendar. Starts follow the slot interval from the beginning of the open hours, keep the minimum
+// notice and horizon, and keep the buffer to confirmed bookings on both sides.
+func Slots(calendar *types.BookingCalendar, bookings []types.Booking, duration int, from, to, now time.Time) ([]types.Slot, error) {
+	loc, err := time.LoadLocation(calendar.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	length := time.Duration(duration) * time.Minute
+	buffer := time.Duration(calendar.Buffer) * time.Minute
+	step := time.Duration(calendar.SlotInterval) * time.Minute
+
+	if earliest := now.Add(time.Duration(calendar.MinNotice) * time.Minute); from.Before(earliest) {
+		from = earliest
+	}
+	if latest := now.AddDate(0, 0, calendar.Horizon); to.After(latest) {
+		to = latest
+	}
+
+	slots := []types.Slot{}
+	first := from.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, open := range windows(calendar, day, loc) {
+			for start := open.start; !start.Add(length).After(open.end) && start.Before(to); start = start.Add(step) {
+				if start.Before(from) || busy(bookings, start, start.Add(length), buffer) {
+					continue
+				}
+				slots = append(slots, types.Slot{StartsAt: start, EndsAt: start.Add(length)})
+			}
+		}
+	}
+
+	// available exceptions may overlap, their slots are listed once
+	sort.Slice(slots, func(i, j int) bool { return slots[i].StartsAt.Before(slots[j].StartsAt) })
+	unique := slots[:0]
+	for i, slot := range slots {
+		if i == 0 || !slot.StartsAt.Equal(slots[i-1].StartsAt) {
+			unique = append(unique, slot)
+		}
+	}
+	return unique, nil
+}
+
+// Bookable reports whether startsAt is one of the slots of the calendar
+func Bookable(calendar *types.BookingCalendar, bookings []types.Booking, duration int, startsAt, now time.Time) (bool, error) {
+	slots, err := Slots(calendar, bookings, duration, startsAt, startsAt.Add(time.Nanosecond), now)
+	if err != nil {
+		return false, err
+	}
+	return len(slots) == 1 && slots[0].StartsAt.Equal(startsAt), nil
+}
+
+// CanCancel applies the cancellation policy of the calendar to a customer cancelling now
+func CanCancel(calendar *types.BookingCalendar, booking *types.Booking, now time.Time) error {
+	if err := upcoming(booking, now); err != nil {
+		return err
+	}
+	if now.Add(time.Duration(calendar.CancelNotice) * time.Minute).After(booking.StartsAt) {
+		return fmt.Errorf("%w: bookings can be cancelled up to %s before they start", ErrPolicy, minutes(calendar.CancelNotice))
+	}
+	return nil
+}
+
+// CanReschedule applies the reschedule policy of the calendar to a customer rescheduling now
+func CanReschedule(calendar *types.BookingCalendar, booking *types.Booking, now time.Time) error {
+	if err := upcoming(booking, now); err != nil {
+		return err
+	}
+	if booking.Reschedules >= calendar.MaxReschedules {
+		return fmt.Errorf("%w: a booking can be rescheduled %d times", ErrPolicy, calendar.MaxReschedules)
+	}
+	if now.Add(time.Duration(calendar.RescheduleNotice) * time.Minute).After(booking.StartsAt) {
+		return fmt.Errorf("%w: bookings can be rescheduled up to %s before they start", ErrPolicy, minutes(calendar.RescheduleNotice))
+	}
+	return nil
+}
+
+// upcoming makes sure the booking is confirmed and didn't start yet
+func upcoming(booking *types.Booking, now time.Time) error {
+	if booking.Status != StatusConfirmed {
+		return fmt.Errorf("%w: booking is %s", ErrPolicy, booking.Status)
+	}
+	if !now.Before(booking.StartsAt) {
+		return fmt.Errorf("%w: booking started already", ErrPolicy)
+	}
+	return nil
+}
+
+// windows lists the open hours of a local date. Available exceptions replace the weekly rules of the
+// date, the other exceptions are taken out of what is left.
+func windows(calendar *types.BookingCalendar, day time.Time, loc *time.Location) []window {
+	date := day.Format(dateLayout)
+
+	var open, closed []window
+	replaced := false
+	for _, exception := range calendar.Exceptions {
+		if date < exception.StartDate || date > exception.EndDate {
+			continue
+		}
+		span, whole := hours(day, exception.StartTime, exception.EndTime, loc)
+		if !exception.Available {
+			if whole {
+				return nil
+			}
+			closed = append(closed, span)
+			continue
+		}
+		if !replaced {
+			open, replaced = nil, true
+		}
+		open = append(open, span)
+	}
+
+	if !replaced {
+		for _, rule := range calendar.Rules {
+			if rule.Weekday == int(day.Weekday()) {
+				span, _ := hours(day, rule.StartTime, rule.EndTime, loc)
+				open = append(open, span)
+			}
+		}
+	}
+
+	for _, span := range closed {
+		open = subtract(open, span)
+	}
+	return open
+}
+
+// hours turns two wall clock times of the day into a window, without times it is the whole day
+func hours(day time.Time, start, end string, loc *time.Location) (window, bool) {
+	if start == "" || end == "" {
+		return window{start: day, end: day.AddDate(0, 0, 1)}, true
+	}
+	from, _ := Clock(start)
+	to, _ := Clock(end)
+	return window{start: at(day, from, loc), end: at(day, to, loc)}, false
+}
+
+// subtract takes span out of every open window
+func subtract(open []window, span window) []window {
+	var left []window
+	for _, w := range open {
+		if !span.start.Before(w.end) || !w.start.Before(span.end) {
+			left = append(left, w)
+			continue
+		}
+		if w.start.Before(span.start) {
+			left = append(left, window{start: w.start, end: span.start})
+		}
+		if span.end.Before(w.end) {
+			left = append(left, window{start: span.end, end: w.end})
+		}
+	}
+	return left
+}
+
+// busy reports whether a confirmed booking, widened by the buffer, runs into start to end
+func busy(bookings []types.Booking, start, end time.Time, buffer time.Duration) bool {
+	for _, booking := range bookings {
+		if booking.Status != StatusConfirmed {
+			continue
+		}
+		if start.Before(booking.EndsAt.Add(buffer)) && booking.StartsAt.Before(end.Add(buffer)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clock parses HH:MM into minutes after midnight, 24:00 stands for the end of the day
+func Clock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %s, use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// at is the moment the wall clock in loc shows minutes after the midnight of day, times that don't
+// exist because of a daylight saving change are moved on by the change
+func at(day time.Time, minutes int, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, loc)
+}
+
+func minutes(m int) string {
+	if m%60 == 0 {
+		return fmt.Sprintf("%d hours", m/60)
+	}
+	return fmt.Sprintf("%d minutes", m)
+}
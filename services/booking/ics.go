@@ -0,0 +1,77 @@
+package booking
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Feed renders the bookings of a professional as an iCalendar (RFC 5545) feed. Cancelled bookings stay
+// in the feed with a higher sequence, so subscribed calendar apps take them out.
+func Feed(profile *types.ProfessionalProfile, calendar *types.BookingCalendar, bookings []types.Booking) []byte {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fold(&b, fmt.Sprintf(format, args...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//pacebe//bookings//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:%s", escape(profile.DisplayName+" bookings"))
+	line("X-WR-TIMEZONE:%s", calendar.TimeZone)
+
+	for _, booking := range bookings {
+		sequence, status := booking.Reschedules, "CONFIRMED"
+		if booking.Status == StatusCancelled {
+			sequence, status = sequence+1, "CANCELLED"
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:booking-%d@pacebe", booking.ID)
+		line("DTSTAMP:%s", stamp(booking.UpdatedAt))
+		line("DTSTART:%s", stamp(booking.StartsAt))
+		line("DTEND:%s", stamp(booking.EndsAt))
+		line("SEQUENCE:%d", sequence)
+		line("STATUS:%s", status)
+		line("SUMMARY:%s", escape(booking.ServiceName+" with "+booking.CustomerName))
+		if booking.Note != "" {
+			line("DESCRIPTION:%s", escape(booking.Note))
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape quotes the characters that have a meaning in iCalendar text values
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold writes a content line, lines longer than 75 octets continue on lines starting with a space
+// without splitting a character
+func fold(b *strings.Builder, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,830 @@
+package booking
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/professional"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+// maxSlotDays caps how many days one slot request may cover
+const maxSlotDays = 31
+
+type Handler struct {
+	store             types.BookingStore
+	professionalStore types.ProfessionalStore
+	userStore         types.UserStore
+}
+
+func NewHandler(store types.BookingStore, professionalStore types.ProfessionalStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, professionalStore: professionalStore, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/professionals/{id:[0-9]+}/slots", h.handleGetSlots).Methods("GET")
+	router.HandleFunc("/professionals/{id:[0-9]+}/bookings.ics", h.handleGetFeed).Methods("GET")
+	router.HandleFunc("/professionals/{id:[0-9]+}/bookings", auth.WithJWTAuth(h.handleCreateBooking, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/me/bookings", auth.WithJWTAuth(h.handleGetMyBookings, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/bookings/{id:[0-9]+}", auth.WithJWTAuth(h.handleGetMyBooking, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/bookings/{id:[0-9]+}/cancel", auth.WithJWTAuth(h.handleCancelMyBooking, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/bookings/{id:[0-9]+}/reschedule", auth.WithJWTAuth(h.handleRescheduleMyBooking, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/me/calendar", auth.WithRoles(h.handleGetCalendar, h.userStore, "professional")).Methods("GET")
+	router.HandleFunc("/me/calendar", auth.WithRoles(h.handleUpdateCalendar, h.userStore, "professional")).Methods("PUT")
+	router.HandleFunc("/me/calendar/rules", auth.WithRoles(h.handleReplaceRules, h.userStore, "professional")).Methods("PUT")
+	router.HandleFunc("/me/calendar/exceptions", auth.WithRoles(h.handleCreateException, h.userStore, "professional")).Methods("POST")
+	router.HandleFunc("/me/calendar/exceptions/{exceptionId:[0-9]+}", auth.WithRoles(h.handleDeleteException, h.userStore, "professional")).Methods("DELETE")
+	router.HandleFunc("/me/calendar/feed-key", auth.WithRoles(h.handleRotateFeedKey, h.userStore, "professional")).Methods("POST")
+	router.HandleFunc("/me/professional-bookings", auth.WithRoles(h.handleGetProfessionalBookings, h.userStore, "professional")).Methods("GET")
+	router.HandleFunc("/me/professional-bookings/{id:[0-9]+}/cancel", auth.WithRoles(h.handleCancelProfessionalBooking, h.userStore, "professional")).Methods("POST")
+}
+
+// @Summary Free slots
+// @Description Lists the times a service of an approved professional can be booked at, from and to are
+// @Description dates in tz, both included. Times are shown in tz, the time zone of the professional by default.
+// @Tags Bookings
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Param serviceId query int true "Service ID"
+// @Param from query string false "First date, YYYY-MM-DD, today by default"
+// @Param to query string false "Last date, YYYY-MM-DD, a week after from by default, at most 31 days after it"
+// @Param tz query string false "IANA time zone to read the dates and show the times in, e.g. Europe/Berlin"
+// @Success 200 {object} map[string]interface{} "Time zone and slots"
+// @Failure 400 {object} map[string]string "Invalid dates or time zone"
+// @Failure 404 {object} map[string]string "Professional or service not found"
+// @Router /professionals/{id}/slots [get]
+func (h *Handler) handleGetSlots(w http.ResponseWriter, r *http.Request) {
+	profile, service, err := h.bookableService(r, r.URL.Query().Get("serviceId"))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	calendar, err := h.store.GetCalendar(profile.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	zone := r.URL.Query().Get("tz")
+	if zone == "" {
+		zone = calendar.TimeZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid tz %s", zone))
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.In(loc).Year(), now.In(loc).Month(), now.In(loc).Day(), 0, 0, 0, 0, loc)
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.ParseInLocation(dateLayout, v, loc); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid from, use YYYY-MM-DD"))
+			return
+		}
+	}
+	to := from.AddDate(0, 0, 7)
+	if v := r.URL.Query().Get("to"); v != "" {
+		last, err := time.ParseInLocation(dateLayout, v, loc)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid to, use YYYY-MM-DD"))
+			return
+		}
+		to = last.AddDate(0, 0, 1)
+	}
+	if !to.After(from) || to.After(from.AddDate(0, 0, maxSlotDays)) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("to must be on or after from and at most %d days later", maxSlotDays))
+		return
+	}
+
+	bookings, err := h.store.GetCalendarBookings(profile.ID, from.Add(-24*time.Hour), to.Add(24*time.Hour))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	slots, err := Slots(calendar, bookings, service.Duration, from, to, now)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range slots {
+		slots[i].StartsAt = slots[i].StartsAt.In(loc)
+		slots[i].EndsAt = slots[i].EndsAt.In(loc)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"timeZone": loc.String(), "slots": slots})
+}
+
+// @Summary Book service
+// @Description Books a service of an approved professional as the logged in user, startsAt has to be one of the free slots
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Param bookingPayload body types.BookingPayload true "Service and start"
+// @Success 201 {object} types.Booking
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Professional or service not found"
+// @Failure 409 {object} map[string]string "Time slot is not available"
+// @Router /professionals/{id}/bookings [post]
+func (h *Handler) handleCreateBooking(w http.ResponseWriter, r *http.Request) {
+	var payload types.BookingPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	profile, service, err := h.bookableService(r, strconv.FormatUint(uint64(payload.ServiceID), 10))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	if profile.UserID == userID {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("professionals can't book themselves"))
+		return
+	}
+	user, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	booking := &types.Booking{
+		ProfileID:    profile.ID,
+		ServiceID:    service.ID,
+		UserID:       userID,
+		CustomerName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		ServiceName:  service.Name,
+		Duration:     service.Duration,
+		Price:        service.Price,
+		StartsAt:     payload.StartsAt,
+		Note:         strings.TrimSpace(payload.Note),
+	}
+	if err := h.store.CreateBooking(booking); err != nil {
+		if errors.Is(err, ErrUnavailable) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, booking)
+}
+
+// @Summary My bookings
+// @Description Lists the bookings of the logged in user, latest start first
+// @Tags Bookings
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Status" Enums(confirmed, cancelled)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Bookings and cursors"
+// @Failure 400 {object} map[string]string "Invalid status, cursor or size"
+// @Router /me/bookings [get]
+func (h *Handler) handleGetMyBookings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	filter := types.BookingFilter{UserID: &userID, Status: r.URL.Query().Get("status"), Latest: true}
+
+	bookings, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": bookings, "cursor": page})
+}
+
+// @Summary Get my booking
+// @Description Shows a booking of the logged in user
+// @Tags Bookings
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Booking ID"
+// @Success 200 {object} types.Booking
+// @Failure 404 {object} map[string]string "Booking not found"
+// @Router /me/bookings/{id} [get]
+func (h *Handler) handleGetMyBooking(w http.ResponseWriter, r *http.Request) {
+	booking, err := h.myBooking(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, booking)
+}
+
+// @Summary Cancel my booking
+// @Description Cancels a booking of the logged in user, up to the cancellation notice of the professional before it starts
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Booking ID"
+// @Param bookingCancelPayload body types.BookingCancelPayload false "Reason"
+// @Success 200 {object} types.Booking
+// @Failure 400 {object} map[string]string "Not allowed by the booking policy"
+// @Failure 404 {object} map[string]string "Booking not found"
+// @Failure 409 {object} map[string]string "Booking changed meanwhile"
+// @Router /me/bookings/{id}/cancel [post]
+func (h *Handler) handleCancelMyBooking(w http.ResponseWriter, r *http.Request) {
+	var payload types.BookingCancelPayload
+	if r.ContentLength != 0 && !parsePayload(w, r, &payload) {
+		return
+	}
+
+	booking, err := h.myBooking(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	calendar, err := h.store.GetCalendar(booking.ProfileID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := CanCancel(calendar, booking, time.Now()); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.cancel(w, r, booking, payload.Reason)
+}
+
+// @Summary Reschedule my booking
+// @Description Moves a booking of the logged in user to another free slot, up to the reschedule notice of the
+// @Description professional before it starts and as often as the professional allows
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Booking ID"
+// @Param bookingReschedulePayload body types.BookingReschedulePayload true "New start"
+// @Success 200 {object} types.Booking
+// @Failure 400 {object} map[string]string "Not allowed by the booking policy"
+// @Failure 404 {object} map[string]string "Booking not found"
+// @Failure 409 {object} map[string]string "Time slot is not available or booking changed meanwhile"
+// @Router /me/bookings/{id}/reschedule [post]
+func (h *Handler) handleRescheduleMyBooking(w http.ResponseWriter, r *http.Request) {
+	var payload types.BookingReschedulePayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	booking, err := h.myBooking(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	calendar, err := h.store.GetCalendar(booking.ProfileID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := CanReschedule(calendar, booking, time.Now()); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.RescheduleBooking(booking, payload.StartsAt); err != nil {
+		if errors.Is(err, ErrUnavailable) || errors.Is(err, ErrChanged) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, booking)
+}
+
+// @Summary My calendar
+// @Description Shows the booking calendar of the logged in professional with its rules, exceptions and feed key
+// @Tags Bookings
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} types.BookingCalendar
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/calendar [get]
+func (h *Handler) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, calendar)
+}
+
+// @Summary Update calendar settings
+// @Description Sets the time zone, slot interval, buffer, notices, horizon and reschedule limit of the logged in professional.
+// @Description Horizon is in days, everything else in minutes.
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param bookingCalendarPayload body types.BookingCalendarPayload true "Settings"
+// @Success 200 {object} types.BookingCalendar
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/calendar [put]
+func (h *Handler) handleUpdateCalendar(w http.ResponseWriter, r *http.Request) {
+	var payload types.BookingCalendarPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	calendar.TimeZone = payload.TimeZone
+	calendar.SlotInterval = payload.SlotInterval
+	calendar.Buffer = payload.Buffer
+	calendar.MinNotice = payload.MinNotice
+	calendar.Horizon = payload.Horizon
+	calendar.CancelNotice = payload.CancelNotice
+	calendar.RescheduleNotice = payload.RescheduleNotice
+	calendar.MaxReschedules = payload.MaxReschedules
+	if err := h.store.UpdateCalendar(calendar); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, calendar)
+}
+
+// @Summary Replace weekly availability
+// @Description Replaces the weekly rules of the logged in professional. Times are HH:MM in the time zone of the
+// @Description calendar, 24:00 ends a rule at midnight. Rules of one weekday can't overlap.
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param availabilityRulesPayload body types.AvailabilityRulesPayload true "Rules"
+// @Success 200 {object} types.BookingCalendar
+// @Failure 400 {object} map[string]string "Invalid rules"
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/calendar/rules [put]
+func (h *Handler) handleReplaceRules(w http.ResponseWriter, r *http.Request) {
+	var payload types.AvailabilityRulesPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	rules := make([]types.AvailabilityRule, 0, len(payload.Rules))
+	for _, rule := range payload.Rules {
+		if _, _, err := span(rule.StartTime, rule.EndTime); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		rules = append(rules, types.AvailabilityRule{Weekday: rule.Weekday, StartTime: rule.StartTime, EndTime: rule.EndTime})
+	}
+
+	// times are HH:MM, so they sort and compare as text
+	slices.SortFunc(rules, func(a, b types.AvailabilityRule) int {
+		if a.Weekday != b.Weekday {
+			return a.Weekday - b.Weekday
+		}
+		return strings.Compare(a.StartTime, b.StartTime)
+	})
+	for i := 1; i < len(rules); i++ {
+		if rules[i].Weekday == rules[i-1].Weekday && rules[i].StartTime < rules[i-1].EndTime {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("rules of %s overlap", time.Weekday(rules[i].Weekday)))
+			return
+		}
+	}
+
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.ReplaceRules(calendar.ID, rules); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeCalendar(w, calendar.ProfileID)
+}
+
+// @Summary Add availability exception
+// @Description Changes the days from startDate to endDate of the logged in professional. Available exceptions replace
+// @Description the weekly rules of those days with their hours, the others close their hours or the whole days.
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param availabilityExceptionPayload body types.AvailabilityExceptionPayload true "Exception"
+// @Success 201 {object} types.AvailabilityException
+// @Failure 400 {object} map[string]string "Invalid exception"
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/calendar/exceptions [post]
+func (h *Handler) handleCreateException(w http.ResponseWriter, r *http.Request) {
+	var payload types.AvailabilityExceptionPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	if payload.EndDate == "" {
+		payload.EndDate = payload.StartDate
+	}
+	start, _ := time.Parse(dateLayout, payload.StartDate)
+	end, _ := time.Parse(dateLayout, payload.EndDate)
+	if end.Before(start) || end.After(start.AddDate(1, 0, 0)) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("endDate must be on or after startDate and within a year of it"))
+		return
+	}
+	if payload.StartTime != "" {
+		if _, _, err := span(payload.StartTime, payload.EndTime); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else if payload.Available {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("available exceptions need a startTime and endTime"))
+		return
+	}
+
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	exception := &types.AvailabilityException{
+		CalendarID: calendar.ID,
+		StartDate:  payload.StartDate,
+		EndDate:    payload.EndDate,
+		Available:  payload.Available,
+		StartTime:  payload.StartTime,
+		EndTime:    payload.EndTime,
+		Note:       strings.TrimSpace(payload.Note),
+	}
+	if err := h.store.CreateException(exception); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, exception)
+}
+
+// @Summary Delete availability exception
+// @Description Removes an exception from the calendar of the logged in professional
+// @Tags Bookings
+// @Security BearerAuth
+// @Param exceptionId path int true "Exception ID"
+// @Success 204
+// @Failure 404 {object} map[string]string "Exception not found"
+// @Router /me/calendar/exceptions/{exceptionId} [delete]
+func (h *Handler) handleDeleteException(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "exceptionId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	exception, err := h.store.GetExceptionByID(id)
+	if err != nil || exception.CalendarID != calendar.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("exception not found"))
+		return
+	}
+
+	if err := h.store.DeleteException(exception.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Rotate feed key
+// @Description Replaces the key of the calendar feed of the logged in professional, the old feed address stops working
+// @Tags Bookings
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} types.BookingCalendar
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/calendar/feed-key [post]
+func (h *Handler) handleRotateFeedKey(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := h.myCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := feedKey()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	calendar.FeedKey = key
+	if err := h.store.UpdateCalendar(calendar); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, calendar)
+}
+
+// @Summary Calendar feed
+// @Description iCalendar feed of the bookings of a professional from 30 days ago on, to subscribe to in a calendar app.
+// @Description The key is the feed key of the calendar.
+// @Tags Bookings
+// @Produce text/calendar
+// @Param id path int true "Profile ID"
+// @Param key query string true "Feed key"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string "Feed not found"
+// @Router /professionals/{id}/bookings.ics [get]
+func (h *Handler) handleGetFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.professionalStore.GetProfileByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("feed not found"))
+		return
+	}
+	calendar, err := h.store.GetCalendar(profile.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(calendar.FeedKey)) != 1 {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("feed not found"))
+		return
+	}
+
+	now := time.Now()
+	bookings, err := h.store.GetCalendarBookings(profile.ID, now.AddDate(0, 0, -30), now.AddDate(1, 0, 0))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="bookings.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(Feed(profile, calendar, bookings))
+}
+
+// @Summary Bookings with me
+// @Description Lists the bookings of the logged in professional by start, from now on unless from is given
+// @Tags Bookings
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Bookings ending after, RFC 3339"
+// @Param to query string false "Bookings starting before, RFC 3339"
+// @Param status query string false "Status" Enums(confirmed, cancelled)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Bookings and cursors"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Failure 404 {object} map[string]string "Professional profile not found"
+// @Router /me/professional-bookings [get]
+func (h *Handler) handleGetProfessionalBookings(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.professionalStore.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	now := time.Now()
+	filter := types.BookingFilter{ProfileID: profile.ID, Status: r.URL.Query().Get("status"), From: &now}
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid from, use RFC 3339"))
+			return
+		}
+		filter.From = &from
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid to, use RFC 3339"))
+			return
+		}
+		filter.To = &to
+	}
+
+	bookings, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": bookings, "cursor": page})
+}
+
+// @Summary Cancel booking with me
+// @Description Cancels a booking with the logged in professional that didn't start yet, the cancellation notice only binds customers
+// @Tags Bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Booking ID"
+// @Param bookingCancelPayload body types.BookingCancelPayload false "Reason"
+// @Success 200 {object} types.Booking
+// @Failure 400 {object} map[string]string "Booking is cancelled or started already"
+// @Failure 404 {object} map[string]string "Booking not found"
+// @Failure 409 {object} map[string]string "Booking changed meanwhile"
+// @Router /me/professional-bookings/{id}/cancel [post]
+func (h *Handler) handleCancelProfessionalBooking(w http.ResponseWriter, r *http.Request) {
+	var payload types.BookingCancelPayload
+	if r.ContentLength != 0 && !parsePayload(w, r, &payload) {
+		return
+	}
+
+	id, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.professionalStore.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	booking, err := h.store.GetBookingByID(id)
+	if err != nil || booking.ProfileID != profile.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("booking not found"))
+		return
+	}
+	if err := upcoming(booking, time.Now()); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.cancel(w, r, booking, payload.Reason)
+}
+
+func (h *Handler) cancel(w http.ResponseWriter, r *http.Request, booking *types.Booking, reason string) {
+	if err := h.store.CancelBooking(booking, strings.TrimSpace(reason), auth.GetUserIDFromContext(r.Context())); err != nil {
+		if errors.Is(err, ErrChanged) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, booking)
+}
+
+// list loads a page of bookings, false means the error was written
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, filter types.BookingFilter) ([]types.Booking, *types.CursorPage, bool) {
+	if filter.Status != "" && filter.Status != StatusConfirmed && filter.Status != StatusCancelled {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be %s or %s", StatusConfirmed, StatusCancelled))
+		return nil, nil, false
+	}
+
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return nil, nil, false
+		}
+		size = n
+	}
+
+	bookings, page, err := h.store.GetBookings(filter, r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return nil, nil, false
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	return bookings, page, true
+}
+
+// bookableService loads the approved professional of the path and one of their active services
+func (h *Handler) bookableService(r *http.Request, serviceID string) (*types.ProfessionalProfile, *types.ProfessionalService, error) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	profile, err := h.professionalStore.GetProfileByID(id)
+	if err != nil || profile.Status != professional.StatusApproved {
+		return nil, nil, fmt.Errorf("professional profile not found")
+	}
+
+	for _, service := range profile.Services {
+		if strconv.FormatUint(uint64(service.ID), 10) == serviceID && service.Active {
+			return profile, &service, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("service not found")
+}
+
+// myBooking loads the booking of the path, bookings of other users are reported as not found
+func (h *Handler) myBooking(r *http.Request) (*types.Booking, error) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := h.store.GetBookingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if booking.UserID != auth.GetUserIDFromContext(r.Context()) {
+		return nil, fmt.Errorf("booking not found")
+	}
+	return booking, nil
+}
+
+// myCalendar loads the calendar of the logged in professional, false means the error was written
+func (h *Handler) myCalendar(w http.ResponseWriter, r *http.Request) (*types.BookingCalendar, bool) {
+	profile, err := h.professionalStore.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	calendar, err := h.store.GetCalendar(profile.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return calendar, true
+}
+
+func (h *Handler) writeCalendar(w http.ResponseWriter, profileID uint) {
+	calendar, err := h.store.GetCalendar(profileID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, calendar)
+}
+
+// span checks that start and end are HH:MM times of one day with end after start
+func span(start, end string) (int, int, error) {
+	from, err := Clock(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := Clock(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from >= to {
+		return 0, 0, fmt.Errorf("end time %s must be after start time %s", end, start)
+	}
+	return from, to, nil
+}
+
+// parsePayload reads and validates the request body into payload, false means the error was written
+func parsePayload(w http.ResponseWriter, r *http.Request, payload any) bool {
+	if err := utils.ParseJSON(r, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return false
+	}
+	return true
+}
+
+func parseID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
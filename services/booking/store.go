@@ -0,0 +1,315 @@
+package booking
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusConfirmed = "confirmed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrUnavailable is returned when the requested start isn't a free slot (anymore)
+var ErrUnavailable = errors.New("time slot is not available")
+
+// ErrChanged is returned when the booking was cancelled or rescheduled meanwhile
+var ErrChanged = errors.New("booking changed meanwhile")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetCalendar(profileID uint) (*types.BookingCalendar, error) {
+	calendar, err := getCalendar(s.db, profileID)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return calendar, err
+	}
+
+	key, err := feedKey()
+	if err != nil {
+		return nil, err
+	}
+	calendar = &types.BookingCalendar{
+		ProfileID:        profileID,
+		TimeZone:         "UTC",
+		SlotInterval:     30,
+		MinNotice:        120,
+		Horizon:          60,
+		CancelNotice:     1440,
+		RescheduleNotice: 1440,
+		MaxReschedules:   2,
+		FeedKey:          key,
+		Rules:            []types.AvailabilityRule{},
+		Exceptions:       []types.AvailabilityException{},
+	}
+	if err := s.db.Create(calendar).Error; err != nil {
+		// a concurrent request created it first
+		if existing, err := getCalendar(s.db, profileID); err == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return calendar, nil
+}
+
+func (s *Store) UpdateCalendar(calendar *types.BookingCalendar) error {
+	return s.db.Omit("Rules", "Exceptions").Save(calendar).Error
+}
+
+// ReplaceRules swaps the weekly rules of a calendar
+func (s *Store) ReplaceRules(calendarID uint, rules []types.AvailabilityRule) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("calendar_id = ?", calendarID).Delete(&types.AvailabilityRule{}).Error; err != nil {
+			return err
+		}
+		for i := range rules {
+			rules[i].ID = 0
+			rules[i].CalendarID = calendarID
+		}
+		if len(rules) > 0 {
+			return tx.Create(&rules).Error
+		}
+		return nil
+	})
+}
+
+func (s *Store) CreateException(exception *types.AvailabilityException) error {
+	return s.db.Create(exception).Error
+}
+
+func (s *Store) GetExceptionByID(id uint) (*types.AvailabilityException, error) {
+	var exception types.AvailabilityException
+	result := s.db.First(&exception, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("exception not found")
+		}
+		return nil, result.Error
+	}
+	return &exception, nil
+}
+
+func (s *Store) DeleteException(id uint) error {
+	return s.db.Delete(&types.AvailabilityException{}, id).Error
+}
+
+// GetBookings lists bookings by start, the next ones first unless filter.Latest. Times are kept and
+// compared in UTC, SQLite compares them as text.
+func (s *Store) GetBookings(filter types.BookingFilter, cursor string, limit int) ([]types.Booking, *types.CursorPage, error) {
+	query := s.db.Model(&types.Booking{})
+	if filter.ProfileID != 0 {
+		query = query.Where("profile_id = ?", filter.ProfileID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("ends_at > ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("starts_at < ?", filter.To.UTC())
+	}
+
+	keyset := pagination.Keyset{Column: "starts_at", Desc: filter.Latest, Filter: filter, Limit: limit, Cursor: cursor}
+	return pagination.Paginate(query, keyset, func(b types.Booking) (any, uint) {
+		return b.StartsAt, b.ID
+	})
+}
+
+func (s *Store) GetCalendarBookings(profileID uint, from, to time.Time) ([]types.Booking, error) {
+	var bookings []types.Booking
+	result := s.db.Where("profile_id = ? AND starts_at < ? AND ends_at > ?", profileID, to.UTC(), from.UTC()).Order("starts_at, id").Find(&bookings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return bookings, nil
+}
+
+func (s *Store) GetBookingByID(id uint) (*types.Booking, error) {
+	var booking types.Booking
+	result := s.db.First(&booking, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("booking not found")
+		}
+		return nil, result.Error
+	}
+	return &booking, nil
+}
+
+func (s *Store) CreateBooking(booking *types.Booking) error {
+	booking.Status = StatusConfirmed
+	booking.StartsAt = booking.StartsAt.UTC()
+	booking.EndsAt = booking.StartsAt.Add(time.Duration(booking.Duration) * time.Minute)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		calendar, err := lock(tx, booking.ProfileID)
+		if err != nil {
+			return err
+		}
+		if err := free(tx, calendar, booking, booking.StartsAt); err != nil {
+			return err
+		}
+
+		booking.TimeZone = calendar.TimeZone
+		return tx.Create(booking).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	dispatch("booking.confirmed", *booking, nil)
+	return nil
+}
+
+func (s *Store) RescheduleBooking(booking *types.Booking, startsAt time.Time) error {
+	previous := booking.StartsAt
+	startsAt = startsAt.UTC()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		calendar, err := lock(tx, booking.ProfileID)
+		if err != nil {
+			return err
+		}
+		if err := free(tx, calendar, booking, startsAt); err != nil {
+			return err
+		}
+
+		// the status and count in the condition keep a cancellation or another reschedule from getting lost
+		result := tx.Model(&types.Booking{}).
+			Where("id = ? AND status = ? AND reschedules = ?", booking.ID, StatusConfirmed, booking.Reschedules).
+			Updates(map[string]any{
+				"starts_at":   startsAt,
+				"ends_at":     startsAt.Add(time.Duration(booking.Duration) * time.Minute),
+				"reschedules": gorm.Expr("reschedules + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %d", ErrChanged, booking.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rescheduled, err := s.GetBookingByID(booking.ID)
+	if err != nil {
+		return err
+	}
+	*booking = *rescheduled
+	dispatch("booking.rescheduled", *booking, &previous)
+	return nil
+}
+
+func (s *Store) CancelBooking(booking *types.Booking, reason string, userID int) error {
+	result := s.db.Model(&types.Booking{}).
+		Where("id = ? AND status = ?", booking.ID, StatusConfirmed).
+		Updates(map[string]any{
+			"status":              StatusCancelled,
+			"cancelled_by":        userID,
+			"cancelled_at":        time.Now(),
+			"cancellation_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrChanged, booking.ID)
+	}
+
+	cancelled, err := s.GetBookingByID(booking.ID)
+	if err != nil {
+		return err
+	}
+	*booking = *cancelled
+	dispatch("booking.cancelled", *booking, nil)
+	return nil
+}
+
+// lock writes the calendar of the professional before anything is read. The write takes a row lock on
+// databases that have them and the write lock on SQLite, so bookings of one professional run one after
+// another and never see the calendar without the booking the other one is about to store.
+func lock(tx *gorm.DB, profileID uint) (*types.BookingCalendar, error) {
+	result := tx.Model(&types.BookingCalendar{}).Where("profile_id = ?", profileID).UpdateColumn("updated_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUnavailable
+	}
+	return getCalendar(tx, profileID)
+}
+
+// free makes sure startsAt is a free slot for the booking, the booking itself doesn't count when it is rescheduled
+func free(tx *gorm.DB, calendar *types.BookingCalendar, booking *types.Booking, startsAt time.Time) error {
+	endsAt := startsAt.Add(time.Duration(booking.Duration) * time.Minute)
+	margin := time.Duration(calendar.Buffer) * time.Minute
+
+	var bookings []types.Booking
+	query := tx.Where("profile_id = ? AND status = ? AND starts_at < ? AND ends_at > ?", booking.ProfileID, StatusConfirmed, endsAt.Add(margin), startsAt.Add(-margin))
+	if booking.ID != 0 {
+		query = query.Where("id <> ?", booking.ID)
+	}
+	if err := query.Find(&bookings).Error; err != nil {
+		return err
+	}
+
+	ok, err := Bookable(calendar, bookings, booking.Duration, startsAt, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func getCalendar(db *gorm.DB, profileID uint) (*types.BookingCalendar, error) {
+	var calendar types.BookingCalendar
+	result := db.
+		Preload("Rules", func(db *gorm.DB) *gorm.DB {
+			return db.Order("weekday, start_time")
+		}).
+		Preload("Exceptions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("start_date, start_time")
+		}).
+		Where("profile_id = ?", profileID).
+		First(&calendar)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &calendar, nil
+}
+
+// feedKey is the secret in the address of the calendar feed, calendar apps can't send a token
+func feedKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func dispatch(name string, booking types.Booking, previous *time.Time) {
+	event.Dispatch(types.Event{
+		Name:    name,
+		Payload: types.BookingEvent{Booking: booking, PreviousStartsAt: previous},
+	})
+}
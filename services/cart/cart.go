@@ -0,0 +1,313 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+var (
+	// ErrItemNotFound is returned for item IDs that aren't in the cart
+	ErrItemNotFound = errors.New("cart item not found")
+
+	errVariantNotFound = errors.New("variant not found")
+	errChooseVariant   = errors.New("choose a variant")
+)
+
+// Pricing prices carts from the current product data, applies the promotions on top, taxes the rest
+// and adds the shipping
+type Pricing struct {
+	products   types.ProductStore
+	promotions types.PromotionEngine
+	taxes      types.TaxEngine
+	shipping   types.ShippingEngine
+}
+
+func NewPricing(products types.ProductStore, promotions types.PromotionEngine, taxes types.TaxEngine, shipping types.ShippingEngine) *Pricing {
+	return &Pricing{products: products, promotions: promotions, taxes: taxes, shipping: shipping}
+}
+
+// catalog looks products up once per cart operation
+type catalog struct {
+	products types.ProductStore
+	cache    map[uint]*types.Product
+}
+
+func newCatalog(products types.ProductStore) *catalog {
+	return &catalog{products: products, cache: make(map[uint]*types.Product)}
+}
+
+// lookup finds the product and, for products with variants, the variant that is sold
+func (c *catalog) lookup(productID uint, variantID *uint) (*types.Product, *types.ProductVariant, error) {
+	product, ok := c.cache[productID]
+	if !ok {
+		var err error
+		product, err = c.products.GetProductByID(productID)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.cache[productID] = product
+	}
+
+	if variantID == nil {
+		if len(product.Variants) > 0 {
+			return nil, nil, fmt.Errorf("%w of %s", errChooseVariant, product.Name)
+		}
+		return product, nil, nil
+	}
+
+	for i := range product.Variants {
+		if product.Variants[i].ID == *variantID {
+			return product, &product.Variants[i], nil
+		}
+	}
+	return nil, nil, errVariantNotFound
+}
+
+// price fills the item from the current product data
+func price(item *types.CartItem, product *types.Product, variant *types.ProductVariant) {
+	item.Name = product.Name
+	item.ImageURL = product.ImageURL
+	item.CategoryID = product.CategoryID
+	item.TaxClass = product.TaxClass
+	item.Weight = product.Weight
+	item.Volume = int64(product.Length) * int64(product.Width) * int64(product.Height)
+	item.UnitPrice = product.Price
+	item.SKU = ""
+	if product.SKU != nil {
+		item.SKU = *product.SKU
+	}
+	// reservations are taken out of the stock already, it is what is left to sell
+	item.Available = max(product.Stock, 0)
+
+	if variant != nil {
+		item.SKU = variant.SKU
+		item.Available = max(variant.Stock, 0)
+		if variant.PriceOverride != nil {
+			item.UnitPrice = money.New(*variant.PriceOverride, product.Price.Currency)
+		}
+	}
+
+	item.Total = money.Multiply(item.UnitPrice, item.Quantity)
+}
+
+// Recalculate refreshes names, prices and stock of all items, sums the cart up again and applies the promotions, taxes and shipping.
+// Items that can't be sold like that anymore, e.g. because their variant was deleted, are dropped.
+func (p *Pricing) Recalculate(cart *types.Cart) error {
+	return p.recalculate(cart, newCatalog(p.products))
+}
+
+func (p *Pricing) recalculate(cart *types.Cart, c *catalog) error {
+	items := make([]types.CartItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		product, variant, err := c.lookup(item.ProductID, item.VariantID)
+		if errors.Is(err, errVariantNotFound) || errors.Is(err, errChooseVariant) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		price(&item, product, variant)
+		items = append(items, item)
+	}
+	cart.Items = items
+
+	cart.ItemCount = 0
+	cart.Subtotal = money.New(0, money.BaseCurrency())
+	for _, item := range cart.Items {
+		subtotal, err := money.Add(cart.Subtotal, item.Total)
+		if err != nil {
+			return err
+		}
+		cart.Subtotal = subtotal
+		cart.ItemCount += item.Quantity
+	}
+	if err := p.promotions.Apply(cart); err != nil {
+		return err
+	}
+	if err := p.taxes.Apply(cart); err != nil {
+		return err
+	}
+	return p.shipping.Apply(cart)
+}
+
+// AddItem puts the quantity into the cart, on top of what the cart already holds of the same product or variant
+func (p *Pricing) AddItem(cart *types.Cart, payload types.CartItemPayload) error {
+	c := newCatalog(p.products)
+	product, variant, err := c.lookup(payload.ProductID, payload.VariantID)
+	if err != nil {
+		return err
+	}
+
+	index := findItem(cart, payload.ProductID, payload.VariantID)
+	if index < 0 {
+		cart.Items = append(cart.Items, types.CartItem{
+			ProductID: payload.ProductID,
+			VariantID: payload.VariantID,
+		})
+		index = len(cart.Items) - 1
+	}
+
+	item := &cart.Items[index]
+	item.Quantity += payload.Quantity
+	price(item, product, variant)
+	if item.Quantity > item.Available {
+		return fmt.Errorf("only %d of %s in stock", item.Available, item.Name)
+	}
+
+	return p.recalculate(cart, c)
+}
+
+// SetQuantity changes the quantity of an item
+func (p *Pricing) SetQuantity(cart *types.Cart, itemID uint, quantity int) error {
+	index := findItemByID(cart, itemID)
+	if index < 0 {
+		return ErrItemNotFound
+	}
+
+	c := newCatalog(p.products)
+	item := &cart.Items[index]
+	product, variant, err := c.lookup(item.ProductID, item.VariantID)
+	if err != nil {
+		return err
+	}
+
+	item.Quantity = quantity
+	price(item, product, variant)
+	if item.Quantity > item.Available {
+		return fmt.Errorf("only %d of %s in stock", item.Available, item.Name)
+	}
+
+	return p.recalculate(cart, c)
+}
+
+// RemoveItem takes an item out of the cart
+func (p *Pricing) RemoveItem(cart *types.Cart, itemID uint) error {
+	index := findItemByID(cart, itemID)
+	if index < 0 {
+		return ErrItemNotFound
+	}
+
+	cart.Items = append(cart.Items[:index], cart.Items[index+1:]...)
+	return p.Recalculate(cart)
+}
+
+// Merge moves the items of the guest cart into the user's cart. Quantities of the same product
+// or variant are added up but never beyond the stock, a merge doesn't fail over stock.
+func (p *Pricing) Merge(into, from *types.Cart) error {
+	for _, item := range from.Items {
+		index := findItem(into, item.ProductID, item.VariantID)
+		if index < 0 {
+			into.Items = append(into.Items, types.CartItem{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Quantity:  item.Quantity,
+			})
+			continue
+		}
+		into.Items[index].Quantity += item.Quantity
+	}
+	if into.CouponCode == nil {
+		into.CouponCode = from.CouponCode
+	}
+	if into.Country == "" {
+		into.Country, into.Region = from.Country, from.Region
+	}
+	if into.ShippingMethodID == nil {
+		into.ShippingMethodID = from.ShippingMethodID
+	}
+
+	if err := p.Recalculate(into); err != nil {
+		return err
+	}
+
+	items := into.Items[:0]
+	for _, item := range into.Items {
+		item.Quantity = min(item.Quantity, item.Available)
+		if item.Quantity > 0 {
+			items = append(items, item)
+		}
+	}
+	into.Items = items
+
+	return p.Recalculate(into)
+}
+
+// ApplyCoupon puts the coupon code on the cart once the promotion behind it applies to the cart
+func (p *Pricing) ApplyCoupon(cart *types.Cart, code string) error {
+	if err := p.Recalculate(cart); err != nil {
+		return err
+	}
+	if err := p.promotions.Check(cart, code); err != nil {
+		return err
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	cart.CouponCode = &code
+	return p.Recalculate(cart)
+}
+
+// SetDestination changes where the cart ships to, the taxes follow the destination
+func (p *Pricing) SetDestination(cart *types.Cart, country, region string) error {
+	cart.Country = strings.ToUpper(country)
+	cart.Region = strings.ToUpper(strings.TrimSpace(region))
+	return p.Recalculate(cart)
+}
+
+// ShippingQuotes lists the shipping methods that can deliver the cart to the country
+func (p *Pricing) ShippingQuotes(cart *types.Cart, country string) ([]types.ShippingQuote, error) {
+	if err := p.Recalculate(cart); err != nil {
+		return nil, err
+	}
+	return p.shipping.Quote(cart, country)
+}
+
+// SetShippingMethod chooses how the cart ships once the method can deliver it to its destination
+func (p *Pricing) SetShippingMethod(cart *types.Cart, methodID uint) error {
+	if err := p.Recalculate(cart); err != nil {
+		return err
+	}
+	if err := p.shipping.Check(cart, methodID); err != nil {
+		return err
+	}
+
+	cart.ShippingMethodID = &methodID
+	return p.Recalculate(cart)
+}
+
+// ReadyToShip tells why the cart can't be checked out yet as far as shipping goes
+func (p *Pricing) ReadyToShip(cart *types.Cart) error {
+	return p.shipping.Ready(cart)
+}
+
+// RemoveCoupon takes the coupon code off the cart
+func (p *Pricing) RemoveCoupon(cart *types.Cart) error {
+	cart.CouponCode = nil
+	return p.Recalculate(cart)
+}
+
+func findItem(cart *types.Cart, productID uint, variantID *uint) int {
+	for i, item := range cart.Items {
+		if item.ProductID != productID {
+			continue
+		}
+		if (item.VariantID == nil && variantID == nil) ||
+			(item.VariantID != nil && variantID != nil && *item.VariantID == *variantID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findItemByID(cart *types.Cart, itemID uint) int {
+	for i, item := range cart.Items {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
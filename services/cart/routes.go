@@ -0,0 +1,531 @@
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+// CookieName is the cookie that holds the token of a guest cart
+const CookieName = "cart_token"
+
+const cookieMaxAge = 30 * 24 * 60 * 60
+
+type Handler struct {
+	store        types.CartStore
+	pricing      *Pricing
+	addressStore types.AddressStore
+	userStore    types.UserStore
+}
+
+func NewHandler(store types.CartStore, pricing *Pricing, addressStore types.AddressStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, pricing: pricing, addressStore: addressStore, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/cart", auth.WithOptionalJWTAuth(h.handleGetCart, h.userStore)).Methods("GET")
+	router.HandleFunc("/cart/items", auth.WithOptionalJWTAuth(h.handleAddItem, h.userStore)).Methods("POST")
+	router.HandleFunc("/cart/items/{itemId:[0-9]+}", auth.WithOptionalJWTAuth(h.handleUpdateItem, h.userStore)).Methods("PUT")
+	router.HandleFunc("/cart/items/{itemId:[0-9]+}", auth.WithOptionalJWTAuth(h.handleRemoveItem, h.userStore)).Methods("DELETE")
+	router.HandleFunc("/cart/destination", auth.WithOptionalJWTAuth(h.handleSetDestination, h.userStore)).Methods("PUT")
+	router.HandleFunc("/cart/shipping-methods", auth.WithOptionalJWTAuth(h.handleGetShippingMethods, h.userStore)).Methods("GET")
+	router.HandleFunc("/cart/shipping", auth.WithOptionalJWTAuth(h.handleSetShipping, h.userStore)).Methods("PUT")
+	router.HandleFunc("/cart/coupon", auth.WithOptionalJWTAuth(h.handleApplyCoupon, h.userStore)).Methods("POST")
+	router.HandleFunc("/cart/coupon", auth.WithOptionalJWTAuth(h.handleRemoveCoupon, h.userStore)).Methods("DELETE")
+}
+
+// RegisterListeners merges the guest cart into the user's cart when they log in
+func (h *Handler) RegisterListeners() {
+	event.Register("user.logged_in", func(e types.Event) {
+		login, ok := e.Payload.(types.UserLoggedInEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+		if login.CartToken == "" {
+			return
+		}
+		if err := h.mergeGuestCart(login.UserID, login.CartToken); err != nil {
+			log.Printf("failed to merge the guest cart of user %d: %v", login.UserID, err)
+		}
+	})
+}
+
+// @Summary Get cart
+// @Description Returns the cart of the logged in user or, without a token, the guest cart from the cart cookie.
+// @Description Prices, discounts, taxes, totals and the available stock are up to date, every item explains the
+// @Description discounts and taxes that apply to it.
+// @Tags Cart
+// @Produce json
+// @Success 200 {object} types.Cart
+// @Router /cart [get]
+func (h *Handler) handleGetCart(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.cart(w, r, false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.Recalculate(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Add to cart
+// @Description Adds a product or one of its variants to the cart, guests get a cart cookie on their first item
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param cartItemPayload body types.CartItemPayload true "Cart item payload"
+// @Success 200 {object} types.Cart
+// @Failure 400 {object} map[string]string "Invalid item or not enough stock"
+// @Router /cart/items [post]
+func (h *Handler) handleAddItem(w http.ResponseWriter, r *http.Request) {
+	var payload types.CartItemPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	cart, err := h.cart(w, r, true)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.AddItem(cart, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Update cart item
+// @Description Changes the quantity of an item in the cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param itemId path int true "Cart item ID"
+// @Param cartItemQuantityPayload body types.CartItemQuantityPayload true "Quantity payload"
+// @Success 200 {object} types.Cart
+// @Failure 400 {object} map[string]string "Invalid quantity or not enough stock"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Router /cart/items/{itemId} [put]
+func (h *Handler) handleUpdateItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseUint(mux.Vars(r)["itemId"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid item id"))
+		return
+	}
+
+	var payload types.CartItemQuantityPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	cart, err := h.cart(w, r, false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.SetQuantity(cart, uint(itemID), payload.Quantity); err != nil {
+		if errors.Is(err, ErrItemNotFound) {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Remove cart item
+// @Description Removes an item from the cart
+// @Tags Cart
+// @Produce json
+// @Param itemId path int true "Cart item ID"
+// @Success 200 {object} types.Cart
+// @Failure 404 {object} map[string]string "Item not found"
+// @Router /cart/items/{itemId} [delete]
+func (h *Handler) handleRemoveItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseUint(mux.Vars(r)["itemId"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid item id"))
+		return
+	}
+
+	cart, err := h.cart(w, r, false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.RemoveItem(cart, uint(itemID)); err != nil {
+		if errors.Is(err, ErrItemNotFound) {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Set cart destination
+// @Description Sets the country and region the cart ships to, the taxes and shipping are calculated for them
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param cartDestinationPayload body types.CartDestinationPayload true "Destination payload"
+// @Success 200 {object} types.Cart
+// @Failure 400 {object} map[string]string "Invalid destination"
+// @Router /cart/destination [put]
+func (h *Handler) handleSetDestination(w http.ResponseWriter, r *http.Request) {
+	var payload types.CartDestinationPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	cart, err := h.cart(w, r, true)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.SetDestination(cart, payload.Country, payload.Region); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary List shipping methods
+// @Description Quotes the shipping methods that can deliver the cart, cheapest first. The country defaults to the
+// @Description destination of the cart.
+// @Tags Cart
+// @Produce json
+// @Param country query string false "Country code"
+// @Success 200 {array} types.ShippingQuote
+// @Failure 400 {object} map[string]string "Invalid country"
+// @Router /cart/shipping-methods [get]
+func (h *Handler) handleGetShippingMethods(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.cart(w, r, false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	country := r.URL.Query().Get("country")
+	if country == "" {
+		country = cart.Country
+	}
+	if err := utils.Validate.Var(country, "omitempty,len=2,alpha"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid country %q", country))
+		return
+	}
+
+	quotes, err := h.pricing.ShippingQuotes(cart, country)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, quotes)
+}
+
+// @Summary Choose shipping method
+// @Description Chooses how the cart ships, the method has to deliver to the destination of the cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param cartShippingPayload body types.CartShippingPayload true "Shipping payload"
+// @Success 200 {object} types.Cart
+// @Failure 400 {object} map[string]string "No destination or the method doesn't deliver the cart"
+// @Router /cart/shipping [put]
+func (h *Handler) handleSetShipping(w http.ResponseWriter, r *http.Request) {
+	var payload types.CartShippingPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	cart, err := h.cart(w, r, true)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.SetShippingMethod(cart, payload.MethodID); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Apply coupon
+// @Description Puts a coupon code on the cart, a cart holds one coupon at a time
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param couponPayload body types.CouponPayload true "Coupon payload"
+// @Success 200 {object} types.Cart
+// @Failure 400 {object} map[string]string "Unknown, expired or used up coupon, or the cart doesn't qualify"
+// @Router /cart/coupon [post]
+func (h *Handler) handleApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	var payload types.CouponPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	cart, err := h.cart(w, r, true)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.ApplyCoupon(cart, payload.Code); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SaveCart(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// @Summary Remove coupon
+// @Description Takes the coupon code off the cart
+// @Tags Cart
+// @Produce json
+// @Success 200 {object} types.Cart
+// @Router /cart/coupon [delete]
+func (h *Handler) handleRemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.cart(w, r, false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.pricing.RemoveCoupon(cart); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cart.ID != 0 {
+		if err := h.store.SaveCart(cart); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, cart)
+}
+
+// cart finds the cart of the request. Users get their own cart, guests the one from the cookie.
+// Without a stored cart an empty one is returned, create stores it and hands guests a cookie.
+// Carts of users without a destination ship to the user's default shipping address.
+func (h *Handler) cart(w http.ResponseWriter, r *http.Request, create bool) (*types.Cart, error) {
+	token := ""
+	if cookie, err := r.Cookie(CookieName); err == nil {
+		token = cookie.Value
+	}
+
+	if userID := auth.GetUserIDFromContext(r.Context()); userID > 0 {
+		// a guest cart that survived the login, e.g. from a client that logged in elsewhere
+		if token != "" {
+			if err := h.mergeGuestCart(userID, token); err != nil {
+				return nil, err
+			}
+			ClearCookie(w)
+		}
+
+		cart, err := h.store.GetCartByUserID(userID)
+		if errors.Is(err, ErrNotFound) {
+			cart = &types.Cart{UserID: &userID}
+			if create {
+				err = h.store.CreateCart(cart)
+			} else {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if cart.Country == "" {
+			shipping, _, err := h.addressStore.GetDefaultAddresses(userID)
+			if err != nil {
+				return nil, err
+			}
+			if shipping != nil {
+				cart.Country, cart.Region = shipping.Country, strings.ToUpper(shipping.Region)
+			}
+		}
+		return cart, nil
+	}
+
+	if token != "" {
+		cart, err := h.store.GetCartByToken(token)
+		if !errors.Is(err, ErrNotFound) {
+			return cart, err
+		}
+	}
+
+	if !create {
+		return &types.Cart{}, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	cart := &types.Cart{Token: &token}
+	if err := h.store.CreateCart(cart); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return cart, nil
+}
+
+// mergeGuestCart moves the guest cart of the token into the user's cart, the guest cart becomes the
+// user's cart when they don't have one yet
+func (h *Handler) mergeGuestCart(userID int, token string) error {
+	guest, err := h.store.GetCartByToken(token)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cart, err := h.store.GetCartByUserID(userID)
+	if errors.Is(err, ErrNotFound) {
+		guest.UserID = &userID
+		guest.Token = nil
+		if err := h.pricing.Recalculate(guest); err != nil {
+			return err
+		}
+		return h.store.SaveCart(guest)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := h.pricing.Merge(cart, guest); err != nil {
+		return err
+	}
+	if err := h.store.SaveCart(cart); err != nil {
+		return err
+	}
+	return h.store.DeleteCart(guest.ID)
+}
+
+// ClearCookie drops the guest cart cookie, e.g. once the cart belongs to a user
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+package cart
+
+import (
+	"errors"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a user or token has no cart yet
+var ErrNotFound = errors.New("cart not found")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetCartByUserID(userID int) (*types.Cart, error) {
+	return s.getCart(s.db.Where("user_id = ?", userID))
+}
+
+func (s *Store) GetCartByToken(token string) (*types.Cart, error) {
+	return s.getCart(s.db.Where("token = ?", token))
+}
+
+func (s *Store) getCart(query *gorm.DB) (*types.Cart, error) {
+	var cart types.Cart
+	result := query.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&cart)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &cart, nil
+}
+
+func (s *Store) CreateCart(cart *types.Cart) error {
+	result := s.db.Create(cart)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// SaveCart stores the cart with exactly the items it holds, items that were removed from the slice are deleted
+func (s *Store) SaveCart(cart *types.Cart) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(cart).Error; err != nil {
+			return err
+		}
+
+		keep := make([]uint, 0, len(cart.Items))
+		for i := range cart.Items {
+			item := &cart.Items[i]
+			item.CartID = cart.ID
+			if err := tx.Save(item).Error; err != nil {
+				return err
+			}
+			keep = append(keep, item.ID)
+		}
+
+		stale := tx.Where("cart_id = ?", cart.ID)
+		if len(keep) > 0 {
+			stale = stale.Where("id NOT IN ?", keep)
+		}
+		return stale.Delete(&types.CartItem{}).Error
+	})
+}
+
+func (s *Store) DeleteCart(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cart_id = ?", id).Delete(&types.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.Cart{}, id).Error
+	})
+}
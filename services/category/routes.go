@@ -0,0 +1,265 @@
+package category
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.CategoryStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.CategoryStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/categories", h.handleGetCategories).Methods("GET")
+	router.HandleFunc("/categories/tree", h.handleGetCategoryTree).Methods("GET")
+	router.HandleFunc("/categories/{id:[0-9]+}", h.handleGetCategory).Methods("GET")
+
+	router.HandleFunc("/categories", auth.WithRoles(h.handleCreateCategory, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/categories/{id:[0-9]+}", auth.WithRoles(h.handleUpdateCategory, h.userStore, "admin", "operator")).Methods("PUT")
+	router.HandleFunc("/categories/{id:[0-9]+}", auth.WithRoles(h.handleDeleteCategory, h.userStore, "admin", "operator")).Methods("DELETE")
+}
+
+// @Summary List categories
+// @Description Lists all categories as a flat list ordered by position
+// @Tags Categories
+// @Produce json
+// @Success 200 {array} types.Category
+// @Router /categories [get]
+func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, categories)
+}
+
+// @Summary Category tree
+// @Description Returns the categories nested under their parents
+// @Tags Categories
+// @Produce json
+// @Success 200 {array} types.Category
+// @Router /categories/tree [get]
+func (h *Handler) handleGetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tree := BuildTree(categories)
+	if tree == nil {
+		tree = []types.Category{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tree)
+}
+
+// @Summary Get category
+// @Description Returns a single category with its attributes
+// @Tags Categories
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} types.Category
+// @Failure 404 {object} map[string]string "Category not found"
+// @Router /categories/{id} [get]
+func (h *Handler) handleGetCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	category, err := h.store.GetCategoryByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, category)
+}
+
+// @Summary Create category
+// @Description Creates a category, the slug is derived from the name when omitted
+// @Tags Categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param categoryPayload body types.CategoryPayload true "Category payload"
+// @Success 201 {object} types.Category
+// @Failure 400 {object} map[string]string "Invalid category data"
+// @Router /categories [post]
+func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	var payload types.CategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	category := types.Category{}
+	if err := h.applyPayload(&category, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreateCategory(&category); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, category)
+}
+
+// @Summary Update category
+// @Description Updates a category and replaces its attributes
+// @Tags Categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Param categoryPayload body types.CategoryPayload true "Category payload"
+// @Success 200 {object} types.Category
+// @Failure 400 {object} map[string]string "Invalid category data"
+// @Failure 404 {object} map[string]string "Category not found"
+// @Router /categories/{id} [put]
+func (h *Handler) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	category, err := h.store.GetCategoryByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.CategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyPayload(category, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateCategory(category); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, category)
+}
+
+// @Summary Delete category
+// @Description Deletes a category without subcategories, its products become uncategorized
+// @Tags Categories
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]string "Category deleted"
+// @Failure 400 {object} map[string]string "Category still has subcategories"
+// @Router /categories/{id} [delete]
+func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteCategory(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Category deleted"})
+}
+
+// applyPayload copies the payload onto the category after checking the slug and parent are usable
+func (h *Handler) applyPayload(category *types.Category, payload types.CategoryPayload) error {
+	slug := utils.Slugify(payload.Slug)
+	if slug == "" {
+		slug = utils.Slugify(payload.Name)
+	}
+	if slug == "" {
+		return fmt.Errorf("slug can't be derived from the name, please provide one")
+	}
+
+	if existing, err := h.store.GetCategoryBySlug(slug); err == nil && existing.ID != category.ID {
+		return fmt.Errorf("category with slug %s already exists", slug)
+	}
+
+	if payload.ParentID != nil {
+		if category.ID != 0 && *payload.ParentID == category.ID {
+			return fmt.Errorf("category can't be its own parent")
+		}
+
+		if _, err := h.store.GetCategoryByID(*payload.ParentID); err != nil {
+			return fmt.Errorf("parent %v", err)
+		}
+
+		if category.ID != 0 {
+			categories, err := h.store.GetCategories()
+			if err != nil {
+				return err
+			}
+			if IsDescendant(categories, *payload.ParentID, category.ID) {
+				return fmt.Errorf("category can't be moved below one of its subcategories")
+			}
+		}
+	}
+
+	category.Name = payload.Name
+	category.Slug = slug
+	category.ParentID = payload.ParentID
+	category.Position = payload.Position
+
+	category.Attributes = make([]types.CategoryAttribute, 0, len(payload.Attributes))
+	for i, attribute := range payload.Attributes {
+		category.Attributes = append(category.Attributes, types.CategoryAttribute{
+			Name:     attribute.Name,
+			Type:     attribute.Type,
+			Options:  attribute.Options,
+			Required: attribute.Required,
+			Position: i,
+		})
+	}
+
+	return nil
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,162 @@
+package category
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetCategories() ([]types.Category, error) {
+	var categories []types.Category
+	result := s.db.Preload("Attributes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Order("position, name").Find(&categories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return categories, nil
+}
+
+func (s *Store) GetCategoryByID(id uint) (*types.Category, error) {
+	var category types.Category
+	result := s.db.Preload("Attributes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).First(&category, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, result.Error
+	}
+	return &category, nil
+}
+
+func (s *Store) GetCategoryBySlug(slug string) (*types.Category, error) {
+	var category types.Category
+	result := s.db.Where("slug = ?", slug).First(&category)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, result.Error
+	}
+	return &category, nil
+}
+
+func (s *Store) CreateCategory(category *types.Category) error {
+	result := s.db.Create(category)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// UpdateCategory saves the category and replaces its attributes with the given ones
+func (s *Store) UpdateCategory(category *types.Category) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Attributes").Save(category).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("category_id = ?", category.ID).Delete(&types.CategoryAttribute{}).Error; err != nil {
+			return err
+		}
+
+		for i := range category.Attributes {
+			category.Attributes[i].ID = 0
+			category.Attributes[i].CategoryID = category.ID
+		}
+
+		if len(category.Attributes) > 0 {
+			if err := tx.Create(&category.Attributes).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteCategory removes a leaf category, its products are left uncategorized
+func (s *Store) DeleteCategory(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&types.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return fmt.Errorf("category still has subcategories")
+		}
+
+		if err := tx.Model(&types.Product{}).Where("category_id = ?", id).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("category_id = ?", id).Delete(&types.CategoryAttribute{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&types.Category{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("category not found")
+		}
+		return nil
+	})
+}
+
+// BuildTree nests a flat list of categories under their parents, keeping each level ordered by position
+func BuildTree(categories []types.Category) []types.Category {
+	children := make(map[uint][]types.Category)
+	var roots []types.Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var attach func(nodes []types.Category) []types.Category
+	attach = func(nodes []types.Category) []types.Category {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].Position < nodes[j].Position
+		})
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	return attach(roots)
+}
+
+// IsDescendant reports whether id sits somewhere below ancestorID in the tree
+func IsDescendant(categories []types.Category, id, ancestorID uint) bool {
+	parents := make(map[uint]*uint, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+
+	// the walk is bounded by the number of categories so a corrupted tree can't loop forever
+	current := parents[id]
+	for i := 0; current != nil && i < len(categories); i++ {
+		if *current == ancestorID {
+			return true
+		}
+		current = parents[*current]
+	}
+	return false
+}
@@ -1,33 +1,125 @@
 package email
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"log"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
+	"os"
 )
 
-func SendEmail(email []string, rememberToken *string) (string, error) {
-	from := "yahyaammar4807@gmail.com"
-	password := "wibh mbwt eezn avgf"
+// smtpConfig is the mail server from SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD and SMTP_FROM,
+// mails aren't sent while host, user or password are unset
+type smtpConfig struct {
+	host, port, user, password, from string
+}
+
+func loadSMTP() (smtpConfig, bool) {
+	config := smtpConfig{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     os.Getenv("SMTP_PORT"),
+		user:     os.Getenv("SMTP_USER"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+	if config.port == "" {
+		config.port = "587"
+	}
+	if config.from == "" {
+		config.from = config.user
+	}
+	return config, config.host != "" && config.user != "" && config.password != ""
+}
 
-	to := email
+func SendEmail(email []string, rememberToken *string) (string, error) {
 	subject := "Subject: Test Email from Go\n"
 	body := fmt.Sprintf(`Hello, click the button below to reset your password:
 	<a href='http://localhost:3000/create-password.html?token=%s' style='display:inline-block; padding:10px 20px; font-size:16px; color:white; background-color:#007BFF; text-decoration:none; border-radius:5px;'>Reset Password</a>`, *rememberToken)
+
+	send(email, subject, body)
+	return "Email sent successfully!", nil
+}
+
+// SendExportFinished tells the user that their export is ready, or why it failed when the link is empty
+func SendExportFinished(email string, jobID uint, link string, reason string) {
+	if link == "" {
+		body := fmt.Sprintf(`Hello, your product export #%d failed: %s`, jobID, reason)
+		send([]string{email}, fmt.Sprintf("Export #%d failed", jobID), body)
+		return
+	}
+
+	body := fmt.Sprintf(`Hello, your product export #%d is ready. The link below works for a limited time:
+	<a href='%s' style='display:inline-block; padding:10px 20px; font-size:16px; color:white; background-color:#007BFF; text-decoration:none; border-radius:5px;'>Download export</a>`, jobID, link)
+	send([]string{email}, fmt.Sprintf("Export #%d is ready", jobID), body)
+}
+
+// SendInvoice mails the invoice of a paid order with its PDF attached
+func SendInvoice(email string, number string, orderID uint, pdf []byte) {
+	body := fmt.Sprintf(`Hello, thank you for your order #%d. Your invoice %s is attached.`, orderID, number)
+	sendWithAttachment([]string{email}, fmt.Sprintf("Invoice %s for order #%d", number, orderID), body, number+".pdf", "application/pdf", pdf)
+}
+
+// SendBackInStock tells a subscriber that a product they waited for can be ordered again
+func SendBackInStock(email string, product string) {
+	body := fmt.Sprintf(`Hello, good news: %s is back in stock. Order soon, we let you know only once.`, product)
+	send([]string{email}, fmt.Sprintf("%s is back in stock", product), body)
+}
+
+func send(to []string, subject string, body string) {
 	msg := []byte("MIME-Version: 1.0;\n" +
 		"Content-Type: text/html; charset=\"UTF-8\";\n" +
 		"Subject: " + subject + "\n" +
 		"\n" + body)
 
-	smtpServer := "smtp.gmail.com"
-	auth := smtp.PlainAuth("", from, password, smtpServer)
+	deliver(to, msg)
+}
+
+// sendWithAttachment sends the html body with one file attached
+func sendWithAttachment(to []string, subject, body, filename, contentType string, data []byte) {
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+
+	html, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {`text/html; charset="UTF-8"`}})
+	html.Write([]byte(body))
+
+	attachment, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf(`attachment; filename="%s"`, filename)},
+	})
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		attachment.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	attachment.Write([]byte(encoded))
+	writer.Close()
+
+	msg := []byte("MIME-Version: 1.0\n" +
+		"Content-Type: multipart/mixed; boundary=" + writer.Boundary() + "\n" +
+		"Subject: " + subject + "\n" +
+		"\n" + parts.String())
+
+	deliver(to, msg)
+}
+
+func deliver(to []string, msg []byte) {
+	config, ok := loadSMTP()
+	if !ok {
+		log.Printf("not sending email to %v, SMTP_HOST, SMTP_USER and SMTP_PASSWORD are not set", to)
+		return
+	}
+	auth := smtp.PlainAuth("", config.user, config.password, config.host)
 
 	go func() {
-		err := smtp.SendMail(smtpServer+":587", auth, from, to, msg)
+		err := smtp.SendMail(config.host+":"+config.port, auth, config.from, to, msg)
 		if err != nil {
 			fmt.Println("Error sending email:", err)
 		} else {
 			fmt.Println("Email sent successfully")
 		}
 	}()
-	return "Email sent successfully!", nil
 }
@@ -35,12 +35,14 @@ func Register(eventName string, listener types.Listener) {
 	dispatcher.listeners[eventName] = append(dispatcher.listeners[eventName], listener)
 }
 
-// Dispatch triggers an event
+// Dispatch triggers an event. Listeners run without the lock held so they can dispatch events themselves.
 func Dispatch(event types.Event) {
 	dispatcher := GetDispatcher()
 	dispatcher.mu.Lock()
-	defer dispatcher.mu.Unlock()
-	for _, listener := range dispatcher.listeners[event.Name] {
+	listeners := append([]types.Listener(nil), dispatcher.listeners[event.Name]...)
+	dispatcher.mu.Unlock()
+
+	for _, listener := range listeners {
 		listener(event)
 	}
 }
@@ -3,6 +3,8 @@ package event
 import (
 	"fmt"
 
+	email "github.com/yahyaammar-dev/pacebe/services/emails"
+
 	"github.com/yahyaammar-dev/pacebe/types"
 )
 
@@ -29,4 +31,39 @@ func NewListener() {
 		fmt.Printf("Logging user creation for %s\n", user)
 	})
 
+	// Warn operators when a product runs low on stock
+	Register("inventory.low", func(e types.Event) {
+		low, ok := e.Payload.(types.LowStockEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+		fmt.Printf("Product %d is low on stock: %d left, threshold %d\n", low.ProductID, low.Available, low.Threshold)
+	})
+
+	// Log every step of an order
+	for _, status := range []string{"pending", "paid", "fulfilled", "delivered", "cancelled", "refunded"} {
+		Register("order."+status, func(e types.Event) {
+			changed, ok := e.Payload.(types.OrderEvent)
+			if !ok {
+				fmt.Println("Invalid payload")
+				return
+			}
+			fmt.Printf("Order %d of user %d is now %s\n", changed.Order.ID, changed.Order.UserID, changed.To)
+		})
+	}
+
+	// Mail the download link to whoever started an export
+	Register("export.finished", func(e types.Event) {
+		finished, ok := e.Payload.(types.ExportFinishedEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+		fmt.Printf("Export %d %s\n", finished.JobID, finished.Status)
+		if finished.Email != "" {
+			email.SendExportFinished(finished.Email, finished.JobID, finished.DownloadURL, finished.Error)
+		}
+	})
+
 }
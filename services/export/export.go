@@ -1,63 +1,196 @@
 package export
 
 import (
-	"encoding/csv"
 	"fmt"
-	"net/http"
+	"io"
+	"strings"
 	"time"
 
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/product"
 	"github.com/yahyaammar-dev/pacebe/types"
 	"gorm.io/gorm"
 )
 
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+// batchSize is how many products are loaded at a time while streaming
+const batchSize = 500
+
+// Column is one exportable product field, Value returns a string, a number or a time
+type Column struct {
+	Name  string
+	Value func(product types.Product) any
+}
+
+// Columns lists every exportable column, DefaultColumns is the header the importer reads back
+var Columns = []Column{
+	{"ID", func(p types.Product) any { return p.ID }},
+	{"Name", func(p types.Product) any { return p.Name }},
+	{"SKU", func(p types.Product) any {
+		if p.SKU == nil {
+			return ""
+		}
+		return *p.SKU
+	}},
+	{"Description", func(p types.Product) any { return p.Description }},
+	{"Price", func(p types.Product) any { return money.Format(p.Price) }},
+	{"Currency", func(p types.Product) any { return p.Price.Currency }},
+	{"Stock", func(p types.Product) any { return p.Stock }},
+	{"Reserved", func(p types.Product) any { return p.Reserved }},
+	{"LowStockThreshold", func(p types.Product) any { return p.LowStockThreshold }},
+	{"Category", func(p types.Product) any {
+		if p.Category == nil {
+			return ""
+		}
+		return p.Category.Name
+	}},
+	{"ImageURL", func(p types.Product) any { return p.ImageURL }},
+	{"CreatedAt", func(p types.Product) any { return p.CreatedAt }},
+	{"UpdatedAt", func(p types.Product) any { return p.UpdatedAt }},
+}
+
+var DefaultColumns = []string{"ID", "Name", "SKU", "Description", "Price", "Currency", "Stock", "Category", "ImageURL", "CreatedAt", "UpdatedAt"}
+
+// Options describes one export, Currency converts the prices like the product list does
+type Options struct {
+	Format   string
+	Columns  []Column
+	Filter   types.ProductFilter
+	Currency string
+	// Progress is called after every batch with the number of rows written so far
+	Progress func(rows int)
+}
+
 type Export struct {
-	db *gorm.DB
+	db            *gorm.DB
+	currencyStore types.CurrencyStore
 }
 
-func NewExporter(db *gorm.DB) *Export {
+func NewExporter(db *gorm.DB, currencyStore types.CurrencyStore) *Export {
 	return &Export{
-		db: db,
+		db:            db,
+		currencyStore: currencyStore,
 	}
 }
 
-func (e *Export) ExportCSV(w http.ResponseWriter) {
-	var products []types.Product
-	result := e.db.Find(&products) // Fetch all products from the database
-	if result.Error != nil {
-		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Set headers for CSV download
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment;filename=products.csv")
-
-	writer := csv.NewWriter(w)
-	defer writer.Flush()
-
-	// Write CSV header
-	header := []string{"ID", "Name", "Description", "Price", "Stock", "Category", "ImageURL", "CreatedAt", "UpdatedAt"}
-	if err := writer.Write(header); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Write each product as a CSV row
-	for _, product := range products {
-		record := []string{
-			fmt.Sprintf("%d", product.ID),
-			product.Name,
-			product.Description,
-			fmt.Sprintf("%.2f", product.Price),
-			fmt.Sprintf("%d", product.Stock),
-			product.Category,
-			product.ImageURL,
-			product.CreatedAt.Format(time.RFC3339),
-			product.UpdatedAt.Format(time.RFC3339),
+// ParseColumns picks columns by their names, case doesn't matter. No names select the default columns.
+func ParseColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+
+	columns := make([]Column, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
 		}
-		if err := writer.Write(record); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+
+		found := false
+		for _, column := range Columns {
+			if strings.EqualFold(column.Name, name) {
+				if seen[column.Name] {
+					return nil, fmt.Errorf("column %s is listed twice", column.Name)
+				}
+				seen[column.Name] = true
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %s", name)
 		}
 	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+	return columns, nil
+}
+
+// ContentType is the media type and file extension of a format
+func ContentType(format string) (string, string, error) {
+	switch format {
+	case FormatCSV:
+		return "text/csv", ".csv", nil
+	case FormatNDJSON:
+		return "application/x-ndjson", ".ndjson", nil
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx", nil
+	default:
+		return "", "", fmt.Errorf("unknown format %s, use csv, ndjson or xlsx", format)
+	}
+}
+
+// Count is the number of products an export with the filter will write
+func (e *Export) Count(filter types.ProductFilter) (int64, error) {
+	var total int64
+	err := product.ApplyFilter(e.db.Model(&types.Product{}), filter).Count(&total).Error
+	return total, err
+}
+
+// Export writes the matching products to w in batches, so memory stays flat however many products match.
+// It returns the number of rows written.
+func (e *Export) Export(w io.Writer, options Options) (int, error) {
+	writer, err := newRowWriter(w, options.Format, options.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(options.Columns))
+	for i, column := range options.Columns {
+		names[i] = column.Name
+	}
+	if err := writer.WriteHeader(names); err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	var batch []types.Product
+	result := product.ApplyFilter(e.db.Model(&types.Product{}), options.Filter).
+		Preload("Category").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			if err := e.currencyStore.LocalizeProducts(batch, options.Currency); err != nil {
+				return err
+			}
+
+			values := make([]any, len(options.Columns))
+			for _, p := range batch {
+				for i, column := range options.Columns {
+					values[i] = column.Value(p)
+				}
+				if err := writer.WriteRow(values); err != nil {
+					return err
+				}
+				rows++
+			}
+
+			if options.Progress != nil {
+				options.Progress(rows)
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return rows, result.Error
+	}
+
+	return rows, writer.Close()
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
 }
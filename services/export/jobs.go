@@ -0,0 +1,244 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/configs"
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// progressInterval throttles how often a running job writes its progress
+const progressInterval = time.Second
+
+// LinkTTL is how long a download link stays valid, from EXPORT_LINK_TTL, e.g. 2h. One day by default.
+func LinkTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("EXPORT_LINK_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 24 * time.Hour
+}
+
+// AppURL is the public address of the API used in links sent out by email, from APP_URL
+func AppURL() string {
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		return strings.TrimSuffix(appURL, "/")
+	}
+	return fmt.Sprintf("http://localhost:%s", configs.Envs.Port)
+}
+
+// Worker runs export jobs one after another so a burst of exports can't starve the database
+type Worker struct {
+	store     types.ExportJobStore
+	exporter  *Export
+	storage   storage.Storage
+	userStore types.UserStore
+	queue     chan uint
+}
+
+func NewWorker(store types.ExportJobStore, exporter *Export, storage storage.Storage, userStore types.UserStore) *Worker {
+	return &Worker{
+		store:     store,
+		exporter:  exporter,
+		storage:   storage,
+		userStore: userStore,
+		queue:     make(chan uint, 100),
+	}
+}
+
+// Start picks up the jobs a restart interrupted and then waits for new ones
+func (wk *Worker) Start() error {
+	unfinished, err := wk.store.GetUnfinishedExportJobs()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for _, job := range unfinished {
+			wk.queue <- job.ID
+		}
+	}()
+
+	go func() {
+		for id := range wk.queue {
+			wk.run(id)
+		}
+	}()
+	return nil
+}
+
+// Enqueue hands a created job to the worker, it blocks while the queue is full
+func (wk *Worker) Enqueue(id uint) {
+	wk.queue <- id
+}
+
+func (wk *Worker) run(id uint) {
+	job, err := wk.store.GetExportJobByID(id)
+	if err != nil {
+		log.Printf("export %d: %v", id, err)
+		return
+	}
+
+	if err := wk.export(job); err != nil {
+		log.Printf("export %d failed: %v", id, err)
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = StatusCompleted
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err := wk.store.UpdateExportJob(job); err != nil {
+		log.Printf("export %d: %v", id, err)
+		return
+	}
+
+	wk.notify(job)
+}
+
+func (wk *Worker) export(job *types.ExportJob) error {
+	columns, err := ParseColumns(job.Columns)
+	if err != nil {
+		return err
+	}
+	contentType, extension, err := ContentType(job.Format)
+	if err != nil {
+		return err
+	}
+
+	total, err := wk.exporter.Count(job.Filter)
+	if err != nil {
+		return err
+	}
+
+	job.Status = StatusRunning
+	job.TotalRows = int(total)
+	job.ProcessedRows = 0
+	job.Error = ""
+	if err := wk.store.UpdateExportJob(job); err != nil {
+		return err
+	}
+
+	// the file is built on disk first, uploads need its size and a failed export must not leave half an object
+	file, err := os.CreateTemp("", "export-*"+extension)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	lastSave := time.Now()
+	rows, err := wk.exporter.Export(file, Options{
+		Format:   job.Format,
+		Columns:  columns,
+		Filter:   job.Filter,
+		Currency: job.Currency,
+		Progress: func(rows int) {
+			job.ProcessedRows = rows
+			if time.Since(lastSave) < progressInterval {
+				return
+			}
+			lastSave = time.Now()
+			if err := wk.store.UpdateExportJob(job); err != nil {
+				log.Printf("failed to save progress of export %d: %v", job.ID, err)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	job.ProcessedRows = rows
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+
+	// the random part keeps the key from being guessed, the file name stays readable for the download
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%sexports/%d/%s/products-%s%s", storage.PrivatePrefix, job.ID, hex.EncodeToString(b), job.CreatedAt.Format("20060102-150405"), extension)
+	if err := wk.storage.Put(context.Background(), key, file, info.Size(), contentType); err != nil {
+		return err
+	}
+
+	job.Key = key
+	job.Size = info.Size()
+	return nil
+}
+
+// notify lets the user who created the job know it finished, listeners send the email
+func (wk *Worker) notify(job *types.ExportJob) {
+	finished := types.ExportFinishedEvent{
+		JobID:  job.ID,
+		Status: job.Status,
+		Error:  job.Error,
+	}
+	if job.Status == StatusCompleted {
+		finished.DownloadURL = AppURL() + DownloadPath(job.ID, time.Now().Add(LinkTTL()))
+	}
+	if job.CreatedBy != nil {
+		if user, err := wk.userStore.GetUserByID(*job.CreatedBy); err == nil {
+			finished.Email = user.Email
+		}
+	}
+
+	event.Dispatch(types.Event{
+		Name:    "export.finished",
+		Payload: finished,
+	})
+}
+
+// DownloadPath is the signed path of a finished export, it works without a token until it expires
+func DownloadPath(id uint, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	values := url.Values{}
+	values.Set("expires", exp)
+	values.Set("signature", signDownload(id, exp))
+	return fmt.Sprintf("/api/v1/exports/%d/download?%s", id, values.Encode())
+}
+
+// VerifyDownload checks the signature and expiry of a download link
+func VerifyDownload(id uint, expires, signature string) error {
+	if !hmac.Equal([]byte(signature), []byte(signDownload(id, expires))) {
+		return fmt.Errorf("invalid download link")
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return fmt.Errorf("download link has expired")
+	}
+	return nil
+}
+
+func signDownload(id uint, expires string) string {
+	mac := hmac.New(sha256.New, []byte(configs.Envs.JWTSecret))
+	fmt.Fprintf(mac, "export:%d:%s", id, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
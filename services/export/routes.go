@@ -0,0 +1,300 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/product"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	exporter  *Export
+	store     types.ExportJobStore
+	worker    *Worker
+	storage   storage.Storage
+	userStore types.UserStore
+}
+
+func NewHandler(exporter *Export, store types.ExportJobStore, worker *Worker, storage storage.Storage, userStore types.UserStore) *Handler {
+	return &Handler{exporter: exporter, store: store, worker: worker, storage: storage, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/export", auth.WithRoles(h.handleExport, h.userStore, "admin", "operator")).Methods("GET")
+
+	router.HandleFunc("/exports", auth.WithRoles(h.handleCreateExportJob, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/exports", auth.WithRoles(h.handleGetExportJobs, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/exports/{id:[0-9]+}", auth.WithRoles(h.handleGetExportJob, h.userStore, "admin", "operator")).Methods("GET")
+	// the signature in the link stands in for the token so browsers and emails can download directly
+	router.HandleFunc("/exports/{id:[0-9]+}/download", h.handleDownload).Methods("GET")
+}
+
+// @Summary Export products
+// @Description Streams the products matching the list filters as CSV, NDJSON or XLSX. The default columns can be imported again.
+// @Tags Products
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "Output format" Enums(csv, ndjson, xlsx) default(csv)
+// @Param columns query string false "Comma separated columns: ID, Name, SKU, Description, Price, Currency, Stock, Reserved, LowStockThreshold, Category, ImageURL, CreatedAt, UpdatedAt"
+// @Param search query string false "Search in name, description and SKUs"
+// @Param sku query string false "Exact product or variant SKU"
+// @Param category query []string false "Category slugs including their subcategories, repeated or comma separated"
+// @Param minPrice query number false "Minimum price in the base currency, e.g. 49.99"
+// @Param maxPrice query number false "Maximum price in the base currency"
+// @Param currency query string false "ISO 4217 currency to export the prices in"
+// @Param inStock query bool false "Only products in stock"
+// @Success 200 {file} file "Exported products"
+// @Failure 400 {object} map[string]string "Invalid filters, format or columns"
+// @Router /products/export [get]
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	options, err := ParseOptions(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	contentType, extension, err := ContentType(options.Format)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=products-%s%s", time.Now().Format("20060102-150405"), extension))
+
+	// push every finished batch to the client instead of holding it in the response buffer
+	flusher, _ := w.(http.Flusher)
+	options.Progress = func(int) {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// the status line is gone once rows are streaming, a late failure can only cut the download short
+	if _, err := h.exporter.Export(w, options); err != nil {
+		log.Printf("product export failed: %v", err)
+	}
+}
+
+// @Summary Create export job
+// @Description Queues a product export that is written to storage in the background, it takes the same parameters as GET /products/export.
+// @Description Poll GET /exports/{id} for progress, the creator also gets an email with the download link when it is done.
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "Output format" Enums(csv, ndjson, xlsx) default(csv)
+// @Param columns query string false "Comma separated columns, see GET /products/export"
+// @Param search query string false "Search in name, description and SKUs"
+// @Param sku query string false "Exact product or variant SKU"
+// @Param category query []string false "Category slugs including their subcategories, repeated or comma separated"
+// @Param minPrice query number false "Minimum price in the base currency, e.g. 49.99"
+// @Param maxPrice query number false "Maximum price in the base currency"
+// @Param currency query string false "ISO 4217 currency to export the prices in"
+// @Param inStock query bool false "Only products in stock"
+// @Success 202 {object} types.ExportJob
+// @Failure 400 {object} map[string]string "Invalid filters, format or columns"
+// @Router /exports [post]
+func (h *Handler) handleCreateExportJob(w http.ResponseWriter, r *http.Request) {
+	options, err := ParseOptions(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	names := make([]string, len(options.Columns))
+	for i, column := range options.Columns {
+		names[i] = column.Name
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	job := &types.ExportJob{
+		Status:    StatusQueued,
+		Format:    options.Format,
+		Columns:   names,
+		Filter:    options.Filter,
+		Currency:  options.Currency,
+		CreatedBy: &userID,
+	}
+	if err := h.store.CreateExportJob(job); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// answer first, the worker changes the job as soon as it has it
+	queued := *job
+	h.worker.Enqueue(job.ID)
+
+	utils.WriteJSON(w, http.StatusAccepted, queued)
+}
+
+// @Summary List export jobs
+// @Description Lists export jobs, newest first
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Export jobs and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /exports [get]
+func (h *Handler) handleGetExportJobs(w http.ResponseWriter, r *http.Request) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return
+		}
+		size = n
+	}
+
+	jobs, page, err := h.store.GetExportJobs(r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": jobs, "cursor": page})
+}
+
+// @Summary Get export job
+// @Description Returns the status and progress of an export job, finished jobs carry a signed download URL that expires
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {object} types.ExportJob
+// @Failure 404 {object} map[string]string "Export not found"
+// @Router /exports/{id} [get]
+func (h *Handler) handleGetExportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	job, err := h.store.GetExportJobByID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if job.Status == StatusCompleted {
+		job.DownloadURL = DownloadPath(job.ID, time.Now().Add(LinkTTL()))
+	}
+
+	utils.WriteJSON(w, http.StatusOK, job)
+}
+
+// @Summary Download export
+// @Description Downloads the file of a finished export through the signed link from GET /exports/{id}
+// @Tags Products
+// @Produce octet-stream
+// @Param id path int true "Export job ID"
+// @Param expires query int true "Expiry of the link as unix time"
+// @Param signature query string true "Signature of the link"
+// @Success 200 {file} file "Exported products"
+// @Failure 403 {object} map[string]string "Invalid or expired link"
+// @Failure 404 {object} map[string]string "Export not found or not finished"
+// @Router /exports/{id}/download [get]
+func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	if err := VerifyDownload(uint(id), r.URL.Query().Get("expires"), r.URL.Query().Get("signature")); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	job, err := h.store.GetExportJobByID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if job.Status != StatusCompleted {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("export is not finished"))
+		return
+	}
+
+	file, err := h.storage.Get(r.Context(), job.Key)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	defer file.Close()
+
+	contentType, _, _ := ContentType(job.Format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(job.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%s", path.Base(job.Key)))
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("download of export %d failed: %v", job.ID, err)
+	}
+}
+
+// ParseOptions reads the format, columns and list filters of an export request
+func ParseOptions(r *http.Request) (Options, error) {
+	values := r.URL.Query()
+
+	query, err := product.ParseListQuery(values)
+	if err != nil {
+		return Options{}, err
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		errors := err.(validator.ValidationErrors)
+		return Options{}, fmt.Errorf("invalid query: %v", errors)
+	}
+
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return Options{}, fmt.Errorf("minPrice must not be greater than maxPrice")
+	}
+
+	format := strings.ToLower(values.Get("format"))
+	if format == "" {
+		format = FormatCSV
+	}
+	if _, _, err := ContentType(format); err != nil {
+		return Options{}, err
+	}
+
+	var names []string
+	if v := values.Get("columns"); v != "" {
+		names = strings.Split(v, ",")
+	}
+	columns, err := ParseColumns(names)
+	if err != nil {
+		return Options{}, err
+	}
+
+	return Options{
+		Format:   format,
+		Columns:  columns,
+		Filter:   product.FilterFromQuery(query),
+		Currency: query.Currency,
+	}, nil
+}
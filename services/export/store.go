@@ -0,0 +1,64 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetExportJobs(cursor string, limit int) ([]types.ExportJob, *types.CursorPage, error) {
+	keyset := pagination.Keyset{Column: "id", Desc: true, Limit: limit, Cursor: cursor}
+	query := s.db.Model(&types.ExportJob{})
+
+	return pagination.Paginate(query, keyset, func(job types.ExportJob) (any, uint) {
+		return job.ID, job.ID
+	})
+}
+
+func (s *Store) GetExportJobByID(id uint) (*types.ExportJob, error) {
+	var job types.ExportJob
+	result := s.db.First(&job, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("export not found")
+		}
+		return nil, result.Error
+	}
+	return &job, nil
+}
+
+// GetUnfinishedExportJobs lists the jobs a restart interrupted, oldest first
+func (s *Store) GetUnfinishedExportJobs() ([]types.ExportJob, error) {
+	var jobs []types.ExportJob
+	result := s.db.Where("status IN ?", []string{StatusQueued, StatusRunning}).Order("id").Find(&jobs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return jobs, nil
+}
+
+func (s *Store) CreateExportJob(job *types.ExportJob) error {
+	result := s.db.Create(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) UpdateExportJob(job *types.ExportJob) error {
+	result := s.db.Save(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
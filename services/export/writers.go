@@ -0,0 +1,129 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// rowWriter turns rows of column values into one output format
+type rowWriter interface {
+	WriteHeader(names []string) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+func newRowWriter(w io.Writer, format string, columns []Column) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown format %s, use csv, ndjson or xlsx", format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteHeader(names []string) error {
+	c.record = make([]string, len(names))
+	return c.writer.Write(names)
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	for i, value := range values {
+		c.record[i] = formatValue(value)
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// ndjsonWriter writes one JSON object per line keyed by the column names
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	names   []string
+}
+
+func (n *ndjsonWriter) WriteHeader(names []string) error {
+	n.names = names
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(values []any) error {
+	row := make(map[string]any, len(values))
+	for i, value := range values {
+		row[n.names[i]] = value
+	}
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// xlsxWriter streams rows into the sheet, the workbook itself can only be written once it is complete
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (x *xlsxWriter) WriteHeader(names []string) error {
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return x.WriteRow(values)
+}
+
+func (x *xlsxWriter) WriteRow(values []any) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	cells := make([]any, len(values))
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok {
+			cells[i] = t.Format(time.RFC3339)
+			continue
+		}
+		cells[i] = value
+	}
+	return x.stream.SetRow(cell, cells)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := x.file.WriteTo(x.out)
+	return err
+}
@@ -0,0 +1,420 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/tax"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+const (
+	// progressEvery is how many rows are processed between saves of the job progress
+	progressEvery = 50
+	// maxReportedErrors keeps the report of a hopeless file readable
+	maxReportedErrors = 1000
+)
+
+// columns the importer understands, the export header plus the threshold and weight. Anything else,
+// e.g. CreatedAt and UpdatedAt, is ignored.
+const (
+	columnID                = "id"
+	columnName              = "name"
+	columnSKU               = "sku"
+	columnDescription       = "description"
+	columnPrice             = "price"
+	columnCurrency          = "currency"
+	columnStock             = "stock"
+	columnCategory          = "category"
+	columnImageURL          = "imageurl"
+	columnLowStockThreshold = "lowstockthreshold"
+	columnWeight            = "weight"
+)
+
+// payloadColumns names the column behind a ProductPayload field for the error report
+var payloadColumns = map[string]string{
+	"Name":              "Name",
+	"Description":       "Description",
+	"Amount":            "Price",
+	"Currency":          "Currency",
+	"SKU":               "SKU",
+	"ImageURL":          "ImageURL",
+	"LowStockThreshold": "LowStockThreshold",
+	"Weight":            "Weight",
+}
+
+type Importer struct {
+	products   types.ProductStore
+	categories types.CategoryStore
+	inventory  types.InventoryStore
+	warehouses types.WarehouseStore
+	jobs       types.ImportJobStore
+}
+
+func NewImporter(products types.ProductStore, categories types.CategoryStore, inventory types.InventoryStore,
+	warehouses types.WarehouseStore, jobs types.ImportJobStore) *Importer {
+	return &Importer{
+		products:   products,
+		categories: categories,
+		inventory:  inventory,
+		warehouses: warehouses,
+		jobs:       jobs,
+	}
+}
+
+// row is one parsed line of the file, fields hold only the columns the file has
+type row struct {
+	number int
+	fields map[string]string
+}
+
+func (r row) has(column string) bool {
+	_, ok := r.fields[column]
+	return ok
+}
+
+// Run imports the rows into the job's products and keeps the job's progress up to date.
+// The first row is the header. Rows fail one by one, a bad row never stops the others.
+func (i *Importer) Run(job *types.ImportJob, rows [][]string) error {
+	job.Status = StatusRunning
+	job.Errors = nil
+	if err := i.jobs.UpdateImportJob(job); err != nil {
+		return err
+	}
+
+	err := i.run(job, rows)
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = StatusCompleted
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	}
+
+	if saveErr := i.jobs.UpdateImportJob(job); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+func (i *Importer) run(job *types.ImportJob, rows [][]string) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("file is empty")
+	}
+
+	columns := header(rows[0])
+	for _, required := range []string{columnName, columnPrice} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("column %s is missing from the header", required)
+		}
+	}
+
+	job.TotalRows = len(rows) - 1
+	seenSKUs := make(map[string]int)
+
+	var warehouseID uint
+	if _, ok := columns[columnStock]; ok {
+		warehouse, err := i.defaultWarehouse()
+		if err != nil {
+			return err
+		}
+		warehouseID = warehouse
+	}
+
+	for n, values := range rows[1:] {
+		r := row{number: n + 2, fields: make(map[string]string, len(columns))}
+		blank := true
+		for name, index := range columns {
+			if index < len(values) {
+				r.fields[name] = strings.TrimSpace(values[index])
+				blank = blank && r.fields[name] == ""
+			} else {
+				r.fields[name] = ""
+			}
+		}
+
+		if blank {
+			job.TotalRows--
+			continue
+		}
+
+		created, rowErrors := i.importRow(job, r, seenSKUs, warehouseID)
+		job.ProcessedRows++
+		switch {
+		case len(rowErrors) > 0:
+			job.Failed++
+			i.report(job, rowErrors)
+		case created:
+			job.Created++
+		default:
+			job.Updated++
+		}
+
+		if job.ProcessedRows%progressEvery == 0 {
+			if err := i.jobs.UpdateImportJob(job); err != nil {
+				log.Printf("failed to save progress of import %d: %v", job.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *Importer) report(job *types.ImportJob, rowErrors []types.ImportRowError) {
+	for _, rowError := range rowErrors {
+		if len(job.Errors) == maxReportedErrors {
+			job.Errors = append(job.Errors, types.ImportRowError{Message: "too many errors, the rest is not reported"})
+		}
+		if len(job.Errors) > maxReportedErrors {
+			return
+		}
+		job.Errors = append(job.Errors, rowError)
+	}
+}
+
+// importRow validates one row and, outside of a dry run, saves it. It reports whether the row
+// creates a new product, dry runs count what would have happened.
+func (i *Importer) importRow(job *types.ImportJob, r row, seenSKUs map[string]int, warehouseID uint) (bool, []types.ImportRowError) {
+	fail := func(column, format string, args ...any) (bool, []types.ImportRowError) {
+		return false, []types.ImportRowError{{Row: r.number, Column: column, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	product, err := i.match(r)
+	if err != nil {
+		return fail("ID", "%v", err)
+	}
+	created := product == nil
+	if created {
+		product = &types.Product{}
+	}
+
+	payload, rowErrors := i.payload(r, product)
+	if len(rowErrors) > 0 {
+		return false, rowErrors
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		for _, fieldError := range err.(validator.ValidationErrors) {
+			rowErrors = append(rowErrors, types.ImportRowError{
+				Row:     r.number,
+				Column:  payloadColumns[fieldError.Field()],
+				Message: fmt.Sprintf("failed on the %s rule", fieldError.Tag()),
+			})
+		}
+		return false, rowErrors
+	}
+
+	if base := money.BaseCurrency(); !strings.EqualFold(payload.Price.Currency, base) {
+		return fail("Currency", "price has to be in the base currency %s", base)
+	}
+
+	if sku := strings.TrimSpace(payload.SKU); sku != "" {
+		if first, ok := seenSKUs[strings.ToLower(sku)]; ok {
+			return fail("SKU", "sku %s is already used in row %d", sku, first)
+		}
+		seenSKUs[strings.ToLower(sku)] = r.number
+
+		taken, err := i.products.IsSKUTaken(sku, product.ID, 0)
+		if err != nil {
+			return fail("SKU", "%v", err)
+		}
+		if taken {
+			return fail("SKU", "sku %s is already in use", sku)
+		}
+	}
+
+	var stock *int
+	if r.has(columnStock) && r.fields[columnStock] != "" {
+		value, err := strconv.Atoi(r.fields[columnStock])
+		if err != nil || value < 0 {
+			return fail("Stock", "stock must be a whole number of at least 0")
+		}
+		stock = &value
+	}
+
+	product.Name = payload.Name
+	product.Description = payload.Description
+	product.Price = money.New(payload.Price.Amount, payload.Price.Currency)
+	product.LowStockThreshold = payload.LowStockThreshold
+	product.CategoryID = payload.CategoryID
+	product.Category = nil
+	product.TaxClass = payload.TaxClass
+	if product.TaxClass == "" {
+		product.TaxClass = tax.ClassStandard
+	}
+	product.Weight = payload.Weight
+	product.Length = payload.Length
+	product.Width = payload.Width
+	product.Height = payload.Height
+	product.ImageURL = payload.ImageURL
+	product.SKU = nil
+	if sku := strings.TrimSpace(payload.SKU); sku != "" {
+		product.SKU = &sku
+	}
+
+	if job.DryRun {
+		return created, nil
+	}
+
+	if created {
+		err = i.products.CreateProduct(product)
+	} else {
+		err = i.products.UpdateProduct(product)
+	}
+	if err != nil {
+		return fail("", "%v", err)
+	}
+
+	// stock goes through the ledger as the difference to what the product holds now
+	if stock != nil && *stock != product.Stock {
+		reference := fmt.Sprintf("import #%d", job.ID)
+		difference := *stock - product.Stock
+		if difference > 0 {
+			_, err = i.inventory.Receive(warehouseID, product.ID, nil, difference, reference, "", job.CreatedBy)
+		} else {
+			_, err = i.inventory.Adjust(warehouseID, product.ID, nil, difference, reference, job.CreatedBy)
+		}
+		if err != nil {
+			return fail("Stock", "product was saved but its stock was not booked: %v", err)
+		}
+	}
+
+	return created, nil
+}
+
+// match finds the product a row updates, by ID first and SKU second. Nil means the row creates one.
+func (i *Importer) match(r row) (*types.Product, error) {
+	if id := r.fields[columnID]; id != "" {
+		parsed, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %s", id)
+		}
+		product, err := i.products.GetProductByID(uint(parsed))
+		if err != nil {
+			return nil, fmt.Errorf("product %d not found", parsed)
+		}
+		return product, nil
+	}
+
+	if sku := r.fields[columnSKU]; sku != "" {
+		if product, err := i.products.GetProductBySKU(sku); err == nil {
+			return product, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// payload fills a ProductPayload from the row, columns the file doesn't have keep the product's values
+func (i *Importer) payload(r row, product *types.Product) (types.ProductPayload, []types.ImportRowError) {
+	payload := types.ProductPayload{
+		Name:              product.Name,
+		Description:       product.Description,
+		Price:             types.MoneyPayload{Amount: product.Price.Amount, Currency: product.Price.Currency},
+		LowStockThreshold: product.LowStockThreshold,
+		CategoryID:        product.CategoryID,
+		TaxClass:          product.TaxClass,
+		Weight:            product.Weight,
+		Length:            product.Length,
+		Width:             product.Width,
+		Height:            product.Height,
+		ImageURL:          product.ImageURL,
+	}
+	if product.SKU != nil {
+		payload.SKU = *product.SKU
+	}
+	if payload.Price.Currency == "" {
+		payload.Price.Currency = money.BaseCurrency()
+	}
+
+	var rowErrors []types.ImportRowError
+	fail := func(column, format string, args ...any) {
+		rowErrors = append(rowErrors, types.ImportRowError{Row: r.number, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.has(columnName) {
+		payload.Name = r.fields[columnName]
+	}
+	if r.has(columnDescription) {
+		payload.Description = r.fields[columnDescription]
+	}
+	if r.has(columnSKU) {
+		payload.SKU = r.fields[columnSKU]
+	}
+	if r.has(columnImageURL) {
+		payload.ImageURL = r.fields[columnImageURL]
+	}
+	if r.has(columnCurrency) && r.fields[columnCurrency] != "" {
+		payload.Price.Currency = strings.ToUpper(r.fields[columnCurrency])
+	}
+
+	if value := r.fields[columnPrice]; value != "" {
+		amount, err := money.ParseAmount(value, payload.Price.Currency)
+		if err != nil {
+			fail("Price", "%v", err)
+		} else {
+			payload.Price.Amount = amount
+		}
+	} else if product.ID == 0 {
+		fail("Price", "price is required")
+	}
+
+	if r.has(columnLowStockThreshold) && r.fields[columnLowStockThreshold] != "" {
+		threshold, err := strconv.Atoi(r.fields[columnLowStockThreshold])
+		if err != nil {
+			fail("LowStockThreshold", "must be a whole number")
+		} else {
+			payload.LowStockThreshold = threshold
+		}
+	}
+
+	if r.has(columnWeight) && r.fields[columnWeight] != "" {
+		weight, err := strconv.Atoi(r.fields[columnWeight])
+		if err != nil {
+			fail("Weight", "must be a whole number of grams")
+		} else {
+			payload.Weight = weight
+		}
+	}
+
+	if r.has(columnCategory) {
+		payload.CategoryID = nil
+		if name := r.fields[columnCategory]; name != "" {
+			category, err := i.categories.GetCategoryBySlug(utils.Slugify(name))
+			if err != nil {
+				fail("Category", "unknown category %s", name)
+			} else {
+				payload.CategoryID = &category.ID
+			}
+		}
+	}
+
+	return payload, rowErrors
+}
+
+// defaultWarehouse is where imported stock is booked, the active warehouse allocated first
+func (i *Importer) defaultWarehouse() (uint, error) {
+	warehouses, err := i.warehouses.GetWarehouses()
+	if err != nil {
+		return 0, err
+	}
+	for _, warehouse := range warehouses {
+		if warehouse.Active {
+			return warehouse.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("stock can't be imported without an active warehouse")
+}
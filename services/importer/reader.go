@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ReadRows detects whether the file is CSV or XLSX from its content and returns all rows,
+// the header included. Only the first sheet of a workbook is read.
+func ReadRows(data []byte) ([][]string, string, error) {
+	detected := mimetype.Detect(data)
+	switch {
+	case detected.Is("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"):
+		rows, err := readXLSX(data)
+		return rows, FormatXLSX, err
+	case detected.Is("text/csv"), detected.Is("text/plain"):
+		rows, err := readCSV(data)
+		return rows, FormatCSV, err
+	default:
+		return nil, "", fmt.Errorf("unsupported file type %s, upload CSV or XLSX", detected.String())
+	}
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %v", err)
+	}
+	return rows, nil
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	workbook, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx: %v", err)
+	}
+	defer workbook.Close()
+
+	sheets := workbook.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("invalid xlsx: workbook has no sheets")
+	}
+
+	rows, err := workbook.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx: %v", err)
+	}
+	return rows, nil
+}
+
+// header maps the lower cased column names to their index
+func header(row []string) map[string]int {
+	columns := make(map[string]int, len(row))
+	for i, name := range row {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok && name != "" {
+			columns[name] = i
+		}
+	}
+	return columns
+}
@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.ImportJobStore
+	importer  *Importer
+	userStore types.UserStore
+}
+
+func NewHandler(store types.ImportJobStore, importer *Importer, userStore types.UserStore) *Handler {
+	return &Handler{store: store, importer: importer, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/import", auth.WithRoles(h.handleImport, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/imports", auth.WithRoles(h.handleGetImports, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/imports/{id:[0-9]+}", auth.WithRoles(h.handleGetImport, h.userStore, "admin", "operator")).Methods("GET")
+}
+
+// MaxFileSize is the upload limit for import files in bytes from IMPORT_MAX_SIZE, 50 MB by default
+func MaxFileSize() int64 {
+	if size, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_SIZE"), 10, 64); err == nil && size > 0 {
+		return size
+	}
+	return 50 << 20
+}
+
+// SyncRows is the number of rows up to which an import finishes within the request, from IMPORT_SYNC_ROWS.
+// Larger files run in the background and are followed through GET /imports/{id}.
+func SyncRows() int {
+	if rows, err := strconv.Atoi(os.Getenv("IMPORT_SYNC_ROWS")); err == nil && rows >= 0 {
+		return rows
+	}
+	return 200
+}
+
+// @Summary Import products
+// @Description Creates or updates products from a CSV or XLSX file with the export header. Rows with an ID or a known SKU update that product, all others create one.
+// @Description Small files are imported right away, larger ones run in the background. With dryRun every row is validated and nothing is saved.
+// @Tags Products
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file"
+// @Param dryRun formData bool false "Only validate the rows"
+// @Success 200 {object} types.ImportJob "Finished import with its report"
+// @Success 202 {object} types.ImportJob "Import running in the background"
+// @Failure 400 {object} map[string]string "Unreadable file"
+// @Router /products/import [post]
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	maxSize := MaxFileSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid upload: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("no file uploaded, send it in the file field"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("file is larger than %d bytes", maxSize))
+		return
+	}
+
+	dryRun := false
+	if value := r.FormValue("dryRun"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("dryRun must be true or false"))
+			return
+		}
+	}
+
+	rows, format, err := ReadRows(data)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	job := &types.ImportJob{
+		Status:    StatusQueued,
+		DryRun:    dryRun,
+		FileName:  fileHeader.Filename,
+		Format:    format,
+		TotalRows: max(len(rows)-1, 0),
+		CreatedBy: &userID,
+	}
+	if err := h.store.CreateImportJob(job); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if job.TotalRows > SyncRows() {
+		// the worker owns the job from here on, answer with a copy
+		queued := *job
+		go func() {
+			if err := h.importer.Run(job, rows); err != nil {
+				log.Printf("import %d failed: %v", job.ID, err)
+			}
+		}()
+
+		utils.WriteJSON(w, http.StatusAccepted, queued)
+		return
+	}
+
+	// the report is what the caller wants, a file level error lands in job.Error
+	h.importer.Run(job, rows)
+	utils.WriteJSON(w, http.StatusOK, job)
+}
+
+// @Summary List imports
+// @Description Lists product imports, newest first, without their error reports
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Imports and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /imports [get]
+func (h *Handler) handleGetImports(w http.ResponseWriter, r *http.Request) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return
+		}
+		size = n
+	}
+
+	jobs, page, err := h.store.GetImportJobs(r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": jobs, "cursor": page})
+}
+
+// @Summary Get import
+// @Description Returns the progress of an import and its per row error report
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Import ID"
+// @Success 200 {object} types.ImportJob
+// @Failure 404 {object} map[string]string "Import not found"
+// @Router /imports/{id} [get]
+func (h *Handler) handleGetImport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	job, err := h.store.GetImportJobByID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetImportJobs(cursor string, limit int) ([]types.ImportJob, *types.CursorPage, error) {
+	keyset := pagination.Keyset{Column: "id", Desc: true, Limit: limit, Cursor: cursor}
+	query := s.db.Model(&types.ImportJob{}).Omit("errors")
+
+	return pagination.Paginate(query, keyset, func(job types.ImportJob) (any, uint) {
+		return job.ID, job.ID
+	})
+}
+
+func (s *Store) GetImportJobByID(id uint) (*types.ImportJob, error) {
+	var job types.ImportJob
+	result := s.db.First(&job, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("import not found")
+		}
+		return nil, result.Error
+	}
+	return &job, nil
+}
+
+func (s *Store) CreateImportJob(job *types.ImportJob) error {
+	result := s.db.Create(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) UpdateImportJob(job *types.ImportJob) error {
+	result := s.db.Save(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// FailInterruptedJobs marks jobs that were still queued or running when the server stopped as failed
+func FailInterruptedJobs(db *gorm.DB) error {
+	result := db.Model(&types.ImportJob{}).
+		Where("status IN ?", []string{StatusQueued, StatusRunning}).
+		Updates(map[string]any{"status": StatusFailed, "error": "interrupted by a restart, please upload the file again"})
+	return result.Error
+}
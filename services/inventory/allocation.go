@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// AllocatePriority picks the warehouse with the lowest priority number that can fill the quantity
+	AllocatePriority = "priority"
+	// AllocateNearest picks the warehouse closest to the destination, by distance where positions are known and
+	// else by region and country, ties fall back to priority
+	AllocateNearest = "nearest"
+	// AllocateMostStock picks the warehouse holding the most available stock
+	AllocateMostStock = "stock"
+)
+
+const earthRadiusKm = 6371.0
+
+// AllocationRule is the rule from STOCK_ALLOCATION, priority when it is unset or unknown
+func AllocationRule() string {
+	switch rule := os.Getenv("STOCK_ALLOCATION"); rule {
+	case AllocatePriority, AllocateNearest, AllocateMostStock:
+		return rule
+	case "":
+		return AllocatePriority
+	default:
+		log.Printf("unknown STOCK_ALLOCATION %q, allocating by priority", rule)
+		return AllocatePriority
+	}
+}
+
+type candidate struct {
+	WarehouseID uint
+	Priority    int
+	Country     string
+	Region      string
+	Latitude    *float64
+	Longitude   *float64
+	Stock       int
+}
+
+// Allocate lists the active warehouses that can fill the whole quantity, best first by the rule
+func Allocate(tx *gorm.DB, productID uint, variantID *uint, quantity int, destination *types.Destination, rule string) ([]uint, error) {
+	var candidates []candidate
+	query := tx.Table("stock_levels").
+		Select("stock_levels.warehouse_id, warehouses.priority, warehouses.country, warehouses.region, warehouses.latitude, warehouses.longitude, stock_levels.stock").
+		Joins("JOIN warehouses ON warehouses.id = stock_levels.warehouse_id").
+		Where("warehouses.active = ? AND stock_levels.product_id = ? AND stock_levels.stock >= ?", true, productID, quantity)
+	if variantID != nil {
+		query = query.Where("stock_levels.variant_id = ?", *variantID)
+	} else {
+		query = query.Where("stock_levels.variant_id IS NULL")
+	}
+	if err := query.Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrInsufficientStock
+	}
+
+	byPriority := func(a, b candidate) bool {
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.WarehouseID < b.WarehouseID
+	}
+
+	switch rule {
+	case AllocateNearest:
+		distance := func(c candidate) float64 {
+			if destination == nil || destination.Point == nil || c.Latitude == nil || c.Longitude == nil {
+				return math.Inf(1)
+			}
+			return Distance(types.GeoPoint{Latitude: *c.Latitude, Longitude: *c.Longitude}, *destination.Point)
+		}
+		// 0 is the same region, 1 the same country and 2 anywhere else
+		locality := func(c candidate) int {
+			if destination == nil || c.Country == "" || !strings.EqualFold(c.Country, destination.Country) {
+				return 2
+			}
+			if c.Region != "" && strings.EqualFold(c.Region, destination.Region) {
+				return 0
+			}
+			return 1
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			di, dj := distance(candidates[i]), distance(candidates[j])
+			if di != dj {
+				return di < dj
+			}
+			if li, lj := locality(candidates[i]), locality(candidates[j]); li != lj {
+				return li < lj
+			}
+			return byPriority(candidates[i], candidates[j])
+		})
+	case AllocateMostStock:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].Stock != candidates[j].Stock {
+				return candidates[i].Stock > candidates[j].Stock
+			}
+			return byPriority(candidates[i], candidates[j])
+		})
+	default:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return byPriority(candidates[i], candidates[j])
+		})
+	}
+
+	warehouses := make([]uint, len(candidates))
+	for i, c := range candidates {
+		warehouses[i] = c.WarehouseID
+	}
+	return warehouses, nil
+}
+
+// Distance is the great circle distance between two points in kilometers
+func Distance(a, b types.GeoPoint) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
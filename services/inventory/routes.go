@@ -0,0 +1,152 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.InventoryStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.InventoryStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/stock-movements", auth.WithRoles(h.handleGetStockMovements, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/stock", auth.WithRoles(h.handleCreateStockMovement, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/stock-transfers", auth.WithRoles(h.handleTransferStock, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary Stock movements
+// @Description Lists the inventory ledger of a product, newest first
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(50)
+// @Success 200 {object} map[string]interface{} "Movements and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /products/{id}/stock-movements [get]
+func (h *Handler) handleGetStockMovements(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	size := 50
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 200"))
+			return
+		}
+		size = n
+	}
+
+	movements, page, err := h.store.GetStockMovements(uint(id), r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": movements, "cursor": page})
+}
+
+// @Summary Record stock
+// @Description Books a receipt of goods or a manual adjustment of one warehouse into the inventory ledger
+// @Tags Inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param stockMovementPayload body types.StockMovementPayload true "Stock movement payload"
+// @Success 201 {object} types.StockMovement
+// @Failure 400 {object} map[string]string "Invalid movement or insufficient stock"
+// @Router /products/{id}/stock [post]
+func (h *Handler) handleCreateStockMovement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	var payload types.StockMovementPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	var movement *types.StockMovement
+	if payload.Type == MovementReceipt {
+		movement, err = h.store.Receive(payload.WarehouseID, uint(id), payload.VariantID, payload.Quantity, payload.Reference, payload.Note, &userID)
+	} else {
+		movement, err = h.store.Adjust(payload.WarehouseID, uint(id), payload.VariantID, payload.Quantity, payload.Note, &userID)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, movement)
+}
+
+// @Summary Transfer stock
+// @Description Moves available stock of a product or variant from one warehouse to another
+// @Tags Inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param stockTransferPayload body types.StockTransferPayload true "Stock transfer payload"
+// @Success 201 {array} types.StockMovement
+// @Failure 400 {object} map[string]string "Invalid transfer or insufficient stock"
+// @Router /stock-transfers [post]
+func (h *Handler) handleTransferStock(w http.ResponseWriter, r *http.Request) {
+	var payload types.StockTransferPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	movements, err := h.store.Transfer(payload.FromWarehouseID, payload.ToWarehouseID, payload.ProductID, payload.VariantID,
+		payload.Quantity, payload.Reference, payload.Note, &userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, movements)
+}
@@ -0,0 +1,595 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	MovementReceipt     = "receipt"
+	MovementAdjustment  = "adjustment"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+	MovementShipment    = "shipment"
+	MovementTransferOut = "transfer_out"
+	MovementTransferIn  = "transfer_in"
+	MovementReturn      = "return"
+
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationShipped  = "shipped"
+	ReservationExpired  = "expired"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Receive(warehouseID, productID uint, variantID *uint, quantity int, reference, note string, userID *int) (*types.StockMovement, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("received quantity must be positive")
+	}
+
+	var movement *types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = apply(tx, types.StockMovement{
+			WarehouseID: warehouseID,
+			ProductID:   productID,
+			VariantID:   variantID,
+			Type:        MovementReceipt,
+			Quantity:    quantity,
+			Reference:   reference,
+			Note:        note,
+			CreatedBy:   userID,
+		}, quantity, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	CheckStock(s.db, movement)
+	return movement, nil
+}
+
+// Adjust corrects the available stock, e.g. after a stock count, it never lets stock drop below zero
+func (s *Store) Adjust(warehouseID, productID uint, variantID *uint, quantity int, note string, userID *int) (*types.StockMovement, error) {
+	if quantity == 0 {
+		return nil, fmt.Errorf("adjustment can't be zero")
+	}
+
+	var movement *types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = apply(tx, types.StockMovement{
+			WarehouseID: warehouseID,
+			ProductID:   productID,
+			VariantID:   variantID,
+			Type:        MovementAdjustment,
+			Quantity:    quantity,
+			Note:        note,
+			CreatedBy:   userID,
+		}, quantity, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	CheckStock(s.db, movement)
+	return movement, nil
+}
+
+// Transfer moves available stock from one warehouse to another, the totals of the product don't change
+func (s *Store) Transfer(fromID, toID, productID uint, variantID *uint, quantity int, reference, note string, userID *int) ([]types.StockMovement, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("transferred quantity must be positive")
+	}
+	if fromID == toID {
+		return nil, fmt.Errorf("can't transfer stock to the same warehouse")
+	}
+
+	var movements []types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		out, err := apply(tx, types.StockMovement{
+			WarehouseID: fromID,
+			ProductID:   productID,
+			VariantID:   variantID,
+			Type:        MovementTransferOut,
+			Quantity:    quantity,
+			Reference:   reference,
+			Note:        note,
+			CreatedBy:   userID,
+		}, -quantity, 0)
+		if err != nil {
+			return err
+		}
+
+		in, err := apply(tx, types.StockMovement{
+			WarehouseID: toID,
+			ProductID:   productID,
+			VariantID:   variantID,
+			Type:        MovementTransferIn,
+			Quantity:    quantity,
+			Reference:   reference,
+			Note:        note,
+			CreatedBy:   userID,
+		}, quantity, 0)
+		if err != nil {
+			return err
+		}
+
+		movements = []types.StockMovement{*out, *in}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+func (s *Store) Reserve(request types.ReservationRequest) (*types.StockReservation, error) {
+	var reservation *types.StockReservation
+	var movement *types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservation, movement, err = ReserveTx(tx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	CheckStock(s.db, movement)
+	return reservation, nil
+}
+
+func (s *Store) Release(reservationID uint) error {
+	var movement *types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = ReleaseTx(tx, reservationID, ReservationReleased)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	CheckStock(s.db, movement)
+	return nil
+}
+
+func (s *Store) Ship(reservationID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := ShipTx(tx, reservationID)
+		return err
+	})
+}
+
+// ExpireReservations gives the stock of every overdue active reservation back
+func (s *Store) ExpireReservations() (int, error) {
+	var reservations []types.StockReservation
+	result := s.db.
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", ReservationActive, time.Now()).
+		Find(&reservations)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	expired := 0
+	for _, reservation := range reservations {
+		var movement *types.StockMovement
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			movement, err = ReleaseTx(tx, reservation.ID, ReservationExpired)
+			return err
+		})
+		if err != nil {
+			return expired, err
+		}
+		if movement == nil {
+			continue
+		}
+
+		CheckStock(s.db, movement)
+		expired++
+		event.Dispatch(types.Event{
+			Name:    "inventory.reservation_expired",
+			Payload: reservation,
+		})
+	}
+
+	return expired, nil
+}
+
+func (s *Store) GetStockMovements(productID uint, cursor string, limit int) ([]types.StockMovement, *types.CursorPage, error) {
+	keyset := pagination.Keyset{Column: "id", Desc: true, Filter: productID, Limit: limit, Cursor: cursor}
+	query := s.db.Model(&types.StockMovement{}).Where("product_id = ?", productID)
+
+	return pagination.Paginate(query, keyset, func(m types.StockMovement) (any, uint) {
+		return m.ID, m.ID
+	})
+}
+
+// ReserveTx takes stock for a reservation inside the caller's transaction. The decrement only
+// happens when enough stock is available so concurrent reservations can't oversell.
+// Without a warehouse in the request the allocation rules pick one that can fill the whole quantity.
+func ReserveTx(tx *gorm.DB, request types.ReservationRequest) (*types.StockReservation, *types.StockMovement, error) {
+	if request.Quantity <= 0 {
+		return nil, nil, fmt.Errorf("reserved quantity must be positive")
+	}
+
+	var warehouses []uint
+	if request.WarehouseID != nil {
+		warehouses = []uint{*request.WarehouseID}
+	} else {
+		var err error
+		warehouses, err = Allocate(tx, request.ProductID, request.VariantID, request.Quantity, request.Destination, AllocationRule())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	reservation := &types.StockReservation{
+		ProductID: request.ProductID,
+		VariantID: request.VariantID,
+		Quantity:  request.Quantity,
+		Status:    ReservationActive,
+		Reference: request.Reference,
+	}
+	if request.TTL > 0 {
+		expiresAt := time.Now().Add(request.TTL)
+		reservation.ExpiresAt = &expiresAt
+	}
+
+	if err := tx.Create(reservation).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// a candidate can lose its stock to a concurrent reservation, the next one is tried then
+	for _, warehouseID := range warehouses {
+		movement, err := apply(tx, types.StockMovement{
+			WarehouseID:   warehouseID,
+			ProductID:     request.ProductID,
+			VariantID:     request.VariantID,
+			Type:          MovementReservation,
+			Quantity:      request.Quantity,
+			ReservationID: &reservation.ID,
+			Reference:     request.Reference,
+		}, -request.Quantity, request.Quantity)
+		if errors.Is(err, ErrInsufficientStock) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		reservation.WarehouseID = warehouseID
+		if err := tx.Model(reservation).Update("warehouse_id", warehouseID).Error; err != nil {
+			return nil, nil, err
+		}
+		return reservation, movement, nil
+	}
+
+	return nil, nil, ErrInsufficientStock
+}
+
+// ReleaseTx puts the stock of an active reservation back, status tells why it was released.
+// Releasing a reservation that is no longer active does nothing and returns no movement.
+func ReleaseTx(tx *gorm.DB, reservationID uint, status string) (*types.StockMovement, error) {
+	reservation, err := closeReservation(tx, reservationID, status)
+	if err != nil || reservation == nil {
+		return nil, err
+	}
+
+	return apply(tx, types.StockMovement{
+		WarehouseID:   reservation.WarehouseID,
+		ProductID:     reservation.ProductID,
+		VariantID:     reservation.VariantID,
+		Type:          MovementRelease,
+		Quantity:      reservation.Quantity,
+		ReservationID: &reservation.ID,
+		Reference:     reservation.Reference,
+		Note:          status,
+	}, reservation.Quantity, -reservation.Quantity)
+}
+
+// KeepTx takes the expiry off an active reservation, e.g. once its order is paid. It reports false
+// when the reservation was released or expired already.
+func KeepTx(tx *gorm.DB, reservationID uint) (bool, error) {
+	result := tx.Model(&types.StockReservation{}).
+		Where("id = ? AND status = ?", reservationID, ReservationActive).
+		Update("expires_at", nil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ShipTx turns an active reservation into a shipment, the units leave the warehouse for good
+func ShipTx(tx *gorm.DB, reservationID uint) (*types.StockMovement, error) {
+	reservation, err := closeReservation(tx, reservationID, ReservationShipped)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, fmt.Errorf("reservation %d is not active", reservationID)
+	}
+
+	return apply(tx, types.StockMovement{
+		WarehouseID:   reservation.WarehouseID,
+		ProductID:     reservation.ProductID,
+		VariantID:     reservation.VariantID,
+		Type:          MovementShipment,
+		Quantity:      reservation.Quantity,
+		ReservationID: &reservation.ID,
+		Reference:     reservation.Reference,
+	}, 0, -reservation.Quantity)
+}
+
+// ReturnTx puts units of a shipped reservation that came back, e.g. through a return, into the
+// warehouse they were shipped from
+func ReturnTx(tx *gorm.DB, reservationID uint, quantity int, reference string, userID *int) (*types.StockMovement, error) {
+	var reservation types.StockReservation
+	if err := tx.First(&reservation, reservationID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("reservation not found")
+		}
+		return nil, err
+	}
+	if reservation.Status != ReservationShipped {
+		return nil, fmt.Errorf("reservation %d was not shipped", reservationID)
+	}
+	if quantity <= 0 || quantity > reservation.Quantity {
+		return nil, fmt.Errorf("can't return %d of the %d units shipped", quantity, reservation.Quantity)
+	}
+
+	return apply(tx, types.StockMovement{
+		WarehouseID:   reservation.WarehouseID,
+		ProductID:     reservation.ProductID,
+		VariantID:     reservation.VariantID,
+		Type:          MovementReturn,
+		Quantity:      quantity,
+		ReservationID: &reservation.ID,
+		Reference:     reference,
+		CreatedBy:     userID,
+	}, quantity, 0)
+}
+
+// CheckStock dispatches inventory.low when a movement took the stock below the product's threshold and
+// inventory.restocked when the movements took a product, or all of its variants together, from none to some stock.
+// Call it after the transaction holding the movements has been committed.
+func CheckStock(db *gorm.DB, movements ...*types.StockMovement) {
+	checkLowStock(db, movements)
+	checkRestocked(db, movements)
+}
+
+func checkLowStock(db *gorm.DB, movements []*types.StockMovement) {
+	for _, movement := range movements {
+		if movement == nil {
+			continue
+		}
+
+		var product types.Product
+		if err := db.Select("id, low_stock_threshold").First(&product, movement.ProductID).Error; err != nil {
+			continue
+		}
+
+		// the threshold is about the stock over all warehouses, not the balance of the movement
+		var available int
+		if err := target(db, movement.ProductID, movement.VariantID).Select("stock").Scan(&available).Error; err != nil {
+			continue
+		}
+
+		threshold := product.LowStockThreshold
+		before := available + decrease(movement)
+		if threshold <= 0 || available >= threshold || before < threshold {
+			continue
+		}
+
+		event.Dispatch(types.Event{
+			Name: "inventory.low",
+			Payload: types.LowStockEvent{
+				ProductID: movement.ProductID,
+				VariantID: movement.VariantID,
+				Available: available,
+				Threshold: threshold,
+			},
+		})
+	}
+}
+
+// checkRestocked compares the stock of each product with what it was before all of the movements, one
+// transaction can move the same product more than once, e.g. when a cancelled order releases two lines.
+// The stock of a product with variants is the stock of all of its variants together.
+func checkRestocked(db *gorm.DB, movements []*types.StockMovement) {
+	changes := make(map[uint]int)
+	variants := make(map[uint]bool)
+	var products []uint
+	for _, movement := range movements {
+		if movement == nil {
+			continue
+		}
+		if _, ok := changes[movement.ProductID]; !ok {
+			products = append(products, movement.ProductID)
+		}
+		changes[movement.ProductID] += change(movement)
+		if movement.VariantID != nil {
+			variants[movement.ProductID] = true
+		}
+	}
+
+	for _, productID := range products {
+		query := target(db, productID, nil).Select("stock")
+		if variants[productID] {
+			query = db.Model(&types.ProductVariant{}).Where("product_id = ?", productID).Select("COALESCE(SUM(stock), 0)")
+		}
+		var available int
+		if err := query.Scan(&available).Error; err != nil {
+			continue
+		}
+		if available <= 0 || available-changes[productID] > 0 {
+			continue
+		}
+
+		event.Dispatch(types.Event{
+			Name:    "inventory.restocked",
+			Payload: types.RestockedEvent{ProductID: productID, Available: available},
+		})
+	}
+}
+
+// change is how a movement moved the available stock
+func change(movement *types.StockMovement) int {
+	switch movement.Type {
+	case MovementReceipt, MovementRelease, MovementTransferIn, MovementReturn, MovementAdjustment:
+		return movement.Quantity
+	case MovementReservation, MovementTransferOut:
+		return -movement.Quantity
+	}
+	return 0
+}
+
+// decrease is how much available stock a movement took away
+func decrease(movement *types.StockMovement) int {
+	switch movement.Type {
+	case MovementReservation:
+		return movement.Quantity
+	case MovementAdjustment:
+		if movement.Quantity < 0 {
+			return -movement.Quantity
+		}
+	}
+	return 0
+}
+
+// closeReservation moves an active reservation to its final status, nil means it wasn't active anymore
+func closeReservation(tx *gorm.DB, reservationID uint, status string) (*types.StockReservation, error) {
+	query := tx.Model(&types.StockReservation{}).Where("id = ? AND status = ?", reservationID, ReservationActive)
+	if status == ReservationExpired {
+		// a reservation kept meanwhile doesn't expire anymore
+		query = query.Where("expires_at IS NOT NULL AND expires_at < ?", time.Now())
+	}
+	result := query.Update("status", status)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var reservation types.StockReservation
+	if err := tx.First(&reservation, reservationID).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// apply changes the stock level of the warehouse together with the totals of the product or variant
+// and writes the ledger entry. The level is only touched when its counters stay non-negative.
+func apply(tx *gorm.DB, movement types.StockMovement, available, reserved int) (*types.StockMovement, error) {
+	var count int64
+	if err := target(tx, movement.ProductID, movement.VariantID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	if err := tx.Model(&types.Warehouse{}).Where("id = ?", movement.WarehouseID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("warehouse not found")
+	}
+
+	level := types.StockLevel{
+		WarehouseID: movement.WarehouseID,
+		ProductID:   movement.ProductID,
+		VariantID:   movement.VariantID,
+	}
+	if err := levelQuery(tx, movement.WarehouseID, movement.ProductID, movement.VariantID).FirstOrCreate(&level).Error; err != nil {
+		return nil, err
+	}
+
+	result := tx.Model(&types.StockLevel{}).
+		Where("id = ? AND stock + ? >= 0 AND reserved + ? >= 0", level.ID, available, reserved).
+		Updates(map[string]any{
+			"stock":    gorm.Expr("stock + ?", available),
+			"reserved": gorm.Expr("reserved + ?", reserved),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInsufficientStock
+	}
+
+	result = target(tx, movement.ProductID, movement.VariantID).
+		Updates(map[string]any{
+			"stock":    gorm.Expr("stock + ?", available),
+			"reserved": gorm.Expr("reserved + ?", reserved),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := tx.First(&level, level.ID).Error; err != nil {
+		return nil, err
+	}
+
+	movement.Available = level.Stock
+	movement.Reserved = level.Reserved
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
+// target selects the product or variant row that carries the totals
+func target(tx *gorm.DB, productID uint, variantID *uint) *gorm.DB {
+	if variantID != nil {
+		return tx.Model(&types.ProductVariant{}).Where("id = ? AND product_id = ?", *variantID, productID)
+	}
+	return tx.Model(&types.Product{}).Where("id = ?", productID)
+}
+
+func levelQuery(tx *gorm.DB, warehouseID, productID uint, variantID *uint) *gorm.DB {
+	query := tx.Where("warehouse_id = ? AND product_id = ?", warehouseID, productID)
+	if variantID != nil {
+		return query.Where("variant_id = ?", *variantID)
+	}
+	return query.Where("variant_id IS NULL")
+}
+
+// ExpireReservationsEvery runs ExpireReservations on a fixed interval, it is meant to run in its own goroutine
+func ExpireReservationsEvery(store types.InventoryStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := store.ExpireReservations()
+		if err != nil {
+			log.Printf("failed to expire stock reservations: %v", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("expired %d stock reservations", expired)
+		}
+	}
+}
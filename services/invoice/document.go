@@ -0,0 +1,245 @@
+package invoice
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// document is what an invoice or credit note shows, render lays it out on A4 pages
+type document struct {
+	title   string
+	details [][2]string
+	billTo  *types.OrderAddress
+	shipTo  *types.OrderAddress
+	rows    []row
+	totals  []row
+	notes   []string
+}
+
+type row struct {
+	description string
+	quantity    string
+	unit        string
+	amount      string
+	bold        bool
+}
+
+func invoiceDocument(invoice *types.Invoice, o *types.Order) document {
+	d := document{
+		title:   "Invoice",
+		details: header(invoice, o),
+		billTo:  o.BillingAddress,
+		shipTo:  o.ShippingAddress,
+	}
+	d.rows, d.totals = lines(o, 1)
+	if o.TaxInclusive {
+		d.notes = append(d.notes, "Prices include tax.")
+	}
+	d.notes = append(d.notes, "Thank you for your order.")
+	return d
+}
+
+// creditNoteDocument repeats the lines of the invoice with negative amounts for full refunds, partial
+// refunds are a single line
+func creditNoteDocument(note, invoice *types.Invoice, o *types.Order, full bool) document {
+	d := document{
+		title:   "Credit note",
+		details: append(header(note, o), [2]string{"Credits invoice", invoice.Number}),
+		billTo:  o.BillingAddress,
+	}
+
+	if full {
+		d.rows, d.totals = lines(o, -1)
+	} else {
+		d.rows = []row{{
+			description: fmt.Sprintf("Partial refund of order #%d", o.ID),
+			quantity:    "1",
+			unit:        format(note.Total),
+			amount:      format(note.Total),
+		}}
+		if note.Tax.Amount != 0 {
+			d.totals = append(d.totals, row{description: "Tax included", amount: format(note.Tax)})
+		}
+		d.totals = append(d.totals, row{description: "Total", amount: format(note.Total), bold: true})
+	}
+	d.notes = append(d.notes, fmt.Sprintf("The amount is refunded through the payment method of order #%d.", o.ID))
+	return d
+}
+
+func header(invoice *types.Invoice, o *types.Order) [][2]string {
+	return [][2]string{
+		{"Number", invoice.Number},
+		{"Date", invoice.IssuedAt.Format("2006-01-02")},
+		{"Order", fmt.Sprintf("#%d of %s", o.ID, o.CreatedAt.Format("2006-01-02"))},
+	}
+}
+
+// lines lists the items and the totals of the order, sign -1 credits them
+func lines(o *types.Order, sign int64) ([]row, []row) {
+	signed := func(m types.Money) string {
+		return format(money.New(sign*m.Amount, m.Currency))
+	}
+
+	rows := make([]row, 0, len(o.Items))
+	for _, item := range o.Items {
+		description := item.Name
+		if item.SKU != "" {
+			description += " (" + item.SKU + ")"
+		}
+		rows = append(rows, row{
+			description: description,
+			quantity:    fmt.Sprintf("%d", item.Quantity),
+			unit:        signed(item.UnitPrice),
+			amount:      signed(item.Total),
+		})
+	}
+
+	totals := []row{{description: "Subtotal", amount: signed(o.Subtotal)}}
+	if o.Discount.Amount != 0 {
+		discount := "Discount"
+		if o.CouponCode != "" {
+			discount += " " + o.CouponCode
+		}
+		totals = append(totals, row{description: discount, amount: signed(money.New(-o.Discount.Amount, o.Discount.Currency))})
+	}
+	if o.ShippingMethodID != nil || o.Shipping.Amount != 0 {
+		totals = append(totals, row{description: "Shipping", amount: signed(o.Shipping)})
+	}
+	for _, tax := range o.Taxes {
+		description := fmt.Sprintf("%s %s%%", tax.Name, tax.Rate)
+		if o.TaxInclusive {
+			description = "incl. " + description
+		}
+		totals = append(totals, row{description: description, amount: signed(money.New(tax.Amount, o.Total.Currency))})
+	}
+	totals = append(totals, row{description: "Total", amount: signed(o.Total), bold: true})
+	return rows, totals
+}
+
+func format(m types.Money) string {
+	return money.Format(m) + " " + m.Currency
+}
+
+// columns of the item table, the numbers are right aligned at their x
+const (
+	left     = 50.0
+	quantity = 370.0
+	unit     = 460.0
+	right    = pageWidth - 50
+	bottom   = 90.0
+)
+
+func render(seller Seller, d document) []byte {
+	p := newPDF()
+
+	// seller on the left, title and details on the right
+	y := pageHeight - 60
+	p.Text(left, y, 12, true, seller.Name)
+	for _, line := range slices.Concat(seller.Address, taxID(seller)) {
+		y -= 13
+		p.Text(left, y, 9, false, line)
+	}
+
+	y = pageHeight - 60
+	p.TextRight(right, y, 20, true, d.title)
+	y -= 8
+	for _, detail := range d.details {
+		y -= 14
+		p.TextRight(unit, y, 9, false, detail[0])
+		p.TextRight(right, y, 9, true, detail[1])
+	}
+
+	y = pageHeight - 200
+	addresses := []struct {
+		label   string
+		x       float64
+		address *types.OrderAddress
+	}{{"Bill to", left, d.billTo}, {"Ship to", 300, d.shipTo}}
+	lowest := y
+	for _, a := range addresses {
+		if a.address == nil {
+			continue
+		}
+		ay := y
+		p.Text(a.x, ay, 9, true, a.label)
+		for _, line := range addressLines(a.address) {
+			ay -= 13
+			p.Text(a.x, ay, 10, false, line)
+		}
+		lowest = min(lowest, ay)
+	}
+
+	y = lowest - 40
+	tableHeader := func() {
+		p.Text(left, y, 9, true, "Description")
+		p.TextRight(quantity, y, 9, true, "Qty")
+		p.TextRight(unit, y, 9, true, "Unit price")
+		p.TextRight(right, y, 9, true, "Amount")
+		p.Line(left, y-6, right, y-6)
+		y -= 22
+	}
+	newLine := func(step float64) {
+		y -= step
+		if y < bottom {
+			p.AddPage()
+			y = pageHeight - 60
+			tableHeader()
+		}
+	}
+
+	tableHeader()
+	for _, r := range d.rows {
+		p.Text(left, y, 10, false, clip(r.description, 60))
+		p.TextRight(quantity, y, 10, false, r.quantity)
+		p.TextRight(unit, y, 10, false, r.unit)
+		p.TextRight(right, y, 10, false, r.amount)
+		newLine(16)
+	}
+
+	p.Line(unit-120, y+8, right, y+8)
+	newLine(8)
+	for _, r := range d.totals {
+		p.TextRight(unit, y, 10, r.bold, r.description)
+		p.TextRight(right, y, 10, r.bold, r.amount)
+		newLine(16)
+	}
+
+	newLine(16)
+	for _, note := range d.notes {
+		p.Text(left, y, 9, false, note)
+		newLine(13)
+	}
+
+	return p.Bytes()
+}
+
+func taxID(seller Seller) []string {
+	if seller.TaxID == "" {
+		return nil
+	}
+	return []string{"Tax ID " + seller.TaxID}
+}
+
+func addressLines(a *types.OrderAddress) []string {
+	lines := []string{a.Name}
+	for _, line := range []string{a.Company, a.Line1, a.Line2, strings.TrimSpace(a.PostalCode + " " + a.City)} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, strings.TrimSpace(a.Region+" "+a.Country))
+	return lines
+}
+
+// clip shortens descriptions that would run into the quantity column
+func clip(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,249 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	email "github.com/yahyaammar-dev/pacebe/services/emails"
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	TypeInvoice    = "invoice"
+	TypeCreditNote = "credit_note"
+)
+
+// prefixes start the numbers of each document type, every type counts on its own
+var prefixes = map[string]string{
+	TypeInvoice:    "INV",
+	TypeCreditNote: "CN",
+}
+
+// invoiceable are the statuses of orders that were paid
+var invoiceable = []string{order.StatusPaid, order.StatusFulfilled, order.StatusDelivered, order.StatusRefunded}
+
+// Seller is who issues the invoices, from INVOICE_SELLER_NAME, INVOICE_SELLER_ADDRESS with its lines
+// separated by semicolons and INVOICE_SELLER_TAX_ID
+type Seller struct {
+	Name    string
+	Address []string
+	TaxID   string
+}
+
+func SellerFromEnv() Seller {
+	seller := Seller{Name: os.Getenv("INVOICE_SELLER_NAME"), TaxID: os.Getenv("INVOICE_SELLER_TAX_ID")}
+	if seller.Name == "" {
+		seller.Name = "Pace"
+	}
+	for _, line := range strings.Split(os.Getenv("INVOICE_SELLER_ADDRESS"), ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			seller.Address = append(seller.Address, line)
+		}
+	}
+	return seller
+}
+
+// Issuer issues the invoices of paid orders and the credit notes of refunds and keeps their PDFs
+type Issuer struct {
+	store   types.InvoiceStore
+	orders  types.OrderStore
+	users   types.UserStore
+	storage storage.Storage
+	seller  Seller
+}
+
+func NewIssuer(store types.InvoiceStore, orders types.OrderStore, users types.UserStore, blobStorage storage.Storage, seller Seller) *Issuer {
+	return &Issuer{store: store, orders: orders, users: users, storage: blobStorage, seller: seller}
+}
+
+// MovePrivate moves the PDFs of invoices issued before they were kept under the private prefix, the
+// old keys could be fetched straight from the storage
+func (i *Issuer) MovePrivate() error {
+	invoices, err := i.store.GetInvoices(types.InvoiceFilter{})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, invoice := range invoices {
+		if invoice.Key == "" || strings.HasPrefix(invoice.Key, storage.PrivatePrefix) {
+			continue
+		}
+		file, err := i.storage.Get(ctx, invoice.Key)
+		if err != nil {
+			return fmt.Errorf("invoice %s: %w", invoice.Number, err)
+		}
+		body, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("invoice %s: %w", invoice.Number, err)
+		}
+
+		key := storage.PrivatePrefix + invoice.Key
+		if err := i.storage.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "application/pdf"); err != nil {
+			return fmt.Errorf("invoice %s: %w", invoice.Number, err)
+		}
+		if err := i.store.MoveInvoice(invoice.ID, key); err != nil {
+			return err
+		}
+		if err := i.storage.Delete(ctx, invoice.Key); err != nil {
+			log.Printf("old PDF of invoice %s was not deleted: %v", invoice.Number, err)
+		}
+	}
+	return nil
+}
+
+// IssueInvoice invoices a paid order, an order is invoiced once
+func (i *Issuer) IssueInvoice(o *types.Order) (*types.Invoice, []byte, error) {
+	if !slices.Contains(invoiceable, o.Status) {
+		return nil, nil, fmt.Errorf("only paid orders are invoiced, this one is %s", o.Status)
+	}
+
+	now := time.Now()
+	invoice := &types.Invoice{
+		Type:     TypeInvoice,
+		Year:     now.Year(),
+		OrderID:  o.ID,
+		UserID:   o.UserID,
+		Tax:      o.Tax,
+		Total:    o.Total,
+		IssuedAt: now,
+	}
+	file, err := i.issue(invoice, func() document {
+		return invoiceDocument(invoice, o)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, file, nil
+}
+
+// IssueCreditNote credits the amount of a refund against the invoice of the order. A refund of the
+// whole invoice repeats its lines, a partial refund is one line with its share of the tax.
+func (i *Issuer) IssueCreditNote(o *types.Order, amount types.Money) (*types.Invoice, error) {
+	invoices, err := i.store.GetInvoicesByOrderID(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var invoice *types.Invoice
+	left := int64(0)
+	for k := range invoices {
+		if invoices[k].Type == TypeInvoice {
+			invoice = &invoices[k]
+		}
+		left += invoices[k].Total.Amount
+	}
+	// refunds of orders that weren't invoiced, e.g. because issuing failed, need the invoice first
+	if invoice == nil {
+		if invoice, _, err = i.IssueInvoice(o); err != nil {
+			return nil, err
+		}
+		left = invoice.Total.Amount
+	}
+
+	if amount.Amount <= 0 || amount.Amount > left {
+		return nil, fmt.Errorf("credit of %s %s doesn't fit the %s %s left on invoice %s",
+			money.Format(amount), amount.Currency, money.Format(money.New(left, amount.Currency)), amount.Currency, invoice.Number)
+	}
+
+	full := amount.Amount == invoice.Total.Amount
+	tax := invoice.Tax.Amount
+	if !full && invoice.Total.Amount != 0 {
+		tax = money.Round(new(big.Rat).SetFrac(big.NewInt(amount.Amount*invoice.Tax.Amount), big.NewInt(invoice.Total.Amount)))
+	}
+
+	now := time.Now()
+	note := &types.Invoice{
+		Type:      TypeCreditNote,
+		Year:      now.Year(),
+		OrderID:   o.ID,
+		UserID:    o.UserID,
+		InvoiceID: &invoice.ID,
+		Tax:       money.New(-tax, amount.Currency),
+		Total:     money.New(-amount.Amount, amount.Currency),
+		IssuedAt:  now,
+	}
+	if _, err := i.issue(note, func() document {
+		return creditNoteDocument(note, invoice, o, full)
+	}); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// issue numbers the document, renders it and keeps the PDF under the private prefix, it is only handed
+// out through the invoice routes. The random part of the key keeps it unguessable all the same.
+func (i *Issuer) issue(invoice *types.Invoice, layout func() document) ([]byte, error) {
+	var file []byte
+	err := i.store.CreateInvoice(invoice, func(invoice *types.Invoice) error {
+		file = render(i.seller, layout())
+
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		invoice.Key = fmt.Sprintf("%sinvoices/%d/%s-%s.pdf", storage.PrivatePrefix, invoice.Year, invoice.Number, hex.EncodeToString(b))
+		invoice.Size = int64(len(file))
+		return i.storage.Put(context.Background(), invoice.Key, bytes.NewReader(file), invoice.Size, "application/pdf")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// RegisterListeners invoices orders when they're paid, mails the invoice to the customer, and issues
+// a credit note for every refund
+func (i *Issuer) RegisterListeners() {
+	event.Register("order."+order.StatusPaid, func(e types.Event) {
+		changed, ok := e.Payload.(types.OrderEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+
+		invoice, file, err := i.IssueInvoice(&changed.Order)
+		if err != nil {
+			log.Printf("failed to invoice order %d: %v", changed.Order.ID, err)
+			return
+		}
+
+		user, err := i.users.GetUserByID(changed.Order.UserID)
+		if err != nil {
+			log.Printf("failed to mail invoice %s: %v", invoice.Number, err)
+			return
+		}
+		email.SendInvoice(user.Email, invoice.Number, changed.Order.ID, file)
+	})
+
+	event.Register("payment.refunded", func(e types.Event) {
+		refunded, ok := e.Payload.(types.PaymentRefundedEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+
+		o, err := i.orders.GetOrderByID(refunded.Payment.OrderID)
+		if err != nil {
+			log.Printf("failed to load order %d for its credit note: %v", refunded.Payment.OrderID, err)
+			return
+		}
+		if _, err := i.IssueCreditNote(o, refunded.Amount); err != nil {
+			log.Printf("failed to issue the credit note of order %d: %v", o.ID, err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// page sizes in points, A4
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// pdf writes simple text documents with the standard Helvetica fonts, which every reader has, so
+// nothing needs to be embedded
+type pdf struct {
+	pages []*bytes.Buffer
+}
+
+func newPDF() *pdf {
+	d := &pdf{}
+	d.AddPage()
+	return d
+}
+
+func (d *pdf) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// Text writes s with its baseline starting at x, y, measured from the bottom left corner of the page
+func (d *pdf) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// TextRight writes s so that it ends at x
+func (d *pdf) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-textWidth(s, size), y, size, bold, s)
+}
+
+func (d *pdf) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (d *pdf) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Bytes assembles the document: the catalog, the page tree, both fonts and every page with its content
+func (d *pdf) Bytes() []byte {
+	var objects []string
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+
+	catalog := add("")
+	pages := add("")
+	regular := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	bold := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	kids := make([]string, 0, len(d.pages))
+	for _, content := range d.pages {
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pages, pageWidth, pageHeight, regular, bold, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages)
+	objects[pages-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, xref)
+	return out.Bytes()
+}
+
+// escape encodes s in WinAnsi for a PDF string, characters the encoding doesn't have become a question mark
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// widths of Helvetica in thousandths of the font size for the characters amounts are made of,
+// everything else is measured like a digit, which is close enough to right align numbers
+var widths = map[rune]float64{
+	' ': 278, ',': 278, '.': 278, '-': 333, '%': 889,
+	'A': 667, 'B': 667, 'C': 722, 'D': 722, 'E': 667, 'F': 611, 'G': 778, 'H': 722, 'I': 278, 'J': 500,
+	'K': 667, 'L': 556, 'M': 833, 'N': 722, 'O': 778, 'P': 667, 'Q': 778, 'R': 722, 'S': 667, 'T': 611,
+	'U': 722, 'V': 667, 'W': 944, 'X': 667, 'Y': 667, 'Z': 611,
+}
+
+func textWidth(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		w, ok := widths[r]
+		if !ok {
+			w = 556
+		}
+		width += w
+	}
+	return width * size / 1000
+}
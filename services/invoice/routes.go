@@ -0,0 +1,239 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store      types.InvoiceStore
+	orderStore types.OrderStore
+	issuer     *Issuer
+	storage    storage.Storage
+	userStore  types.UserStore
+}
+
+func NewHandler(store types.InvoiceStore, orderStore types.OrderStore, issuer *Issuer, blobStorage storage.Storage, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orderStore: orderStore, issuer: issuer, storage: blobStorage, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/orders/{id:[0-9]+}/invoices", auth.WithJWTAuth(h.handleGetMyOrderInvoices, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/invoices/{id:[0-9]+}/pdf", auth.WithJWTAuth(h.handleDownloadMyInvoice, h.userStore)).Methods("GET")
+
+	router.HandleFunc("/invoices", auth.WithRoles(h.handleGetInvoices, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/invoices/{id:[0-9]+}/pdf", auth.WithRoles(h.handleDownloadInvoice, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/invoices", auth.WithRoles(h.handleGetOrderInvoices, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/invoices", auth.WithRoles(h.handleIssueInvoice, h.userStore, "admin")).Methods("POST")
+}
+
+// @Summary My order invoices
+// @Description Lists the invoice and credit notes of an order of the logged in user
+// @Tags Invoices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} types.Invoice
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /me/orders/{id}/invoices [get]
+func (h *Handler) handleGetMyOrderInvoices(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := h.orderStore.GetOrderByID(id)
+	if err != nil || order.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+
+	invoices, err := h.store.GetInvoicesByOrderID(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, invoices)
+}
+
+// @Summary Download my invoice
+// @Description Downloads the PDF of an invoice or credit note of the logged in user
+// @Tags Invoices
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string "Invoice not found"
+// @Router /me/invoices/{id}/pdf [get]
+func (h *Handler) handleDownloadMyInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	invoice, err := h.store.GetInvoiceByID(id)
+	if err != nil || invoice.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("invoice not found"))
+		return
+	}
+
+	h.download(w, r, invoice)
+}
+
+// @Summary List invoices
+// @Description Lists the invoices and credit notes in the order of their numbers
+// @Tags Invoices
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "invoice or credit_note"
+// @Param year query int false "Year of issue"
+// @Success 200 {array} types.Invoice
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Router /invoices [get]
+func (h *Handler) handleGetInvoices(w http.ResponseWriter, r *http.Request) {
+	filter := types.InvoiceFilter{Type: r.URL.Query().Get("type")}
+	if _, ok := prefixes[filter.Type]; filter.Type != "" && !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("type must be %s or %s", TypeInvoice, TypeCreditNote))
+		return
+	}
+	if v := r.URL.Query().Get("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid year"))
+			return
+		}
+		filter.Year = year
+	}
+
+	invoices, err := h.store.GetInvoices(filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, invoices)
+}
+
+// @Summary Download invoice
+// @Description Downloads the PDF of an invoice or credit note
+// @Tags Invoices
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string "Invoice not found"
+// @Router /invoices/{id}/pdf [get]
+func (h *Handler) handleDownloadInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	invoice, err := h.store.GetInvoiceByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	h.download(w, r, invoice)
+}
+
+// @Summary Order invoices
+// @Description Lists the invoice and credit notes of an order
+// @Tags Invoices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} types.Invoice
+// @Router /orders/{id}/invoices [get]
+func (h *Handler) handleGetOrderInvoices(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	invoices, err := h.store.GetInvoicesByOrderID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, invoices)
+}
+
+// @Summary Issue invoice
+// @Description Invoices a paid order that has no invoice, e.g. because issuing it failed when it was paid
+// @Tags Invoices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 201 {object} types.Invoice
+// @Failure 400 {object} map[string]string "Order isn't paid"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Order is invoiced already"
+// @Router /orders/{id}/invoices [post]
+func (h *Handler) handleIssueInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := h.orderStore.GetOrderByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	invoice, _, err := h.issuer.IssueInvoice(order)
+	if err != nil {
+		if errors.Is(err, ErrAlreadyInvoiced) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, invoice)
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request, invoice *types.Invoice) {
+	file, err := h.storage.Get(r.Context(), invoice.Key)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Length", strconv.FormatInt(invoice.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%s.pdf", invoice.Number))
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("download of invoice %s failed: %v", invoice.Number, err)
+	}
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,103 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAlreadyInvoiced is returned for a second invoice of an order, orders have one invoice and any number of credit notes
+var ErrAlreadyInvoiced = errors.New("order is invoiced already")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetInvoices lists invoices and credit notes in the order they were issued
+func (s *Store) GetInvoices(filter types.InvoiceFilter) ([]types.Invoice, error) {
+	query := s.db.Order("type, year, sequence")
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Year != 0 {
+		query = query.Where("year = ?", filter.Year)
+	}
+
+	var invoices []types.Invoice
+	if err := query.Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (s *Store) GetInvoiceByID(id uint) (*types.Invoice, error) {
+	var invoice types.Invoice
+	result := s.db.First(&invoice, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, result.Error
+	}
+	return &invoice, nil
+}
+
+// GetInvoicesByOrderID lists the invoice of the order and its credit notes
+func (s *Store) GetInvoicesByOrderID(orderID uint) ([]types.Invoice, error) {
+	var invoices []types.Invoice
+	result := s.db.Where("order_id = ?", orderID).Order("id").Find(&invoices)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return invoices, nil
+}
+
+// MoveInvoice points the invoice at its PDF under a new key
+func (s *Store) MoveInvoice(id uint, key string) error {
+	return s.db.Model(&types.Invoice{}).Where("id = ?", id).Update("key", key).Error
+}
+
+// CreateInvoice takes the next number of the type and year and stores the invoice in one transaction.
+// The sequence row stays locked until the invoice is stored, so numbers are handed out in order and a
+// failed file or insert gives its number back.
+func (s *Store) CreateInvoice(invoice *types.Invoice, file func(*types.Invoice) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		sequence := types.InvoiceSequence{Type: invoice.Type, Year: invoice.Year}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+			return err
+		}
+		next := tx.Model(&types.InvoiceSequence{}).
+			Where("type = ? AND year = ?", invoice.Type, invoice.Year).
+			Update("number", gorm.Expr("number + 1"))
+		if next.Error != nil {
+			return next.Error
+		}
+		if err := tx.Where("type = ? AND year = ?", invoice.Type, invoice.Year).First(&sequence).Error; err != nil {
+			return err
+		}
+
+		if invoice.Type == TypeInvoice {
+			var count int64
+			if err := tx.Model(&types.Invoice{}).Where("order_id = ? AND type = ?", invoice.OrderID, TypeInvoice).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrAlreadyInvoiced
+			}
+		}
+
+		invoice.Sequence = sequence.Number
+		invoice.Number = fmt.Sprintf("%s-%d-%06d", prefixes[invoice.Type], invoice.Year, invoice.Sequence)
+		if err := file(invoice); err != nil {
+			return err
+		}
+		return tx.Create(invoice).Error
+	})
+}
@@ -0,0 +1,118 @@
+package invoice
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&types.Invoice{}, &types.InvoiceSequence{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(db)
+}
+
+func keep(*types.Invoice) error { return nil }
+
+func create(t *testing.T, store *Store, kind string, year int, orderID uint) *types.Invoice {
+	t.Helper()
+
+	invoice := &types.Invoice{Type: kind, Year: year, OrderID: orderID, UserID: 1}
+	if err := store.CreateInvoice(invoice, keep); err != nil {
+		t.Fatal(err)
+	}
+	return invoice
+}
+
+func TestInvoiceNumbersAreConsecutive(t *testing.T) {
+	store := newTestStore(t)
+
+	for i, want := range []string{"INV-2026-000001", "INV-2026-000002", "INV-2026-000003"} {
+		if got := create(t, store, TypeInvoice, 2026, uint(i+1)).Number; got != want {
+			t.Fatalf("invoice %d is %s, want %s", i+1, got, want)
+		}
+	}
+	// credit notes count on their own
+	if got := create(t, store, TypeCreditNote, 2026, 1).Number; got != "CN-2026-000001" {
+		t.Fatalf("credit note is %s, want CN-2026-000001", got)
+	}
+	if got := create(t, store, TypeInvoice, 2026, 4).Number; got != "INV-2026-000004" {
+		t.Fatalf("invoice after the credit note is %s, want INV-2026-000004", got)
+	}
+}
+
+func TestFailedFileGivesItsNumberBack(t *testing.T) {
+	store := newTestStore(t)
+	create(t, store, TypeInvoice, 2026, 1)
+
+	failed := errors.New("storage is down")
+	err := store.CreateInvoice(&types.Invoice{Type: TypeInvoice, Year: 2026, OrderID: 2, UserID: 1}, func(invoice *types.Invoice) error {
+		if invoice.Number != "INV-2026-000002" {
+			t.Errorf("failing invoice got %s, want INV-2026-000002", invoice.Number)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the file error", err)
+	}
+
+	if got := create(t, store, TypeInvoice, 2026, 2).Number; got != "INV-2026-000002" {
+		t.Fatalf("retried invoice is %s, want INV-2026-000002", got)
+	}
+	invoices, err := store.GetInvoices(types.InvoiceFilter{Type: TypeInvoice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 2 {
+		t.Fatalf("%d invoices stored, want 2", len(invoices))
+	}
+}
+
+func TestSecondInvoiceOfOrderIsRefused(t *testing.T) {
+	store := newTestStore(t)
+	create(t, store, TypeInvoice, 2026, 1)
+
+	filed := false
+	err := store.CreateInvoice(&types.Invoice{Type: TypeInvoice, Year: 2026, OrderID: 1, UserID: 1}, func(*types.Invoice) error {
+		filed = true
+		return nil
+	})
+	if !errors.Is(err, ErrAlreadyInvoiced) {
+		t.Fatalf("got %v, want ErrAlreadyInvoiced", err)
+	}
+	if filed {
+		t.Fatal("the refused invoice was rendered and kept")
+	}
+
+	// the refused invoice used up no number, and credit notes of the order are still issued
+	if got := create(t, store, TypeInvoice, 2026, 2).Number; got != "INV-2026-000002" {
+		t.Fatalf("next invoice is %s, want INV-2026-000002", got)
+	}
+	create(t, store, TypeCreditNote, 2026, 1)
+	create(t, store, TypeCreditNote, 2026, 1)
+}
+
+func TestNumbersStartOverEachYear(t *testing.T) {
+	store := newTestStore(t)
+	create(t, store, TypeInvoice, 2025, 1)
+	create(t, store, TypeInvoice, 2025, 2)
+
+	if got := create(t, store, TypeInvoice, 2026, 3); got.Number != "INV-2026-000001" || got.Sequence != 1 {
+		t.Fatalf("first invoice of 2026 is %s, want INV-2026-000001", got.Number)
+	}
+	if got := create(t, store, TypeInvoice, 2025, 4).Number; got != "INV-2025-000003" {
+		t.Fatalf("late invoice of 2025 is %s, want INV-2025-000003", got)
+	}
+}
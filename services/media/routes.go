@@ -0,0 +1,395 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/storage"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+// maxImagesPerUpload caps the files of a single multipart request
+const maxImagesPerUpload = 10
+
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type Handler struct {
+	store        types.ProductImageStore
+	productStore types.ProductStore
+	userStore    types.UserStore
+	storage      storage.Storage
+}
+
+func NewHandler(store types.ProductImageStore, productStore types.ProductStore, userStore types.UserStore, storage storage.Storage) *Handler {
+	return &Handler{store: store, productStore: productStore, userStore: userStore, storage: storage}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/images", h.handleGetImages).Methods("GET")
+
+	router.HandleFunc("/products/{id:[0-9]+}/images", auth.WithRoles(h.handleUploadImages, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/images/order", auth.WithRoles(h.handleReorderImages, h.userStore, "admin", "operator")).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/images/{imageId:[0-9]+}", auth.WithRoles(h.handleUpdateImage, h.userStore, "admin", "operator")).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/images/{imageId:[0-9]+}", auth.WithRoles(h.handleDeleteImage, h.userStore, "admin", "operator")).Methods("DELETE")
+}
+
+// MaxImageSize is the upload limit per image in bytes from MAX_IMAGE_SIZE, 10 MB by default
+func MaxImageSize() int64 {
+	if size, err := strconv.ParseInt(os.Getenv("MAX_IMAGE_SIZE"), 10, 64); err == nil && size > 0 {
+		return size
+	}
+	return 10 << 20
+}
+
+// @Summary List product images
+// @Description Lists the images of a product in display order
+// @Tags Products
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {array} types.ProductImage
+// @Router /products/{id}/images [get]
+func (h *Handler) handleGetImages(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	images, err := h.store.GetProductImages(productID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, images)
+}
+
+// @Summary Upload product images
+// @Description Uploads one or more JPEG, PNG, GIF or WebP images, they are added after the existing ones with thumbnails
+// @Tags Products
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param images formData file true "Image files"
+// @Param alt formData string false "Alternative text for the uploaded images"
+// @Success 201 {array} types.ProductImage
+// @Failure 400 {object} map[string]string "Invalid or too large image"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id}/images [post]
+func (h *Handler) handleUploadImages(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.productStore.GetProductByID(productID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	maxSize := MaxImageSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize*maxImagesPerUpload+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid upload: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("no images uploaded, send them in the images field"))
+		return
+	}
+	if len(files) > maxImagesPerUpload {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at most %d images per upload", maxImagesPerUpload))
+		return
+	}
+
+	alt := r.FormValue("alt")
+	if len(alt) > 250 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("alt text can't be longer than 250 characters"))
+		return
+	}
+
+	var images []types.ProductImage
+	var keys []string
+	cleanup := func() {
+		for _, key := range keys {
+			if err := h.storage.Delete(context.Background(), key); err != nil {
+				log.Printf("failed to delete %s: %v", key, err)
+			}
+		}
+	}
+
+	for _, file := range files {
+		image, written, err := h.storeImage(r.Context(), productID, file, maxSize)
+		keys = append(keys, written...)
+		if err != nil {
+			cleanup()
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s: %v", file.Filename, err))
+			return
+		}
+		image.Alt = alt
+		images = append(images, *image)
+	}
+
+	if err := h.store.CreateProductImages(productID, images); err != nil {
+		cleanup()
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, images)
+}
+
+// @Summary Reorder product images
+// @Description Sets the display order of all images of a product, the first one becomes the product's imageUrl
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param productImageOrderPayload body types.ProductImageOrderPayload true "Image IDs in display order"
+// @Success 200 {array} types.ProductImage
+// @Failure 400 {object} map[string]string "Order doesn't match the product's images"
+// @Router /products/{id}/images/order [put]
+func (h *Handler) handleReorderImages(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ProductImageOrderPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.store.ReorderProductImages(productID, payload.ImageIDs); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.handleGetImages(w, r)
+}
+
+// @Summary Update product image
+// @Description Updates the alternative text of an image
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param imageId path int true "Image ID"
+// @Param productImagePayload body types.ProductImagePayload true "Image payload"
+// @Success 200 {object} types.ProductImage
+// @Failure 404 {object} map[string]string "Image not found"
+// @Router /products/{id}/images/{imageId} [put]
+func (h *Handler) handleUpdateImage(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	imageID, err := parseID(r, "imageId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	image, err := h.store.GetProductImage(productID, imageID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ProductImagePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	image.Alt = payload.Alt
+	if err := h.store.UpdateProductImage(image); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, image)
+}
+
+// @Summary Delete product image
+// @Description Deletes an image and its thumbnails, the remaining images close the gap
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param imageId path int true "Image ID"
+// @Success 200 {object} map[string]string "Image deleted"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Router /products/{id}/images/{imageId} [delete]
+func (h *Handler) handleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	imageID, err := parseID(r, "imageId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	image, err := h.store.GetProductImage(productID, imageID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteProductImage(productID, imageID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the row is gone already, a blob that can't be deleted only wastes space
+	keys := []string{image.Key}
+	for _, thumbnail := range image.Thumbnails {
+		keys = append(keys, thumbnail.Key)
+	}
+	for _, key := range keys {
+		if err := h.storage.Delete(r.Context(), key); err != nil {
+			log.Printf("failed to delete %s: %v", key, err)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Image deleted"})
+}
+
+// storeImage checks an uploaded file and writes it with its thumbnails, it returns the keys it wrote
+// so the caller can clean up when a later file of the same upload fails
+func (h *Handler) storeImage(ctx context.Context, productID uint, file *multipart.FileHeader, maxSize int64) (*types.ProductImage, []string, error) {
+	if file.Size > maxSize {
+		return nil, nil, fmt.Errorf("image is larger than %d bytes", maxSize)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, nil, fmt.Errorf("image is larger than %d bytes", maxSize)
+	}
+
+	// trust the bytes, not the file name or the header the client sent
+	contentType := mimetype.Detect(data).String()
+	extension, ok := allowedTypes[contentType]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+
+	img, err := Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, nil, err
+	}
+	base := fmt.Sprintf("products/%d/%s", productID, name)
+
+	image := &types.ProductImage{
+		Key:         base + extension,
+		URL:         h.storage.URL(base + extension),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	var written []string
+	if err := h.storage.Put(ctx, image.Key, bytes.NewReader(data), image.Size, contentType); err != nil {
+		return nil, written, err
+	}
+	written = append(written, image.Key)
+
+	for _, size := range ThumbnailSizes {
+		thumbnail := Resize(img, size.Max)
+		encoded, thumbnailType, err := Encode(thumbnail, contentType)
+		if err != nil {
+			return nil, written, err
+		}
+
+		key := fmt.Sprintf("%s_%s%s", base, size.Name, allowedTypes[thumbnailType])
+		if err := h.storage.Put(ctx, key, bytes.NewReader(encoded), int64(len(encoded)), thumbnailType); err != nil {
+			return nil, written, err
+		}
+		written = append(written, key)
+
+		image.Thumbnails = append(image.Thumbnails, types.ImageThumbnail{
+			Name:   size.Name,
+			Key:    key,
+			URL:    h.storage.URL(key),
+			Width:  thumbnail.Bounds().Dx(),
+			Height: thumbnail.Bounds().Dy(),
+		})
+	}
+
+	return image, written, nil
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func parseID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
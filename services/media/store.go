@@ -0,0 +1,137 @@
+package media
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetProductImages(productID uint) ([]types.ProductImage, error) {
+	var images []types.ProductImage
+	result := s.db.Where("product_id = ?", productID).Order("position, id").Find(&images)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return images, nil
+}
+
+func (s *Store) GetProductImage(productID, id uint) (*types.ProductImage, error) {
+	var image types.ProductImage
+	result := s.db.Where("product_id = ?", productID).First(&image, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("image not found")
+		}
+		return nil, result.Error
+	}
+	return &image, nil
+}
+
+// CreateProductImages appends the images after the ones the product already has
+func (s *Store) CreateProductImages(productID uint, images []types.ProductImage) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var next int
+		if err := tx.Model(&types.ProductImage{}).
+			Where("product_id = ?", productID).
+			Select("COALESCE(MAX(position) + 1, 0)").
+			Scan(&next).Error; err != nil {
+			return err
+		}
+
+		for i := range images {
+			images[i].ProductID = productID
+			images[i].Position = next + i
+		}
+
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+		return syncCover(tx, productID)
+	})
+}
+
+func (s *Store) UpdateProductImage(image *types.ProductImage) error {
+	result := s.db.Save(image)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) DeleteProductImage(productID, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("product_id = ?", productID).Delete(&types.ProductImage{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("image not found")
+		}
+
+		var ids []uint
+		if err := tx.Model(&types.ProductImage{}).Where("product_id = ?", productID).Order("position, id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if err := renumber(tx, ids); err != nil {
+			return err
+		}
+		return syncCover(tx, productID)
+	})
+}
+
+// ReorderProductImages puts the images in the given order, every image of the product has to be listed
+func (s *Store) ReorderProductImages(productID uint, ids []uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&types.ProductImage{}).Where("product_id = ?", productID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+
+		known := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			known[id] = true
+		}
+		if len(ids) != len(existing) {
+			return fmt.Errorf("order must list all %d images of the product", len(existing))
+		}
+		for _, id := range ids {
+			if !known[id] {
+				return fmt.Errorf("image %d doesn't belong to the product", id)
+			}
+		}
+
+		if err := renumber(tx, ids); err != nil {
+			return err
+		}
+		return syncCover(tx, productID)
+	})
+}
+
+func renumber(tx *gorm.DB, ids []uint) error {
+	for position, id := range ids {
+		if err := tx.Model(&types.ProductImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCover keeps products.image_url pointing at the first image for clients that only read one image
+func syncCover(tx *gorm.DB, productID uint) error {
+	var cover types.ProductImage
+	result := tx.Where("product_id = ?", productID).Order("position, id").Limit(1).Find(&cover)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return tx.Model(&types.Product{}).Where("id = ?", productID).Update("image_url", cover.URL).Error
+}
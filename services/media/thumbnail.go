@@ -0,0 +1,87 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ThumbnailSize bounds the longest edge of a thumbnail in pixels
+type ThumbnailSize struct {
+	Name string
+	Max  int
+}
+
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Max: 160},
+	{Name: "medium", Max: 480},
+}
+
+// maxPixels keeps decompression bombs from being decoded, 40 megapixels is plenty for product shots
+const maxPixels = 40_000_000
+
+// Decode checks the dimensions from the header before decoding the whole image
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large, %dx%d pixels", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	return img, nil
+}
+
+// Resize scales the image down so its longest edge fits max, smaller images are left alone
+func Resize(img image.Image, max int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= max && height <= max {
+		return img
+	}
+
+	if width >= height {
+		height = height * max / width
+		width = max
+	} else {
+		width = width * max / height
+		height = max
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+	return resized
+}
+
+// Encode writes JPEG for photos and PNG for everything else so transparency survives
+func Encode(img image.Image, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
@@ -0,0 +1,125 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// minorUnits lists the ISO 4217 currencies that don't use two decimals
+var minorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0,
+	"VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// BaseCurrency is the currency product prices are stored and filtered in
+func BaseCurrency() string {
+	if currency := os.Getenv("BASE_CURRENCY"); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return "USD"
+}
+
+// Exponent returns the number of decimals of a currency, e.g. 2 for USD and 0 for JPY
+func Exponent(currency string) int {
+	if exp, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+func New(amount int64, currency string) types.Money {
+	return types.Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// ParseAmount turns a decimal string like "349.99" into minor units of the currency,
+// it refuses more decimals than the currency has instead of rounding them away
+func ParseAmount(s, currency string) (int64, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(pow10(Exponent(currency))))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("amount %s has more decimals than %s allows", s, currency)
+	}
+
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is out of range", s)
+	}
+	return r.Num().Int64(), nil
+}
+
+// Format renders the amount as a plain decimal string, e.g. 34999 USD becomes "349.99"
+func Format(m types.Money) string {
+	exp := Exponent(m.Currency)
+	if exp == 0 {
+		return fmt.Sprintf("%d", m.Amount)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	divisor := pow10(exp).Int64()
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/divisor, exp, amount%divisor)
+}
+
+// Convert applies a rate to the amount and rounds half away from zero into the minor units of the target currency
+func Convert(m types.Money, to string, rate *big.Rat) types.Money {
+	to = strings.ToUpper(to)
+	if strings.EqualFold(m.Currency, to) {
+		return New(m.Amount, to)
+	}
+
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, rate)
+	r.Mul(r, new(big.Rat).SetInt(pow10(Exponent(to))))
+	r.Quo(r, new(big.Rat).SetInt(pow10(Exponent(m.Currency))))
+
+	return New(Round(r), to)
+}
+
+// Add sums amounts of the same currency
+func Add(a, b types.Money) (types.Money, error) {
+	if !strings.EqualFold(a.Currency, b.Currency) {
+		return types.Money{}, fmt.Errorf("can't add %s to %s", b.Currency, a.Currency)
+	}
+	return New(a.Amount+b.Amount, a.Currency), nil
+}
+
+// Multiply scales the amount by a whole quantity, e.g. a unit price by the number of items
+func Multiply(m types.Money, quantity int) types.Money {
+	return New(m.Amount*int64(quantity), m.Currency)
+}
+
+// Round turns an exact amount of minor units into a whole one, halves round away from zero
+func Round(r *big.Rat) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+
+	negative := num.Sign() < 0
+	num.Abs(num)
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	if negative {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
+
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
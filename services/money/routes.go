@@ -0,0 +1,63 @@
+package money
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.CurrencyStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.CurrencyStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/exchange-rates", h.handleGetExchangeRates).Methods("GET")
+	router.HandleFunc("/exchange-rates/reload", auth.WithRoles(h.handleReloadExchangeRates, h.userStore, "admin")).Methods("POST")
+}
+
+// @Summary List exchange rates
+// @Description Lists the exchange rates used to convert prices
+// @Tags Currencies
+// @Produce json
+// @Success 200 {array} types.ExchangeRate
+// @Router /exchange-rates [get]
+func (h *Handler) handleGetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.store.GetExchangeRates()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rates)
+}
+
+// @Summary Reload exchange rates
+// @Description Reads the exchange rate file from disk again and stores its rates
+// @Tags Currencies
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.ExchangeRate
+// @Failure 400 {object} map[string]string "Rates file is missing or invalid"
+// @Router /exchange-rates/reload [post]
+func (h *Handler) handleReloadExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := LoadRatesFile(RatesFilePath())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SaveExchangeRates(rates); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.handleGetExchangeRates(w, r)
+}
@@ -0,0 +1,227 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// RatesFile is the layout of the exchange rate file, e.g. {"base": "USD", "rates": {"EUR": "0.92"}}
+type RatesFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+// RatesFilePath is where LoadRatesFile reads from unless EXCHANGE_RATES_FILE says otherwise
+func RatesFilePath() string {
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		return path
+	}
+	return "rates.json"
+}
+
+// LoadRatesFile reads and checks an exchange rate file without touching the database
+func LoadRatesFile(path string) ([]types.ExchangeRate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file RatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rates file %s: %v", path, err)
+	}
+
+	base := strings.ToUpper(strings.TrimSpace(file.Base))
+	if len(base) != 3 {
+		return nil, fmt.Errorf("invalid rates file %s: base currency is missing", path)
+	}
+
+	rates := make([]types.ExchangeRate, 0, len(file.Rates))
+	for quote, rate := range file.Rates {
+		r, ok := new(big.Rat).SetString(rate)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rates file %s: rate %q for %s", path, rate, quote)
+		}
+		rates = append(rates, types.ExchangeRate{
+			Base:  base,
+			Quote: strings.ToUpper(strings.TrimSpace(quote)),
+			Rate:  rate,
+		})
+	}
+
+	return rates, nil
+}
+
+func (s *Store) GetExchangeRates() ([]types.ExchangeRate, error) {
+	var rates []types.ExchangeRate
+	result := s.db.Order("base, quote").Find(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rates, nil
+}
+
+// SaveExchangeRates inserts new currency pairs and updates the rate of known ones
+func (s *Store) SaveExchangeRates(rates []types.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&rates)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Rate finds the rate between two currencies, directly, inverted or crossed over a shared base
+func (s *Store) Rate(from, to string) (*big.Rat, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	rates, err := s.GetExchangeRates()
+	if err != nil {
+		return nil, err
+	}
+
+	return findRate(rates, from, to)
+}
+
+func (s *Store) Convert(m types.Money, currency string) (types.Money, error) {
+	rate, err := s.Rate(m.Currency, currency)
+	if err != nil {
+		return types.Money{}, err
+	}
+	return Convert(m, currency, rate), nil
+}
+
+// LocalizeProducts rewrites the prices of the products into the currency, fixed prices from the
+// price list win and everything else is converted with the exchange rates
+func (s *Store) LocalizeProducts(products []types.Product, currency string) error {
+	currency = strings.ToUpper(currency)
+	if currency == "" || len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	var prices []types.ProductPrice
+	if err := s.db.Where("product_id IN ? AND currency = ?", ids, currency).Find(&prices).Error; err != nil {
+		return err
+	}
+
+	fixed := make(map[uint]int64, len(prices))
+	for _, price := range prices {
+		fixed[price.ProductID] = price.Amount
+	}
+
+	// rates are only loaded once a price actually has to be converted
+	var rates []types.ExchangeRate
+	loaded := false
+	rate := func(from string) (*big.Rat, error) {
+		if !loaded {
+			var err error
+			if rates, err = s.GetExchangeRates(); err != nil {
+				return nil, err
+			}
+			loaded = true
+		}
+		return findRate(rates, from, currency)
+	}
+
+	for i := range products {
+		product := &products[i]
+		if strings.EqualFold(product.Price.Currency, currency) {
+			continue
+		}
+		base := product.Price
+
+		if amount, ok := fixed[product.ID]; ok {
+			product.Price = New(amount, currency)
+		} else {
+			r, err := rate(base.Currency)
+			if err != nil {
+				return err
+			}
+			product.Price = Convert(base, currency, r)
+		}
+
+		// an override of the product's own price follows its localized price, other overrides are converted
+		for j := range product.Variants {
+			override := product.Variants[j].PriceOverride
+			if override == nil {
+				continue
+			}
+			localized := product.Price.Amount
+			if *override != base.Amount {
+				r, err := rate(base.Currency)
+				if err != nil {
+					return err
+				}
+				localized = Convert(New(*override, base.Currency), currency, r).Amount
+			}
+			product.Variants[j].PriceOverride = &localized
+		}
+	}
+
+	return nil
+}
+
+func findRate(rates []types.ExchangeRate, from, to string) (*big.Rat, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	parsed := make(map[[2]string]*big.Rat, len(rates))
+	for _, rate := range rates {
+		if r, ok := new(big.Rat).SetString(rate.Rate); ok && r.Sign() > 0 {
+			parsed[[2]string{rate.Base, rate.Quote}] = r
+		}
+	}
+
+	if r, ok := parsed[[2]string{from, to}]; ok {
+		return r, nil
+	}
+
+	if r, ok := parsed[[2]string{to, from}]; ok {
+		return new(big.Rat).Inv(r), nil
+	}
+
+	// cross both currencies over a base that quotes them, e.g. EUR -> GBP through USD
+	// the rates are walked in their order so the same base is picked every time
+	for _, rate := range rates {
+		fromRate, ok := parsed[[2]string{rate.Base, rate.Quote}]
+		if !ok || rate.Quote != from {
+			continue
+		}
+		if toRate, ok := parsed[[2]string{rate.Base, to}]; ok {
+			return new(big.Rat).Quo(toRate, fromRate), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no exchange rate from %s to %s", from, to)
+}
@@ -0,0 +1,386 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/address"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/cart"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/promotion"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store        types.OrderStore
+	cartStore    types.CartStore
+	addressStore types.AddressStore
+	pricing      *cart.Pricing
+	userStore    types.UserStore
+}
+
+func NewHandler(store types.OrderStore, cartStore types.CartStore, addressStore types.AddressStore, pricing *cart.Pricing, userStore types.UserStore) *Handler {
+	return &Handler{store: store, cartStore: cartStore, addressStore: addressStore, pricing: pricing, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/checkout", auth.WithJWTAuth(h.handleCheckout, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/orders", auth.WithJWTAuth(h.handleGetMyOrders, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/orders/{id:[0-9]+}", auth.WithJWTAuth(h.handleGetMyOrder, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/orders/{id:[0-9]+}/cancel", auth.WithJWTAuth(h.handleCancelMyOrder, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/orders", auth.WithRoles(h.handleGetOrders, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}", auth.WithRoles(h.handleGetOrder, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/transitions", auth.WithRoles(h.handleTransitionOrder, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary Checkout
+// @Description Turns the cart of the user into a pending order and reserves its stock, the cart is empty afterwards.
+// @Description The order keeps a copy of the shipping and billing address, the defaults of the user unless others are chosen.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param checkoutPayload body types.CheckoutPayload true "Checkout payload"
+// @Success 201 {object} types.Order
+// @Failure 400 {object} map[string]string "Empty cart, no shipping address or method, not enough stock or a used up promotion"
+// @Router /checkout [post]
+func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	var payload types.CheckoutPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	userCart, err := h.cartStore.GetCartByUserID(userID)
+	if err != nil {
+		if errors.Is(err, cart.ErrNotFound) {
+			utils.WriteError(w, http.StatusBadRequest, ErrEmptyCart)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	shippingAddress, billingAddress, err := h.addresses(userID, payload)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// the order is placed at today's prices and promotions, not the ones the cart was last saved with,
+	// and taxed and shipped for the shipping address
+	if shippingAddress != nil {
+		err = h.pricing.SetDestination(userCart, shippingAddress.Country, shippingAddress.Region)
+	} else {
+		err = h.pricing.Recalculate(userCart)
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if userCart.ShippingMethodID != nil && shippingAddress == nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("choose a shipping address"))
+		return
+	}
+	if err := h.pricing.ReadyToShip(userCart); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := h.store.CreateOrder(userCart, types.OrderDetails{
+		Note:            payload.Note,
+		ShippingAddress: address.Snapshot(shippingAddress),
+		BillingAddress:  address.Snapshot(billingAddress),
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmptyCart) || errors.Is(err, inventory.ErrInsufficientStock) || errors.Is(err, promotion.ErrUsedUp) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, order)
+}
+
+// addresses finds the shipping and billing address of the checkout. The defaults of the user stand in
+// for addresses the payload doesn't name, the shipping address for a missing billing address.
+func (h *Handler) addresses(userID int, payload types.CheckoutPayload) (*types.Address, *types.Address, error) {
+	shipping, billing, err := h.addressStore.GetDefaultAddresses(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	find := func(id uint) (*types.Address, error) {
+		found, err := h.addressStore.GetAddressByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if found.UserID != userID {
+			return nil, fmt.Errorf("address not found")
+		}
+		return found, nil
+	}
+	if payload.ShippingAddressID != nil {
+		if shipping, err = find(*payload.ShippingAddressID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if payload.BillingAddressID != nil {
+		if billing, err = find(*payload.BillingAddressID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if billing == nil {
+		billing = shipping
+	}
+	return shipping, billing, nil
+}
+
+// @Summary My orders
+// @Description Lists the orders of the logged in user, newest first
+// @Tags Orders
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only orders in this status"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Orders and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /me/orders [get]
+func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	h.writeOrders(w, r, types.OrderFilter{UserID: &userID, Status: r.URL.Query().Get("status")})
+}
+
+// @Summary My order
+// @Description Returns an order of the logged in user with its items and status history
+// @Tags Orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} types.Order
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /me/orders/{id} [get]
+func (h *Handler) handleGetMyOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.myOrder(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// @Summary Cancel my order
+// @Description Cancels a pending order of the logged in user and gives its stock back, paid orders are refunded by an operator
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param cancelOrderPayload body types.CancelOrderPayload false "Reason"
+// @Success 200 {object} types.Order
+// @Failure 400 {object} map[string]string "Order can't be cancelled"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /me/orders/{id}/cancel [post]
+func (h *Handler) handleCancelMyOrder(w http.ResponseWriter, r *http.Request) {
+	var payload types.CancelOrderPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	order, err := h.myOrder(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if order.Status != StatusPending {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("only pending orders can be cancelled, this one is %s", order.Status))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	order, err = h.store.TransitionOrder(order.ID, StatusCancelled, payload.Note, &userID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// @Summary List orders
+// @Description Lists all orders, newest first
+// @Tags Orders
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only orders in this status"
+// @Param userId query int false "Only orders of this user"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Orders and cursors"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Router /orders [get]
+func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+	filter := types.OrderFilter{Status: r.URL.Query().Get("status")}
+	if v := r.URL.Query().Get("userId"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid userId"))
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	h.writeOrders(w, r, filter)
+}
+
+// @Summary Get order
+// @Description Returns an order with its items and status history
+// @Tags Orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} types.Order
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /orders/{id} [get]
+func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	order, err := h.store.GetOrderByID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// @Summary Change order status
+// @Description Moves an order along pending → paid → fulfilled → delivered, or cancels or refunds it.
+// @Description Fulfilling ships the reserved stock, cancelling and refunding gives reserved stock back.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param orderTransitionPayload body types.OrderTransitionPayload true "Order transition payload"
+// @Success 200 {object} types.Order
+// @Failure 400 {object} map[string]string "Transition not allowed"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /orders/{id}/transitions [post]
+func (h *Handler) handleTransitionOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	var payload types.OrderTransitionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if _, err := h.store.GetOrderByID(uint(id)); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	order, err := h.store.TransitionOrder(uint(id), payload.Status, payload.Note, &userID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+func (h *Handler) writeOrders(w http.ResponseWriter, r *http.Request, filter types.OrderFilter) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return
+		}
+		size = n
+	}
+
+	orders, page, err := h.store.GetOrders(filter, r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": orders, "cursor": page})
+}
+
+// myOrder loads the order of the path, orders of other users are reported as not found
+func (h *Handler) myOrder(r *http.Request) (*types.Order, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	order, err := h.store.GetOrderByID(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != auth.GetUserIDFromContext(r.Context()) {
+		return nil, fmt.Errorf("order not found")
+	}
+	return order, nil
+}
@@ -0,0 +1,74 @@
+package order
+
+import (
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusPaid      = "paid"
+	StatusFulfilled = "fulfilled"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+	StatusRefunded  = "refunded"
+)
+
+var ErrInvalidTransition = errors.New("invalid order transition")
+
+// ErrStockReleased is returned when an order is paid after its reservations expired
+var ErrStockReleased = errors.New("stock of the order was released")
+
+// transitions lists the statuses an order can move to from each status. Paid orders aren't cancelled
+// but refunded, goods that already left the warehouse come back through returns.
+var transitions = map[string][]string{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusFulfilled, StatusRefunded},
+	StatusFulfilled: {StatusDelivered, StatusRefunded},
+	StatusDelivered: {StatusRefunded},
+}
+
+// CanTransition reports whether an order in status from may move to status to
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// PendingTTL is how long an order may wait for its payment before it's cancelled and its stock released,
+// it is also the TTL of the order's reservations. ORDER_PENDING_TTL of 0 keeps pending orders forever.
+func PendingTTL() time.Duration {
+	if v := os.Getenv("ORDER_PENDING_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err == nil && ttl >= 0 {
+			return ttl
+		}
+		log.Printf("invalid ORDER_PENDING_TTL %q, using 24h", v)
+	}
+	return 24 * time.Hour
+}
+
+// CancelPendingOrdersEvery cancels unpaid orders older than PendingTTL until the process exits
+func CancelPendingOrdersEvery(store types.OrderStore, interval time.Duration) {
+	ttl := PendingTTL()
+	if ttl == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cancelled, err := store.CancelPendingOrders(time.Now().Add(-ttl))
+		if err != nil {
+			log.Printf("failed to cancel pending orders: %v", err)
+			continue
+		}
+		if cancelled > 0 {
+			log.Printf("cancelled %d unpaid orders", cancelled)
+		}
+	}
+}
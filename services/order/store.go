@@ -0,0 +1,310 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/promotion"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrEmptyCart = errors.New("cart is empty")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetOrders(filter types.OrderFilter, cursor string, limit int) ([]types.Order, *types.CursorPage, error) {
+	keyset := pagination.Keyset{Column: "id", Desc: true, Filter: filter, Limit: limit, Cursor: cursor}
+	query := s.db.Model(&types.Order{}).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	return pagination.Paginate(query, keyset, func(order types.Order) (any, uint) {
+		return order.ID, order.ID
+	})
+}
+
+func (s *Store) GetOrderByID(id uint) (*types.Order, error) {
+	var order types.Order
+	result := s.db.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Shipments.Updates", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&order, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, result.Error
+	}
+	return &order, nil
+}
+
+// CreateOrder turns a recalculated cart of a user into a pending order. The stock of every item is
+// reserved, the promotions are redeemed and the cart is emptied in the same transaction, nothing
+// changes when one item is short or a promotion got used up.
+func (s *Store) CreateOrder(cart *types.Cart, details types.OrderDetails) (*types.Order, error) {
+	if cart.UserID == nil {
+		return nil, fmt.Errorf("only carts of users can be checked out")
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	order := &types.Order{
+		UserID:           *cart.UserID,
+		Status:           StatusPending,
+		ItemCount:        cart.ItemCount,
+		Subtotal:         cart.Subtotal,
+		Discount:         cart.Discount,
+		Discounts:        cart.Discounts,
+		FreeShipping:     cart.FreeShipping,
+		Tax:              cart.Tax,
+		Taxes:            cart.Taxes,
+		TaxInclusive:     cart.TaxInclusive,
+		Total:            cart.Total,
+		Note:             details.Note,
+		ShippingAddress:  details.ShippingAddress,
+		BillingAddress:   details.BillingAddress,
+		ShippingMethodID: cart.ShippingMethodID,
+		Shipping:         cart.Shipping,
+	}
+	if cart.CouponCode != nil {
+		order.CouponCode = *cart.CouponCode
+	}
+
+	// the nearest allocation rule ships from the warehouses closest to the customer
+	var destination *types.Destination
+	if details.ShippingAddress != nil {
+		destination = &types.Destination{Country: details.ShippingAddress.Country, Region: details.ShippingAddress.Region}
+	}
+
+	var movements []*types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+			return err
+		}
+
+		items := make([]types.OrderItem, 0, len(cart.Items))
+		for _, item := range cart.Items {
+			reservation, movement, err := inventory.ReserveTx(tx, types.ReservationRequest{
+				ProductID:   item.ProductID,
+				VariantID:   item.VariantID,
+				Quantity:    item.Quantity,
+				Reference:   fmt.Sprintf("order:%d", order.ID),
+				TTL:         PendingTTL(),
+				Destination: destination,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", item.Name, err)
+			}
+			movements = append(movements, movement)
+
+			items = append(items, types.OrderItem{
+				OrderID:       order.ID,
+				ProductID:     item.ProductID,
+				VariantID:     item.VariantID,
+				Name:          item.Name,
+				SKU:           item.SKU,
+				ImageURL:      item.ImageURL,
+				Quantity:      item.Quantity,
+				UnitPrice:     item.UnitPrice,
+				Total:         item.Total,
+				Discount:      item.Discount,
+				Discounts:     item.Discounts,
+				TaxClass:      item.TaxClass,
+				Tax:           item.Tax,
+				Taxes:         item.Taxes,
+				ReservationID: &reservation.ID,
+			})
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return err
+		}
+		order.Items = items
+
+		if err := promotion.RedeemTx(tx, order); err != nil {
+			return err
+		}
+
+		transition := types.OrderTransition{OrderID: order.ID, To: StatusPending, UserID: cart.UserID}
+		if err := tx.Create(&transition).Error; err != nil {
+			return err
+		}
+		order.Transitions = []types.OrderTransition{transition}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&types.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&types.Cart{}).Where("id = ?", cart.ID).
+			Updates(map[string]any{
+				"item_count":         0,
+				"coupon_code":        nil,
+				"subtotal_amount":    0,
+				"discount_amount":    0,
+				"discounts":          nil,
+				"free_shipping":      false,
+				"tax_amount":         0,
+				"taxes":              nil,
+				"total_amount":       0,
+				"shipping_method_id": nil,
+				"shipping_amount":    0,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inventory.CheckStock(s.db, movements...)
+	dispatch(*order, "")
+	return order, nil
+}
+
+// TransitionOrder moves the order to the status and records the step. Paid orders keep their reserved
+// stock until it ships, cancelled and refunded orders give it back, fulfilled orders ship it. Cancelled orders don't count towards promotion limits.
+func (s *Store) TransitionOrder(id uint, status, note string, userID *int) (*types.Order, error) {
+	var from string
+	var movements []*types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var order types.Order
+		if err := tx.Preload("Items").First(&order, id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("order not found")
+			}
+			return err
+		}
+
+		from = order.Status
+		if !CanTransition(from, status) {
+			return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, status)
+		}
+
+		// the status in the condition keeps two concurrent transitions from both passing
+		result := tx.Model(&types.Order{}).Where("id = ? AND status = ?", id, from).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: order %d changed meanwhile", ErrInvalidTransition, id)
+		}
+
+		for _, item := range order.Items {
+			if item.ReservationID == nil {
+				continue
+			}
+
+			var movement *types.StockMovement
+			var err error
+			switch status {
+			case StatusPaid:
+				// the reservations expire with the pending order, a late payment finds its stock gone
+				kept, err := inventory.KeepTx(tx, *item.ReservationID)
+				if err != nil {
+					return err
+				}
+				if !kept {
+					return ErrStockReleased
+				}
+				continue
+			case StatusCancelled, StatusRefunded:
+				// shipped reservations aren't active anymore and stay as they are
+				movement, err = inventory.ReleaseTx(tx, *item.ReservationID, inventory.ReservationReleased)
+			case StatusFulfilled:
+				movement, err = inventory.ShipTx(tx, *item.ReservationID)
+			}
+			if err != nil {
+				return err
+			}
+			movements = append(movements, movement)
+		}
+
+		if status == StatusCancelled {
+			if err := promotion.ReleaseTx(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&types.OrderTransition{
+			OrderID: id,
+			From:    from,
+			To:      status,
+			Note:    note,
+			UserID:  userID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inventory.CheckStock(s.db, movements...)
+
+	order, err := s.GetOrderByID(id)
+	if err != nil {
+		return nil, err
+	}
+	dispatch(*order, from)
+	return order, nil
+}
+
+// CancelPendingOrders cancels the orders that are still unpaid since before the given time
+func (s *Store) CancelPendingOrders(before time.Time) (int, error) {
+	var ids []uint
+	result := s.db.Model(&types.Order{}).
+		Where("status = ? AND created_at < ?", StatusPending, before).
+		Pluck("id", &ids)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		_, err := s.TransitionOrder(id, StatusCancelled, "payment not received in time", nil)
+		if errors.Is(err, ErrInvalidTransition) {
+			// paid while we were looking
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+// dispatch emits order.<status> for the status the order is in now
+func dispatch(order types.Order, from string) {
+	event.Dispatch(types.Event{
+		Name: "order." + order.Status,
+		Payload: types.OrderEvent{
+			Order: order,
+			From:  from,
+			To:    order.Status,
+		},
+	})
+}
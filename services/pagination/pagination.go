@@ -0,0 +1,231 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/configs"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the row a page starts after, Backward cursors walk towards the start of the listing.
+// Filter is the hash of the filter of the listing the cursor was made for.
+type Cursor struct {
+	Column   string
+	Desc     bool
+	Filter   string
+	Value    any
+	ID       uint
+	Backward bool
+}
+
+// Keyset describes a listing ordered by (Column, id), Column must never come from user input directly.
+// Filter is what narrows the listing down, cursors only work with the filter they were made with.
+type Keyset struct {
+	Column string
+	Desc   bool
+	Filter any
+	Limit  int
+	Cursor string
+}
+
+type cursorPayload struct {
+	Column   string          `json:"c"`
+	Desc     bool            `json:"d,omitempty"`
+	Filter   string          `json:"f,omitempty"`
+	Type     string          `json:"t,omitempty"`
+	Value    json.RawMessage `json:"v"`
+	ID       uint            `json:"i"`
+	Backward bool            `json:"b,omitempty"`
+}
+
+// Encode signs a cursor so clients can pass it back but not forge one
+func Encode(cursor Cursor) (string, error) {
+	payload := cursorPayload{
+		Column:   cursor.Column,
+		Desc:     cursor.Desc,
+		Filter:   cursor.Filter,
+		ID:       cursor.ID,
+		Backward: cursor.Backward,
+	}
+
+	value := cursor.Value
+	if t, ok := value.(time.Time); ok {
+		payload.Type = "time"
+		value = t.Format(time.RFC3339Nano)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	payload.Value = raw
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + sign(encoded), nil
+}
+
+// Decode verifies and unpacks a cursor created by Encode
+func Decode(token string) (*Cursor, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{
+		Column:   payload.Column,
+		Desc:     payload.Desc,
+		Filter:   payload.Filter,
+		ID:       payload.ID,
+		Backward: payload.Backward,
+	}
+
+	if payload.Type == "time" {
+		var s string
+		if err := json.Unmarshal(payload.Value, &s); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Value = t
+	} else if err := json.Unmarshal(payload.Value, &cursor.Value); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// Paginate loads one page of a keyset listing. key returns the sort value and id of a row,
+// they are what the next and previous cursors are built from.
+func Paginate[T any](query *gorm.DB, keyset Keyset, key func(T) (any, uint)) ([]T, *types.CursorPage, error) {
+	filter, err := hashFilter(keyset.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cursor *Cursor
+	if keyset.Cursor != "" {
+		c, err := Decode(keyset.Cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.Column != keyset.Column || c.Desc != keyset.Desc {
+			return nil, nil, fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalidCursor)
+		}
+		if c.Filter != filter {
+			return nil, nil, fmt.Errorf("%w: cursor belongs to a different filter", ErrInvalidCursor)
+		}
+		cursor = c
+	}
+
+	backward := cursor != nil && cursor.Backward
+
+	// walking backward reads the rows in reverse order and flips them afterwards
+	desc := keyset.Desc != backward
+	direction, operator := "ASC", ">"
+	if desc {
+		direction, operator = "DESC", "<"
+	}
+
+	if cursor != nil {
+		if keyset.Column == "id" {
+			query = query.Where(fmt.Sprintf("id %s ?", operator), cursor.ID)
+		} else {
+			query = query.Where(
+				fmt.Sprintf("%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?)", keyset.Column, operator),
+				cursor.Value, cursor.Value, cursor.ID,
+			)
+		}
+	}
+
+	if keyset.Column != "id" {
+		query = query.Order(fmt.Sprintf("%s %s", keyset.Column, direction))
+	}
+	query = query.Order(fmt.Sprintf("id %s", direction))
+
+	var rows []T
+	if err := query.Limit(keyset.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	hasMore := len(rows) > keyset.Limit
+	if hasMore {
+		rows = rows[:keyset.Limit]
+	}
+
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &types.CursorPage{}
+	if len(rows) == 0 {
+		return rows, page, nil
+	}
+
+	if backward || hasMore {
+		value, id := key(rows[len(rows)-1])
+		next, err := Encode(Cursor{Column: keyset.Column, Desc: keyset.Desc, Filter: filter, Value: value, ID: id})
+		if err != nil {
+			return nil, nil, err
+		}
+		page.Next = next
+	}
+
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		value, id := key(rows[0])
+		prev, err := Encode(Cursor{Column: keyset.Column, Desc: keyset.Desc, Filter: filter, Value: value, ID: id, Backward: true})
+		if err != nil {
+			return nil, nil, err
+		}
+		page.Prev = prev
+	}
+
+	return rows, page, nil
+}
+
+// hashFilter sums up a filter for the cursors of its listing, a listing without one has no hash
+func hashFilter(filter any) (string, error) {
+	if filter == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(filter)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, []byte(configs.Envs.JWTSecret))
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,169 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Fake keeps payment intents in memory for development and tests. Its webhooks are JSON encoded
+// types.PaymentEvent bodies signed with Sign in the Fake-Signature header.
+type Fake struct {
+	mu       sync.Mutex
+	secret   string
+	capture  string
+	intents  map[string]*types.PaymentIntent
+	keys     map[string]string
+	refunded map[string]int64
+	refunds  map[string]types.PaymentRefund
+	next     int
+}
+
+func NewFake(secret, capture string) *Fake {
+	return &Fake{
+		secret:   secret,
+		capture:  capture,
+		intents:  make(map[string]*types.PaymentIntent),
+		keys:     make(map[string]string),
+		refunded: make(map[string]int64),
+		refunds:  make(map[string]types.PaymentRefund),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateIntent(order types.Order, idempotencyKey string) (*types.PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.keys[idempotencyKey]; ok {
+		intent := *f.intents[id]
+		return &intent, nil
+	}
+
+	f.next++
+	id := fmt.Sprintf("pi_fake_%d", f.next)
+	f.intents[id] = &types.PaymentIntent{
+		ID:           id,
+		Status:       StatusPending,
+		Amount:       order.Total,
+		ClientSecret: id + "_secret",
+	}
+	f.keys[idempotencyKey] = id
+
+	intent := *f.intents[id]
+	return &intent, nil
+}
+
+func (f *Fake) GetIntent(intentID string) (*types.PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("payment intent %s not found", intentID)
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+func (f *Fake) Capture(intentID string) (*types.PaymentIntent, error) {
+	return f.move(intentID, StatusAuthorized, StatusSucceeded)
+}
+
+func (f *Fake) Cancel(intentID string) (*types.PaymentIntent, error) {
+	intent, err := f.move(intentID, StatusPending, StatusCancelled)
+	if err == nil {
+		return intent, nil
+	}
+	return f.move(intentID, StatusAuthorized, StatusCancelled)
+}
+
+// Refund gives back part or all of a captured intent, the intent is refunded once nothing is left
+func (f *Fake) Refund(intentID string, amount types.Money, idempotencyKey string) (*types.PaymentRefund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if refund, ok := f.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		return &refund, nil
+	}
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("payment intent %s not found", intentID)
+	}
+	if intent.Status != StatusSucceeded {
+		return nil, fmt.Errorf("payment intent %s is %s", intentID, intent.Status)
+	}
+	if amount.Amount <= 0 || f.refunded[intentID]+amount.Amount > intent.Amount.Amount {
+		return nil, fmt.Errorf("refund of %d exceeds what is left of payment intent %s", amount.Amount, intentID)
+	}
+
+	f.refunded[intentID] += amount.Amount
+	if f.refunded[intentID] == intent.Amount.Amount {
+		intent.Status = StatusRefunded
+	}
+	refund := types.PaymentRefund{ID: fmt.Sprintf("re_%s_%d", intentID, f.refunded[intentID]), Status: StatusSucceeded, Amount: amount}
+	if idempotencyKey != "" {
+		f.refunds[idempotencyKey] = refund
+	}
+	return &refund, nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header) (*types.PaymentEvent, error) {
+	if !hmac.Equal([]byte(header.Get("Fake-Signature")), []byte(f.Sign(payload))) {
+		return nil, fmt.Errorf("invalid webhook signature")
+	}
+
+	var event types.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.ID == "" {
+		return nil, fmt.Errorf("webhook event has no ID")
+	}
+
+	// a webhook is what the customer did with the intent, the intent follows it
+	f.mu.Lock()
+	if intent, ok := f.intents[event.IntentID]; ok {
+		switch event.Type {
+		case EventAuthorized, EventSucceeded, EventCancelled, EventRefunded:
+			intent.Status = event.Type
+		}
+	}
+	f.mu.Unlock()
+
+	return &event, nil
+}
+
+// Sign is the Fake-Signature of a webhook body
+func (f *Fake) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) move(intentID, from, to string) (*types.PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("payment intent %s not found", intentID)
+	}
+	if intent.Status != from {
+		return nil, fmt.Errorf("payment intent %s is %s", intentID, intent.Status)
+	}
+	intent.Status = to
+
+	moved := *intent
+	return &moved, nil
+}
@@ -0,0 +1,359 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Processor keeps payments and orders in line with what the provider reports
+type Processor struct {
+	store    types.PaymentStore
+	orders   types.OrderStore
+	provider types.PaymentProvider
+}
+
+func NewProcessor(store types.PaymentStore, orders types.OrderStore, provider types.PaymentProvider) *Processor {
+	return &Processor{store: store, orders: orders, provider: provider}
+}
+
+// Pay starts a payment of the order total, the client finishes it with the returned client secret.
+// A payment of the order that is still open is handed out again, a second one could charge twice.
+func (p *Processor) Pay(o *types.Order) (*types.Payment, error) {
+	if o.Status != order.StatusPending {
+		return nil, fmt.Errorf("only pending orders can be paid, this one is %s", o.Status)
+	}
+
+	payments, err := p.store.GetPaymentsByOrderID(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range payments {
+		payment := &payments[i]
+		if payment.Status != StatusPending && payment.Status != StatusAuthorized {
+			continue
+		}
+		intent, err := p.provider.GetIntent(payment.ProviderID)
+		if err != nil {
+			return nil, err
+		}
+		payment.ClientSecret = intent.ClientSecret
+		return payment, nil
+	}
+
+	intent, err := p.provider.CreateIntent(*o, fmt.Sprintf("order-%d-payment-%d", o.ID, len(payments)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &types.Payment{
+		OrderID:    o.ID,
+		Provider:   p.provider.Name(),
+		ProviderID: intent.ID,
+		Status:     intent.Status,
+		Amount:     o.Total,
+	}
+	if err := p.store.CreatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	payment.ClientSecret = intent.ClientSecret
+	return payment, nil
+}
+
+// Capture collects an authorized payment
+func (p *Processor) Capture(payment *types.Payment) error {
+	if payment.Status != StatusAuthorized {
+		return fmt.Errorf("only authorized payments can be captured, this one is %s", payment.Status)
+	}
+
+	intent, err := p.provider.Capture(payment.ProviderID)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = intent.Status
+	return p.store.UpdatePayment(payment)
+}
+
+// Handle applies a verified webhook event. Events of payments that weren't started here are ignored.
+func (p *Processor) Handle(e *types.PaymentEvent) error {
+	if e.Type == "" {
+		return nil
+	}
+
+	payment, err := p.store.GetPaymentByProviderID(p.provider.Name(), e.IntentID)
+	if err != nil {
+		log.Printf("ignoring %s event %s: %v", e.Type, e.ID, err)
+		return nil
+	}
+
+	switch e.Type {
+	case EventAuthorized, EventSucceeded:
+		// events can arrive out of order, a refunded payment stays refunded
+		if payment.Status == StatusRefunded || payment.Status == StatusSucceeded {
+			return nil
+		}
+		payment.Status = e.Type
+		if err := p.store.UpdatePayment(payment); err != nil {
+			return err
+		}
+		return p.paid(payment)
+
+	case EventFailed:
+		payment.Status = StatusFailed
+		payment.FailureMessage = e.Message
+		return p.store.UpdatePayment(payment)
+
+	case EventCancelled:
+		payment.Status = StatusCancelled
+		return p.store.UpdatePayment(payment)
+
+	case EventRefunded:
+		// the event carries the refunded amount so far, refunds given back here are known already
+		refund := e.Amount - payment.RefundedAmount
+		payment.RefundedAmount = e.Amount
+		if payment.RefundedAmount < payment.Amount.Amount {
+			// partial refunds leave the order as it is
+			if err := p.store.UpdatePayment(payment); err != nil {
+				return err
+			}
+			p.refunded(payment, refund)
+			return nil
+		}
+		payment.Status = StatusRefunded
+		if err := p.store.UpdatePayment(payment); err != nil {
+			return err
+		}
+		p.refunded(payment, refund)
+
+		_, err := p.orders.TransitionOrder(payment.OrderID, order.StatusRefunded, "refunded through "+p.provider.Name(), nil)
+		if errors.Is(err, order.ErrInvalidTransition) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// paid moves the order of an authorized or captured payment to paid. Money for an order that was
+// cancelled or refunded in the meantime goes back, its stock isn't reserved anymore, and so does a
+// second payment of an order another payment paid already.
+func (p *Processor) paid(payment *types.Payment) error {
+	o, err := p.orders.GetOrderByID(payment.OrderID)
+	if err != nil {
+		return err
+	}
+
+	switch o.Status {
+	case order.StatusPending:
+		_, err := p.orders.TransitionOrder(o.ID, order.StatusPaid, "payment "+payment.ProviderID, nil)
+		if errors.Is(err, order.ErrInvalidTransition) {
+			return nil
+		}
+		if errors.Is(err, order.ErrStockReleased) {
+			log.Printf("stock of order %d expired before payment %d arrived, giving the money back", o.ID, payment.ID)
+			// cancelling the order gives its payments back
+			_, err := p.orders.TransitionOrder(o.ID, order.StatusCancelled, "stock expired before the payment arrived", nil)
+			if !errors.Is(err, order.ErrInvalidTransition) {
+				return err
+			}
+			// cancelled meanwhile, the payment may have been given back already
+			payment, err := p.store.GetPaymentByID(payment.ID)
+			if err != nil {
+				return err
+			}
+			return p.paid(payment)
+		}
+		return err
+	case order.StatusCancelled, order.StatusRefunded:
+		log.Printf("order %d was %s before payment %d arrived, giving the money back", o.ID, o.Status, payment.ID)
+		return p.giveBack(payment)
+	}
+
+	// the order is paid or further, the payment that paid it keeps its money
+	payments, err := p.store.GetPaymentsByOrderID(o.ID)
+	if err != nil {
+		return err
+	}
+	for _, other := range payments {
+		if other.ID != payment.ID && (other.Status == StatusAuthorized || other.Status == StatusSucceeded) {
+			log.Printf("order %d was paid by payment %d already, giving payment %d back", o.ID, other.ID, payment.ID)
+			return p.giveBack(payment)
+		}
+	}
+	return nil
+}
+
+// giveBack refunds a captured payment and cancels one that is only authorized or still open
+func (p *Processor) giveBack(payment *types.Payment) error {
+	switch payment.Status {
+	case StatusSucceeded:
+		amount := payment.Amount
+		amount.Amount -= payment.RefundedAmount
+		if _, err := p.provider.Refund(payment.ProviderID, amount, ""); err != nil {
+			return err
+		}
+		payment.Status = StatusRefunded
+		payment.RefundedAmount = payment.Amount.Amount
+		if err := p.store.UpdatePayment(payment); err != nil {
+			return err
+		}
+		p.refunded(payment, amount.Amount)
+		return nil
+	case StatusPending, StatusAuthorized:
+		if _, err := p.provider.Cancel(payment.ProviderID); err != nil {
+			return err
+		}
+		payment.Status = StatusCancelled
+	default:
+		return nil
+	}
+	return p.store.UpdatePayment(payment)
+}
+
+// Refund gives part or all of what was captured for the order back, e.g. for returned goods. The amount
+// is taken from the captured payments in turn, an order with nothing captured left is refunded.
+// A key makes the refund idempotent: a retry with the same key only refunds what the first attempt
+// didn't get to, and the provider sees the same key for each payment.
+func (p *Processor) Refund(o *types.Order, amount types.Money, note, key string) error {
+	payments, err := p.store.GetPaymentsByOrderID(o.ID)
+	if err != nil {
+		return err
+	}
+
+	left := amount.Amount
+	done := make(map[uint]bool)
+	if key != "" {
+		refunds, err := p.store.GetRefundsByKey(key)
+		if err != nil {
+			return err
+		}
+		for _, refund := range refunds {
+			done[refund.PaymentID] = true
+			left -= refund.Amount.Amount
+		}
+	}
+
+	refundable := refundable(payments, amount.Currency)
+	if amount.Amount <= 0 || left < 0 || left > refundable {
+		return fmt.Errorf("refund of %s %s doesn't fit the %s %s captured and not refunded",
+			money.Format(money.New(left, amount.Currency)), amount.Currency, money.Format(money.New(refundable, amount.Currency)), amount.Currency)
+	}
+
+	remaining := left
+	for i := range payments {
+		payment := &payments[i]
+		if remaining == 0 || done[payment.ID] || payment.Status != StatusSucceeded || payment.Amount.Currency != amount.Currency {
+			continue
+		}
+
+		part := min(remaining, payment.Amount.Amount-payment.RefundedAmount)
+		if part <= 0 {
+			continue
+		}
+		idempotencyKey := ""
+		if key != "" {
+			idempotencyKey = fmt.Sprintf("%s-payment-%d", key, payment.ID)
+		}
+		refund, err := p.provider.Refund(payment.ProviderID, money.New(part, amount.Currency), idempotencyKey)
+		if err != nil {
+			return err
+		}
+		payment.RefundedAmount += part
+		if payment.RefundedAmount == payment.Amount.Amount {
+			payment.Status = StatusRefunded
+		}
+		if key != "" {
+			err = p.store.RecordRefund(payment, &types.Refund{Key: key, ProviderID: refund.ID, Amount: money.New(part, amount.Currency)})
+		} else {
+			err = p.store.UpdatePayment(payment)
+		}
+		if err != nil {
+			return err
+		}
+		p.refunded(payment, part)
+		remaining -= part
+	}
+
+	if refundable > left {
+		return nil
+	}
+	_, err = p.orders.TransitionOrder(o.ID, order.StatusRefunded, note, nil)
+	if errors.Is(err, order.ErrInvalidTransition) {
+		return nil
+	}
+	return err
+}
+
+// Refundable is what was captured for the order in currency and not refunded yet
+func (p *Processor) Refundable(o *types.Order, currency string) (int64, error) {
+	payments, err := p.store.GetPaymentsByOrderID(o.ID)
+	if err != nil {
+		return 0, err
+	}
+	return refundable(payments, currency), nil
+}
+
+func refundable(payments []types.Payment, currency string) int64 {
+	total := int64(0)
+	for _, payment := range payments {
+		if payment.Status == StatusSucceeded && payment.Amount.Currency == currency {
+			total += payment.Amount.Amount - payment.RefundedAmount
+		}
+	}
+	return total
+}
+
+// refunded tells the listeners, e.g. the credit notes, about money that went back
+func (p *Processor) refunded(payment *types.Payment, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	event.Dispatch(types.Event{
+		Name:    "payment.refunded",
+		Payload: types.PaymentRefundedEvent{Payment: *payment, Amount: money.New(amount, payment.Amount.Currency)},
+	})
+}
+
+// RegisterListeners captures authorized payments when their order is fulfilled and
+// gives the money of cancelled and refunded orders back
+func (p *Processor) RegisterListeners() {
+	event.Register("order.fulfilled", p.forPayments(func(payment *types.Payment) error {
+		if payment.Status != StatusAuthorized {
+			return nil
+		}
+		return p.Capture(payment)
+	}))
+	event.Register("order.cancelled", p.forPayments(p.giveBack))
+	event.Register("order.refunded", p.forPayments(p.giveBack))
+}
+
+func (p *Processor) forPayments(apply func(*types.Payment) error) types.Listener {
+	return func(e types.Event) {
+		changed, ok := e.Payload.(types.OrderEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+
+		payments, err := p.store.GetPaymentsByOrderID(changed.Order.ID)
+		if err != nil {
+			log.Printf("failed to load the payments of order %d: %v", changed.Order.ID, err)
+			return
+		}
+
+		for i := range payments {
+			if err := apply(&payments[i]); err != nil {
+				log.Printf("%s: payment %d of order %d failed: %v", e.Name, payments[i].ID, changed.Order.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type testEnv struct {
+	db        *gorm.DB
+	fake      *Fake
+	processor *Processor
+	router    *mux.Router
+	order     *types.Order
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&types.Order{}, &types.OrderItem{}, &types.OrderTransition{}, &types.Shipment{}, &types.ShipmentUpdate{},
+		&types.Payment{}, &types.WebhookEvent{}, &types.Refund{}); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &types.Order{UserID: 1, Status: order.StatusPending, Total: money.New(1000, "USD")}
+	if err := db.Create(o).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+	orders := order.NewStore(db)
+	fake := NewFake("whsec_test", CaptureAutomatic)
+	processor := NewProcessor(store, orders, fake)
+
+	router := mux.NewRouter()
+	NewHandler(store, orders, processor, fake, nil).RegisterRoutes(router)
+
+	return &testEnv{db: db, fake: fake, processor: processor, router: router, order: o}
+}
+
+// webhook delivers a signed fake event and returns the status the handler reported
+func (env *testEnv) webhook(t *testing.T, event types.PaymentEvent) string {
+	t.Helper()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/fake", bytes.NewReader(body))
+	req.Header.Set("Fake-Signature", env.fake.Sign(body))
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webhook %s: status %d: %s", event.ID, rec.Code, rec.Body.String())
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response["status"]
+}
+
+func (env *testEnv) pay(t *testing.T) *types.Payment {
+	t.Helper()
+
+	o, err := order.NewStore(env.db).GetOrderByID(env.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := env.processor.Pay(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func (env *testEnv) payment(t *testing.T, id uint) *types.Payment {
+	t.Helper()
+
+	payment, err := NewStore(env.db).GetPaymentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func (env *testEnv) orderStatus(t *testing.T) string {
+	t.Helper()
+
+	o, err := order.NewStore(env.db).GetOrderByID(env.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o.Status
+}
+
+func TestWebhookRedeliveryIsProcessedOnce(t *testing.T) {
+	env := newTestEnv(t)
+	payment := env.pay(t)
+
+	event := types.PaymentEvent{ID: "evt_1", Type: EventSucceeded, IntentID: payment.ProviderID, Amount: 1000}
+	if status := env.webhook(t, event); status != "processed" {
+		t.Fatalf("first delivery: got %q, want processed", status)
+	}
+
+	// a redelivered refund would otherwise be booked again
+	refund := types.PaymentEvent{ID: "evt_2", Type: EventRefunded, IntentID: payment.ProviderID, Amount: 400}
+	for i, want := range []string{"processed", "duplicate", "duplicate"} {
+		if status := env.webhook(t, refund); status != want {
+			t.Fatalf("refund delivery %d: got %q, want %q", i+1, status, want)
+		}
+	}
+
+	var transitions int64
+	env.db.Model(&types.OrderTransition{}).Where("order_id = ? AND \"to\" = ?", env.order.ID, order.StatusPaid).Count(&transitions)
+	if transitions != 1 {
+		t.Fatalf("order was moved to paid %d times, want once", transitions)
+	}
+	if got := env.payment(t, payment.ID); got.RefundedAmount != 400 || got.Status != StatusSucceeded {
+		t.Fatalf("payment is %s with %d refunded, want succeeded with 400", got.Status, got.RefundedAmount)
+	}
+}
+
+func TestOutOfOrderEventsKeepTheLatestStatus(t *testing.T) {
+	env := newTestEnv(t)
+	payment := env.pay(t)
+
+	env.webhook(t, types.PaymentEvent{ID: "evt_succeeded", Type: EventSucceeded, IntentID: payment.ProviderID})
+	env.webhook(t, types.PaymentEvent{ID: "evt_authorized", Type: EventAuthorized, IntentID: payment.ProviderID})
+
+	if got := env.payment(t, payment.ID).Status; got != StatusSucceeded {
+		t.Fatalf("payment is %s after a late authorized event, want succeeded", got)
+	}
+	if got := env.orderStatus(t); got != order.StatusPaid {
+		t.Fatalf("order is %s, want paid", got)
+	}
+}
+
+func TestPayReusesTheOpenPayment(t *testing.T) {
+	env := newTestEnv(t)
+
+	first := env.pay(t)
+	second := env.pay(t)
+	if first.ID != second.ID || second.ClientSecret == "" {
+		t.Fatalf("second Pay made payment %d with secret %q, want payment %d again", second.ID, second.ClientSecret, first.ID)
+	}
+}
+
+func TestPayOnPaidOrder(t *testing.T) {
+	env := newTestEnv(t)
+	payment := env.pay(t)
+	env.webhook(t, types.PaymentEvent{ID: "evt_1", Type: EventSucceeded, IntentID: payment.ProviderID})
+
+	o, err := order.NewStore(env.db).GetOrderByID(env.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.processor.Pay(o); err == nil {
+		t.Fatal("paying a paid order succeeded")
+	}
+}
+
+func TestSecondPaymentOfPaidOrderIsGivenBack(t *testing.T) {
+	env := newTestEnv(t)
+
+	// the customer abandons the first payment, pays a second one and then the first one after all
+	first := env.pay(t)
+	env.webhook(t, types.PaymentEvent{ID: "evt_1", Type: EventCancelled, IntentID: first.ProviderID})
+	second := env.pay(t)
+	if second.ID == first.ID {
+		t.Fatal("a cancelled payment was handed out again")
+	}
+	env.webhook(t, types.PaymentEvent{ID: "evt_2", Type: EventSucceeded, IntentID: second.ProviderID})
+	env.webhook(t, types.PaymentEvent{ID: "evt_3", Type: EventSucceeded, IntentID: first.ProviderID})
+
+	if got := env.payment(t, first.ID); got.Status != StatusRefunded || got.RefundedAmount != 1000 {
+		t.Fatalf("late payment is %s with %d refunded, want refunded with 1000", got.Status, got.RefundedAmount)
+	}
+	if got := env.payment(t, second.ID); got.Status != StatusSucceeded || got.RefundedAmount != 0 {
+		t.Fatalf("paying payment is %s with %d refunded, want succeeded with 0", got.Status, got.RefundedAmount)
+	}
+	if got := env.orderStatus(t); got != order.StatusPaid {
+		t.Fatalf("order is %s, want paid", got)
+	}
+}
+
+func TestRefundRetriedWithSameKey(t *testing.T) {
+	env := newTestEnv(t)
+	payment := env.pay(t)
+	env.webhook(t, types.PaymentEvent{ID: "evt_1", Type: EventSucceeded, IntentID: payment.ProviderID})
+
+	o, err := order.NewStore(env.db).GetOrderByID(env.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := env.processor.Refund(o, money.New(300, "USD"), "return 1", "return-1"); err != nil {
+			t.Fatalf("refund attempt %d: %v", i+1, err)
+		}
+	}
+
+	if got := env.payment(t, payment.ID).RefundedAmount; got != 300 {
+		t.Fatalf("payment has %d refunded, want 300", got)
+	}
+	if got := env.fake.refunded[payment.ProviderID]; got != 300 {
+		t.Fatalf("provider refunded %d, want 300", got)
+	}
+	var refunds int64
+	env.db.Model(&types.Refund{}).Where("key = ?", "return-1").Count(&refunds)
+	if refunds != 1 {
+		t.Fatalf("%d refunds recorded, want 1", refunds)
+	}
+
+	// a provider call that went through before the refund could be recorded isn't paid out twice
+	if _, err := env.fake.Refund(payment.ProviderID, money.New(200, "USD"), "return-2-payment-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.processor.Refund(o, money.New(200, "USD"), "return 2", "return-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.fake.refunded[payment.ProviderID]; got != 500 {
+		t.Fatalf("provider refunded %d, want 500", got)
+	}
+	if got := env.payment(t, payment.ID).RefundedAmount; got != 500 {
+		t.Fatalf("payment has %d refunded, want 500", got)
+	}
+}
@@ -0,0 +1,76 @@
+package payment
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusAuthorized = "authorized"
+	StatusSucceeded  = "succeeded"
+	StatusFailed     = "failed"
+	StatusCancelled  = "cancelled"
+	StatusRefunded   = "refunded"
+)
+
+// Types of the events providers report through their webhooks
+const (
+	EventAuthorized = "authorized"
+	EventSucceeded  = "succeeded"
+	EventFailed     = "failed"
+	EventCancelled  = "cancelled"
+	EventRefunded   = "refunded"
+)
+
+const (
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
+)
+
+// CaptureMethod is PAYMENT_CAPTURE. Manual payments are only authorized at checkout and captured when the order is fulfilled.
+func CaptureMethod() string {
+	if strings.EqualFold(os.Getenv("PAYMENT_CAPTURE"), CaptureManual) {
+		return CaptureManual
+	}
+	return CaptureAutomatic
+}
+
+// New creates the provider from PAYMENT_PROVIDER, stripe is the default once STRIPE_SECRET_KEY is set.
+// The fake provider has to be asked for, its webhooks would otherwise mark orders paid for anyone
+// who knows its secret.
+func New() (types.PaymentProvider, error) {
+	name := strings.ToLower(os.Getenv("PAYMENT_PROVIDER"))
+	if name == "" && os.Getenv("STRIPE_SECRET_KEY") != "" {
+		name = "stripe"
+	}
+
+	switch name {
+	case "stripe":
+		provider, err := NewStripe(StripeConfig{
+			SecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+			WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+			APIURL:        os.Getenv("STRIPE_API_URL"),
+			Capture:       CaptureMethod(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case "fake":
+		secret := os.Getenv("FAKE_WEBHOOK_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("FAKE_WEBHOOK_SECRET is required for the fake payment provider")
+		}
+		log.Println("payments go through the fake provider, set PAYMENT_PROVIDER=stripe to take real payments")
+		return NewFake(secret, CaptureMethod()), nil
+	case "":
+		return nil, fmt.Errorf("PAYMENT_PROVIDER is not set, use stripe or fake")
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+	}
+}
@@ -0,0 +1,172 @@
+package payment
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+const maxWebhookSize = 1 << 16
+
+type Handler struct {
+	store      types.PaymentStore
+	orderStore types.OrderStore
+	processor  *Processor
+	provider   types.PaymentProvider
+	userStore  types.UserStore
+}
+
+func NewHandler(store types.PaymentStore, orderStore types.OrderStore, processor *Processor, provider types.PaymentProvider, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orderStore: orderStore, processor: processor, provider: provider, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/orders/{id:[0-9]+}/payments", auth.WithJWTAuth(h.handleCreatePayment, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/orders/{id:[0-9]+}/payments", auth.WithRoles(h.handleGetPayments, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/payments/{id:[0-9]+}/capture", auth.WithRoles(h.handleCapturePayment, h.userStore, "admin", "operator")).Methods("POST")
+
+	// the provider signs its webhooks, they don't carry a token
+	router.HandleFunc("/webhooks/"+h.provider.Name(), h.handleWebhook).Methods("POST")
+}
+
+// @Summary Pay order
+// @Description Starts a payment of a pending order of the logged in user. The client confirms it with the
+// @Description returned client secret, the order becomes paid once the provider reports the payment.
+// @Tags Payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 201 {object} types.Payment
+// @Failure 400 {object} map[string]string "Order can't be paid"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /me/orders/{id}/payments [post]
+func (h *Handler) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	order, err := h.orderStore.GetOrderByID(uint(id))
+	if err != nil || order.UserID != auth.GetUserIDFromContext(r.Context()) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+
+	payment, err := h.processor.Pay(order)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, payment)
+}
+
+// @Summary Order payments
+// @Description Lists the payments of an order, oldest first
+// @Tags Payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} types.Payment
+// @Router /orders/{id}/payments [get]
+func (h *Handler) handleGetPayments(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	payments, err := h.store.GetPaymentsByOrderID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payments)
+}
+
+// @Summary Capture payment
+// @Description Collects an authorized payment, with PAYMENT_CAPTURE=manual this also happens when the order is fulfilled
+// @Tags Payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment ID"
+// @Success 200 {object} types.Payment
+// @Failure 400 {object} map[string]string "Payment can't be captured"
+// @Failure 404 {object} map[string]string "Payment not found"
+// @Router /payments/{id}/capture [post]
+func (h *Handler) handleCapturePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	payment, err := h.store.GetPaymentByID(uint(id))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.processor.Capture(payment); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payment)
+}
+
+// @Summary Payment webhook
+// @Description Receives the signed events of the payment provider, e.g. /webhooks/stripe. Every event is processed
+// @Description once, redeliveries are acknowledged without doing anything.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string "Event processed or already processed"
+// @Failure 400 {object} map[string]string "Invalid signature or body"
+// @Router /webhooks/stripe [post]
+func (h *Handler) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	event, err := h.provider.VerifyWebhook(payload, r.Header)
+	if err != nil {
+		log.Printf("rejected %s webhook: %v", h.provider.Name(), err)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid webhook"))
+		return
+	}
+
+	record := &types.WebhookEvent{Provider: h.provider.Name(), EventID: event.ID, Type: event.Type}
+	fresh, err := h.store.RecordWebhookEvent(record)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !fresh {
+		utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+		return
+	}
+
+	if err := h.processor.Handle(event); err != nil {
+		// forget the event so the provider's retry gets processed
+		if err := h.store.DeleteWebhookEvent(record.ID); err != nil {
+			log.Printf("failed to forget webhook event %s: %v", event.ID, err)
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "processed"})
+}
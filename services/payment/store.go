@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetPaymentsByOrderID(orderID uint) ([]types.Payment, error) {
+	var payments []types.Payment
+	result := s.db.Where("order_id = ?", orderID).Order("id").Find(&payments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return payments, nil
+}
+
+func (s *Store) GetPaymentByID(id uint) (*types.Payment, error) {
+	var payment types.Payment
+	result := s.db.First(&payment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, result.Error
+	}
+	return &payment, nil
+}
+
+func (s *Store) GetPaymentByProviderID(provider, providerID string) (*types.Payment, error) {
+	var payment types.Payment
+	result := s.db.Where("provider = ? AND provider_id = ?", provider, providerID).First(&payment)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, result.Error
+	}
+	return &payment, nil
+}
+
+func (s *Store) CreatePayment(payment *types.Payment) error {
+	result := s.db.Create(payment)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) UpdatePayment(payment *types.Payment) error {
+	result := s.db.Save(payment)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) GetRefundsByKey(key string) ([]types.Refund, error) {
+	var refunds []types.Refund
+	result := s.db.Where("key = ?", key).Order("id").Find(&refunds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return refunds, nil
+}
+
+func (s *Store) RecordRefund(payment *types.Payment, refund *types.Refund) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(payment).Error; err != nil {
+			return err
+		}
+		refund.PaymentID = payment.ID
+		return tx.Create(refund).Error
+	})
+}
+
+// RecordWebhookEvent stores the event unless it was stored before, false means it's a redelivery.
+// The unique index decides between two deliveries that arrive at the same time.
+func (s *Store) RecordWebhookEvent(event *types.WebhookEvent) (bool, error) {
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *Store) DeleteWebhookEvent(id uint) error {
+	result := s.db.Delete(&types.WebhookEvent{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/client"
+	"github.com/stripe/stripe-go/v72/webhook"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// StripeConfig configures the Stripe adapter, APIURL points it at another server such as stripe-mock
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+	APIURL        string
+	Capture       string
+}
+
+// Stripe takes payments through Stripe payment intents
+type Stripe struct {
+	api           *client.API
+	webhookSecret string
+	capture       string
+}
+
+func NewStripe(config StripeConfig) (*Stripe, error) {
+	if config.SecretKey == "" {
+		return nil, fmt.Errorf("STRIPE_SECRET_KEY is required for stripe payments")
+	}
+
+	var backends *stripe.Backends
+	if config.APIURL != "" {
+		url := strings.TrimSuffix(config.APIURL, "/")
+		backends = &stripe.Backends{
+			API:     stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{URL: stripe.String(url)}),
+			Connect: stripe.GetBackendWithConfig(stripe.ConnectBackend, &stripe.BackendConfig{URL: stripe.String(url)}),
+			Uploads: stripe.GetBackendWithConfig(stripe.UploadsBackend, &stripe.BackendConfig{URL: stripe.String(url)}),
+		}
+	}
+
+	return &Stripe{
+		api:           client.New(config.SecretKey, backends),
+		webhookSecret: config.WebhookSecret,
+		capture:       config.Capture,
+	}, nil
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+func (s *Stripe) CreateIntent(order types.Order, idempotencyKey string) (*types.PaymentIntent, error) {
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(order.Total.Amount),
+		Currency: stripe.String(strings.ToLower(order.Total.Currency)),
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+		Description: stripe.String(fmt.Sprintf("Order %d", order.ID)),
+	}
+	if s.capture == CaptureManual {
+		params.CaptureMethod = stripe.String(string(stripe.PaymentIntentCaptureMethodManual))
+	}
+	params.AddMetadata("order_id", strconv.FormatUint(uint64(order.ID), 10))
+	params.SetIdempotencyKey(idempotencyKey)
+
+	intent, err := s.api.PaymentIntents.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(intent), nil
+}
+
+func (s *Stripe) GetIntent(intentID string) (*types.PaymentIntent, error) {
+	intent, err := s.api.PaymentIntents.Get(intentID, &stripe.PaymentIntentParams{})
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(intent), nil
+}
+
+func (s *Stripe) Capture(intentID string) (*types.PaymentIntent, error) {
+	intent, err := s.api.PaymentIntents.Capture(intentID, &stripe.PaymentIntentCaptureParams{})
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(intent), nil
+}
+
+func (s *Stripe) Cancel(intentID string) (*types.PaymentIntent, error) {
+	intent, err := s.api.PaymentIntents.Cancel(intentID, &stripe.PaymentIntentCancelParams{})
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(intent), nil
+}
+
+func (s *Stripe) Refund(intentID string, amount types.Money, idempotencyKey string) (*types.PaymentRefund, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(intentID),
+		Amount:        stripe.Int64(amount.Amount),
+	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+
+	refund, err := s.api.Refunds.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return &types.PaymentRefund{
+		ID:     refund.ID,
+		Status: string(refund.Status),
+		Amount: types.Money{Amount: refund.Amount, Currency: strings.ToUpper(string(refund.Currency))},
+	}, nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header and turns the payment intent and refund events into payment events
+func (s *Stripe) VerifyWebhook(payload []byte, header http.Header) (*types.PaymentEvent, error) {
+	if s.webhookSecret == "" {
+		return nil, fmt.Errorf("STRIPE_WEBHOOK_SECRET is not set")
+	}
+
+	e, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), s.webhookSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &types.PaymentEvent{ID: e.ID}
+	if e.Data == nil {
+		return event, nil
+	}
+
+	var object struct {
+		ID               string `json:"id"`
+		Amount           int64  `json:"amount"`
+		AmountRefunded   int64  `json:"amount_refunded"`
+		PaymentIntent    string `json:"payment_intent"`
+		LastPaymentError *struct {
+			Message string `json:"message"`
+		} `json:"last_payment_error"`
+	}
+	if err := json.Unmarshal(e.Data.Raw, &object); err != nil {
+		return nil, err
+	}
+
+	event.IntentID = object.ID
+	event.Amount = object.Amount
+	switch e.Type {
+	case "payment_intent.amount_capturable_updated":
+		event.Type = EventAuthorized
+	case "payment_intent.succeeded":
+		event.Type = EventSucceeded
+	case "payment_intent.payment_failed":
+		event.Type = EventFailed
+		if object.LastPaymentError != nil {
+			event.Message = object.LastPaymentError.Message
+		}
+	case "payment_intent.canceled":
+		event.Type = EventCancelled
+	case "charge.refunded":
+		event.Type = EventRefunded
+		event.IntentID = object.PaymentIntent
+		event.Amount = object.AmountRefunded
+	}
+	return event, nil
+}
+
+func stripeIntent(intent *stripe.PaymentIntent) *types.PaymentIntent {
+	status := StatusPending
+	switch intent.Status {
+	case stripe.PaymentIntentStatusRequiresCapture:
+		status = StatusAuthorized
+	case stripe.PaymentIntentStatusSucceeded:
+		status = StatusSucceeded
+	case stripe.PaymentIntentStatusCanceled:
+		status = StatusCancelled
+	}
+
+	return &types.PaymentIntent{
+		ID:           intent.ID,
+		Status:       status,
+		Amount:       types.Money{Amount: intent.Amount, Currency: strings.ToUpper(string(intent.Currency))},
+		ClientSecret: intent.ClientSecret,
+	}
+}
@@ -0,0 +1,233 @@
+package price
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store        types.PriceStore
+	productStore types.ProductStore
+	userStore    types.UserStore
+}
+
+func NewHandler(store types.PriceStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, productStore: productStore, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/price-history", auth.WithRoles(h.handleGetPriceHistory, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/scheduled-prices", auth.WithRoles(h.handleGetScheduledPrices, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/scheduled-prices", auth.WithRoles(h.handleCreateScheduledPrice, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/scheduled-prices/{scheduleId:[0-9]+}/cancel", auth.WithRoles(h.handleCancelScheduledPrice, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary Price history
+// @Description Lists every change of the base price of a product, newest first
+// @Tags Prices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Price changes and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id}/price-history [get]
+func (h *Handler) handleGetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	product, err := h.productFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return
+		}
+		size = n
+	}
+
+	changes, page, err := h.store.GetPriceHistory(product.ID, r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": changes, "cursor": page})
+}
+
+// @Summary Scheduled prices
+// @Description Lists the scheduled prices of a product in the order they start
+// @Tags Prices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param status query string false "Status" Enums(scheduled, active, completed, cancelled)
+// @Success 200 {array} types.ScheduledPrice
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id}/scheduled-prices [get]
+func (h *Handler) handleGetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	product, err := h.productFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	filter := types.ScheduledPriceFilter{ProductID: product.ID, Status: r.URL.Query().Get("status")}
+	switch filter.Status {
+	case "", StatusScheduled, StatusActive, StatusCompleted, StatusCancelled:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be scheduled, active, completed or cancelled"))
+		return
+	}
+
+	prices, err := h.store.GetScheduledPrices(filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, prices)
+}
+
+// @Summary Schedule price
+// @Description Sets the base price of a product at startsAt. With endsAt the price before it comes back then,
+// @Description unless the price was changed again meanwhile. Windows of one product can't overlap.
+// @Tags Prices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param scheduledPricePayload body types.ScheduledPricePayload true "Price and window"
+// @Success 201 {object} types.ScheduledPrice
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Failure 409 {object} map[string]string "Overlaps another scheduled price"
+// @Router /products/{id}/scheduled-prices [post]
+func (h *Handler) handleCreateScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	product, err := h.productFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ScheduledPricePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if base := money.BaseCurrency(); !strings.EqualFold(payload.Price.Currency, base) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("price has to be in the base currency %s", base))
+		return
+	}
+	if payload.EndsAt != nil && !payload.EndsAt.After(time.Now()) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("endsAt must be in the future"))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	scheduled := &types.ScheduledPrice{
+		ProductID: product.ID,
+		Price:     money.New(payload.Price.Amount, payload.Price.Currency),
+		StartsAt:  payload.StartsAt,
+		EndsAt:    payload.EndsAt,
+		Note:      payload.Note,
+		CreatedBy: &userID,
+	}
+	if err := h.store.CreateScheduledPrice(scheduled); err != nil {
+		if errors.Is(err, ErrOverlap) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, scheduled)
+}
+
+// @Summary Cancel scheduled price
+// @Description Drops a scheduled price that didn't start yet, or ends a running one now and puts the previous price back
+// @Tags Prices
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param scheduleId path int true "Scheduled price ID"
+// @Success 200 {object} types.ScheduledPrice
+// @Failure 404 {object} map[string]string "Scheduled price not found"
+// @Failure 409 {object} map[string]string "Scheduled price is over"
+// @Router /products/{id}/scheduled-prices/{scheduleId}/cancel [post]
+func (h *Handler) handleCancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scheduleID, err := parseID(r, "scheduleId")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scheduled, err := h.store.GetScheduledPriceByID(scheduleID)
+	if err != nil || scheduled.ProductID != productID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("scheduled price not found"))
+		return
+	}
+
+	if err := h.store.CancelScheduledPrice(scheduled); err != nil {
+		if errors.Is(err, ErrNotCancellable) || errors.Is(err, ErrChanged) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, scheduled)
+}
+
+func (h *Handler) productFromRequest(r *http.Request) (*types.Product, error) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return h.productStore.GetProductByID(id)
+}
+
+func parseID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
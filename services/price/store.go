@@ -0,0 +1,296 @@
+package price
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusScheduled = "scheduled"
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// sources say what changed the price in a history entry
+const (
+	SourceCreated   = "created"
+	SourceUpdated   = "updated"
+	SourceScheduled = "scheduled"
+	SourceReverted  = "reverted"
+)
+
+var ErrOverlap = errors.New("scheduled price overlaps another one")
+
+var ErrNotCancellable = errors.New("only scheduled or active prices can be cancelled")
+
+// ErrChanged is returned when the scheduler or another request moved the entry on first
+var ErrChanged = errors.New("scheduled price changed meanwhile")
+
+// open are the statuses of entries that still have something to do
+var open = []string{StatusScheduled, StatusActive}
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetPriceHistory lists the price changes of a product, newest first
+func (s *Store) GetPriceHistory(productID uint, cursor string, limit int) ([]types.PriceChange, *types.CursorPage, error) {
+	query := s.db.Model(&types.PriceChange{}).Where("product_id = ?", productID)
+	keyset := pagination.Keyset{Column: "created_at", Desc: true, Filter: productID, Limit: limit, Cursor: cursor}
+	return pagination.Paginate(query, keyset, func(c types.PriceChange) (any, uint) {
+		return c.CreatedAt, c.ID
+	})
+}
+
+// GetScheduledPrices lists scheduled prices in the order they start
+func (s *Store) GetScheduledPrices(filter types.ScheduledPriceFilter) ([]types.ScheduledPrice, error) {
+	query := s.db.Order("starts_at, id")
+	if filter.ProductID != 0 {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var prices []types.ScheduledPrice
+	if err := query.Find(&prices).Error; err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (s *Store) GetScheduledPriceByID(id uint) (*types.ScheduledPrice, error) {
+	var scheduled types.ScheduledPrice
+	result := s.db.First(&scheduled, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("scheduled price not found")
+		}
+		return nil, result.Error
+	}
+	return &scheduled, nil
+}
+
+func (s *Store) CreateScheduledPrice(scheduled *types.ScheduledPrice) error {
+	scheduled.Status = StatusScheduled
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var others []types.ScheduledPrice
+		if err := tx.Where("product_id = ? AND status IN ?", scheduled.ProductID, open).Find(&others).Error; err != nil {
+			return err
+		}
+		for _, other := range others {
+			if overlaps(*scheduled, other) {
+				return fmt.Errorf("%w: %d", ErrOverlap, other.ID)
+			}
+		}
+
+		return tx.Create(scheduled).Error
+	})
+}
+
+func (s *Store) CancelScheduledPrice(scheduled *types.ScheduledPrice) error {
+	now := time.Now()
+	var err error
+	switch scheduled.Status {
+	case StatusScheduled:
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			return claim(tx, scheduled, StatusScheduled, map[string]any{"status": StatusCancelled, "ended_at": now})
+		})
+	case StatusActive:
+		err = s.end(scheduled, StatusCancelled, now)
+	default:
+		return ErrNotCancellable
+	}
+	if err != nil {
+		return err
+	}
+
+	changed, err := s.GetScheduledPriceByID(scheduled.ID)
+	if err != nil {
+		return err
+	}
+	*scheduled = *changed
+	return nil
+}
+
+// ApplyScheduledPrices ends the running entries first, so a price that starts when another ends
+// begins from the price before both
+func (s *Store) ApplyScheduledPrices(now time.Time) (int, int, error) {
+	var over []types.ScheduledPrice
+	if err := s.db.Where("status = ? AND ends_at <= ?", StatusActive, now).Order("ends_at, id").Find(&over).Error; err != nil {
+		return 0, 0, err
+	}
+
+	ended := 0
+	for i := range over {
+		if err := s.end(&over[i], StatusCompleted, now); err != nil {
+			if errors.Is(err, ErrChanged) {
+				continue
+			}
+			return 0, ended, err
+		}
+		ended++
+	}
+
+	var due []types.ScheduledPrice
+	if err := s.db.Where("status = ? AND starts_at <= ?", StatusScheduled, now).Order("starts_at, id").Find(&due).Error; err != nil {
+		return 0, ended, err
+	}
+
+	started := 0
+	for i := range due {
+		applied, err := s.start(&due[i], now)
+		if err != nil {
+			if errors.Is(err, ErrChanged) {
+				continue
+			}
+			return started, ended, err
+		}
+		if applied {
+			started++
+		}
+	}
+	return started, ended, nil
+}
+
+// start sets the scheduled price and remembers the one it replaces. An entry whose end passed before
+// it could start, e.g. while the server was down, completes without touching the price.
+func (s *Store) start(scheduled *types.ScheduledPrice, now time.Time) (bool, error) {
+	missed := scheduled.EndsAt != nil && !scheduled.EndsAt.After(now)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		current, err := currentPrice(tx, scheduled.ProductID)
+		if err != nil {
+			return err
+		}
+
+		changes := map[string]any{
+			"status":            StatusActive,
+			"previous_amount":   current.Amount,
+			"previous_currency": current.Currency,
+			"applied_at":        now,
+		}
+		if missed {
+			changes = map[string]any{"status": StatusCompleted, "ended_at": now}
+		} else if scheduled.EndsAt == nil {
+			changes["status"] = StatusCompleted
+			changes["ended_at"] = now
+		}
+		if err := claim(tx, scheduled, StatusScheduled, changes); err != nil {
+			return err
+		}
+		if missed {
+			return nil
+		}
+
+		return setPrice(tx, scheduled.ProductID, current, scheduled.Price, SourceScheduled, &scheduled.ID)
+	})
+	return err == nil && !missed, err
+}
+
+// end finishes a running entry and puts the previous price back, unless the price was changed after the
+// entry set it, then the newer price stays
+func (s *Store) end(scheduled *types.ScheduledPrice, status string, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := claim(tx, scheduled, StatusActive, map[string]any{"status": status, "ended_at": now}); err != nil {
+			return err
+		}
+
+		current, err := currentPrice(tx, scheduled.ProductID)
+		if err != nil {
+			return err
+		}
+		if current != scheduled.Price {
+			return nil
+		}
+		return setPrice(tx, scheduled.ProductID, current, scheduled.Previous, SourceReverted, &scheduled.ID)
+	})
+}
+
+// claim moves the entry on from status, the status in the condition keeps the scheduler and a
+// cancellation from both handling it
+func claim(tx *gorm.DB, scheduled *types.ScheduledPrice, status string, changes map[string]any) error {
+	result := tx.Model(&types.ScheduledPrice{}).Where("id = ? AND status = ?", scheduled.ID, status).Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrChanged, scheduled.ID)
+	}
+	return nil
+}
+
+func currentPrice(tx *gorm.DB, productID uint) (types.Money, error) {
+	var product types.Product
+	if err := tx.Select("id", "price_amount", "price_currency").First(&product, productID).Error; err != nil {
+		return types.Money{}, err
+	}
+	return product.Price, nil
+}
+
+func setPrice(tx *gorm.DB, productID uint, previous, price types.Money, source string, scheduledID *uint) error {
+	result := tx.Model(&types.Product{ID: productID}).Updates(map[string]any{
+		"price_amount":   price.Amount,
+		"price_currency": price.Currency,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return RecordTx(tx, productID, previous, price, source, scheduledID)
+}
+
+// RecordTx adds a history entry when the price differs from the previous one, it is meant to run in
+// the transaction that changes the price
+func RecordTx(tx *gorm.DB, productID uint, previous, price types.Money, source string, scheduledID *uint) error {
+	if previous == price {
+		return nil
+	}
+	return tx.Create(&types.PriceChange{
+		ProductID:        productID,
+		Price:            price,
+		Previous:         previous,
+		Source:           source,
+		ScheduledPriceID: scheduledID,
+	}).Error
+}
+
+// overlaps reports whether two entries would hold the price at the same time, an entry without an
+// end only takes the moment it starts
+func overlaps(a, b types.ScheduledPrice) bool {
+	switch {
+	case a.EndsAt == nil && b.EndsAt == nil:
+		return a.StartsAt.Equal(b.StartsAt)
+	case a.EndsAt == nil:
+		return !a.StartsAt.Before(b.StartsAt) && a.StartsAt.Before(*b.EndsAt)
+	case b.EndsAt == nil:
+		return overlaps(b, a)
+	}
+	return a.StartsAt.Before(*b.EndsAt) && b.StartsAt.Before(*a.EndsAt)
+}
+
+// ApplyScheduledPricesEvery runs ApplyScheduledPrices on a fixed interval, it is meant to run in its own goroutine
+func ApplyScheduledPricesEvery(store types.PriceStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		started, ended, err := store.ApplyScheduledPrices(time.Now())
+		if err != nil {
+			log.Printf("failed to apply scheduled prices: %v", err)
+			continue
+		}
+		if started > 0 || ended > 0 {
+			log.Printf("started %d and ended %d scheduled prices", started, ended)
+		}
+	}
+}
@@ -0,0 +1,578 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/tax"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store         types.ProductStore
+	userStore     types.UserStore
+	currencyStore types.CurrencyStore
+}
+
+func NewHandler(store types.ProductStore, userStore types.UserStore, currencyStore types.CurrencyStore) *Handler {
+	return &Handler{store: store, userStore: userStore, currencyStore: currencyStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products", h.handleGetProducts).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}", h.handleGetProduct).Methods("GET")
+
+	router.HandleFunc("/products", auth.WithRoles(h.handleCreateProduct, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", auth.WithRoles(h.handleUpdateProduct, h.userStore, "admin", "operator")).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/variants/generate", auth.WithRoles(h.handleGenerateVariants, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/variants/{variantId:[0-9]+}", auth.WithRoles(h.handleUpdateVariant, h.userStore, "admin", "operator")).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/variants/{variantId:[0-9]+}", auth.WithRoles(h.handleDeleteVariant, h.userStore, "admin", "operator")).Methods("DELETE")
+}
+
+// @Summary List products
+// @Description Lists products with faceted filtering, sorting and pagination.
+// @Description Passing a cursor parameter, empty for the first page, switches from offset to cursor pagination.
+// @Tags Products
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param size query int false "Page size" default(20)
+// @Param search query string false "Search in name, description and SKUs"
+// @Param sku query string false "Exact product or variant SKU"
+// @Param category query []string false "Category slugs including their subcategories, repeated or comma separated"
+// @Param minPrice query number false "Minimum price in the base currency, e.g. 49.99"
+// @Param maxPrice query number false "Maximum price in the base currency"
+// @Param currency query string false "ISO 4217 currency to show the prices in"
+// @Param inStock query bool false "Only products in stock"
+// @Param sort query string false "Sort by" Enums(price, name, newest, stock, rating)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Success 200 {object} types.ProductListResponse
+// @Success 200 {object} types.ProductCursorResponse "When paginating by cursor"
+// @Failure 400 {object} map[string]string "Invalid query parameters"
+// @Router /products [get]
+func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid query: %v", errors))
+		return
+	}
+
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("minPrice must not be greater than maxPrice"))
+		return
+	}
+
+	filter := FilterFromQuery(query)
+
+	facets, err := h.store.GetProductFacets(filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if query.Cursor != nil {
+		products, page, err := h.store.GetProductsByCursor(filter, *query.Cursor, query.Size)
+		if err != nil {
+			if errors.Is(err, pagination.ErrInvalidCursor) {
+				utils.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := h.currencyStore.LocalizeProducts(products, query.Currency); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, types.ProductCursorResponse{
+			Data:   products,
+			Cursor: page,
+			Facets: facets,
+		})
+		return
+	}
+
+	products, total, err := h.store.GetProducts(query.Page, query.Size, filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.currencyStore.LocalizeProducts(products, query.Currency); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.ProductListResponse{
+		Data:   products,
+		Total:  total,
+		Page:   query.Page,
+		Size:   query.Size,
+		Facets: facets,
+	})
+}
+
+// @Summary Get product
+// @Description Returns a product with its category, options and variants
+// @Tags Products
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param currency query string false "ISO 4217 currency to show the prices in"
+// @Success 200 {object} types.Product
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id} [get]
+func (h *Handler) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if err := utils.Validate.Var(currency, "omitempty,iso4217"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid currency: %s", currency))
+		return
+	}
+
+	product, err := h.store.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	products := []types.Product{*product}
+	if err := h.currencyStore.LocalizeProducts(products, currency); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	product = &products[0]
+
+	utils.WriteJSON(w, http.StatusOK, product)
+}
+
+// @Summary Create product
+// @Description Creates a product together with its options, variants are generated separately
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param productPayload body types.ProductPayload true "Product payload"
+// @Success 201 {object} types.Product
+// @Failure 400 {object} map[string]string "Invalid product data"
+// @Router /products [post]
+func (h *Handler) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	var payload types.ProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	product := types.Product{}
+	if err := h.applyPayload(&product, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	product.Options = buildOptions(payload.Options)
+	product.Prices = buildPrices(payload.Prices)
+
+	if err := h.store.CreateProduct(&product); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetProductByID(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// @Summary Update product
+// @Description Updates a product. Options are only replaced when given, variants whose option values are gone get removed.
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param productPayload body types.ProductPayload true "Product payload"
+// @Success 200 {object} types.Product
+// @Failure 400 {object} map[string]string "Invalid product data"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id} [put]
+func (h *Handler) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.store.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyPayload(product, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateProduct(product); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if payload.Options != nil {
+		if err := h.store.ReplaceProductOptions(product.ID, buildOptions(payload.Options)); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if payload.Prices != nil {
+		if err := h.store.ReplaceProductPrices(product.ID, buildPrices(payload.Prices)); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	updated, err := h.store.GetProductByID(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// @Summary Generate variants
+// @Description Creates a variant with its own SKU for every option value combination that has none yet
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {array} types.ProductVariant
+// @Failure 400 {object} map[string]string "Product has no options or too many combinations"
+// @Router /products/{id}/variants/generate [post]
+func (h *Handler) handleGenerateVariants(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	variants, err := h.store.GenerateVariants(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, variants)
+}
+
+// @Summary Update variant
+// @Description Updates the SKU, barcode and price override of a variant, stock is booked through the inventory ledger
+// @Tags Products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param variantId path int true "Variant ID"
+// @Param variantPayload body types.ProductVariantPayload true "Variant payload"
+// @Success 200 {object} types.ProductVariant
+// @Failure 400 {object} map[string]string "Invalid variant data"
+// @Failure 404 {object} map[string]string "Variant not found"
+// @Router /products/{id}/variants/{variantId} [put]
+func (h *Handler) handleUpdateVariant(w http.ResponseWriter, r *http.Request) {
+	variant, err := h.variantFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ProductVariantPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	sku := strings.TrimSpace(payload.SKU)
+	taken, err := h.store.IsSKUTaken(sku, 0, variant.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if taken {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sku %s is already in use", sku))
+		return
+	}
+
+	variant.SKU = sku
+	variant.Barcode = strings.TrimSpace(payload.Barcode)
+	variant.PriceOverride = payload.PriceOverride
+
+	if err := h.store.UpdateVariant(variant); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, variant)
+}
+
+// @Summary Delete variant
+// @Description Deletes a single variant of a product
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param variantId path int true "Variant ID"
+// @Success 200 {object} map[string]string "Variant deleted"
+// @Failure 404 {object} map[string]string "Variant not found"
+// @Router /products/{id}/variants/{variantId} [delete]
+func (h *Handler) handleDeleteVariant(w http.ResponseWriter, r *http.Request) {
+	variant, err := h.variantFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteVariant(variant.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Variant deleted"})
+}
+
+// variantFromRequest loads the variant from the path and makes sure it belongs to the product in the path
+func (h *Handler) variantFromRequest(r *http.Request) (*types.ProductVariant, error) {
+	productID, err := parseID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	variantID, err := parseID(r, "variantId")
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := h.store.GetVariantByID(variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if variant.ProductID != productID {
+		return nil, fmt.Errorf("variant not found")
+	}
+
+	return variant, nil
+}
+
+// applyPayload copies the payload onto the product after checking the SKU is free and the prices
+// are in the right currencies
+func (h *Handler) applyPayload(product *types.Product, payload types.ProductPayload) error {
+	base := money.BaseCurrency()
+	if !strings.EqualFold(payload.Price.Currency, base) {
+		return fmt.Errorf("price has to be in the base currency %s, use prices for other currencies", base)
+	}
+
+	for _, price := range payload.Prices {
+		if strings.EqualFold(price.Currency, base) {
+			return fmt.Errorf("prices can't contain the base currency %s", base)
+		}
+	}
+
+	product.SKU = nil
+	if sku := strings.TrimSpace(payload.SKU); sku != "" {
+		taken, err := h.store.IsSKUTaken(sku, product.ID, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("sku %s is already in use", sku)
+		}
+		product.SKU = &sku
+	}
+
+	product.Name = payload.Name
+	product.Description = payload.Description
+	product.Price = money.New(payload.Price.Amount, payload.Price.Currency)
+	product.Prices = nil
+	product.LowStockThreshold = payload.LowStockThreshold
+	product.CategoryID = payload.CategoryID
+	product.Category = nil
+	product.TaxClass = payload.TaxClass
+	if product.TaxClass == "" {
+		product.TaxClass = tax.ClassStandard
+	}
+	product.Weight = payload.Weight
+	product.Length = payload.Length
+	product.Width = payload.Width
+	product.Height = payload.Height
+	product.ImageURL = payload.ImageURL
+
+	return nil
+}
+
+func buildOptions(payload []types.ProductOptionPayload) []types.ProductOption {
+	options := make([]types.ProductOption, 0, len(payload))
+	for i, option := range payload {
+		values := make([]types.ProductOptionValue, 0, len(option.Values))
+		for j, value := range option.Values {
+			values = append(values, types.ProductOptionValue{Value: strings.TrimSpace(value), Position: j})
+		}
+		options = append(options, types.ProductOption{
+			Name:     strings.TrimSpace(option.Name),
+			Position: i,
+			Values:   values,
+		})
+	}
+	return options
+}
+
+func buildPrices(payload []types.MoneyPayload) []types.ProductPrice {
+	prices := make([]types.ProductPrice, 0, len(payload))
+	for _, price := range payload {
+		prices = append(prices, types.ProductPrice{
+			Currency: strings.ToUpper(price.Currency),
+			Amount:   price.Amount,
+		})
+	}
+	return prices
+}
+
+func parseID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
+
+// ParseListQuery reads the product list query parameters, validation is left to the caller
+func ParseListQuery(values url.Values) (types.ProductListQuery, error) {
+	query := types.ProductListQuery{
+		Page:     1,
+		Size:     20,
+		Search:   strings.TrimSpace(values.Get("search")),
+		SKU:      strings.TrimSpace(values.Get("sku")),
+		Sort:     values.Get("sort"),
+		Order:    strings.ToLower(values.Get("order")),
+		Currency: strings.ToUpper(values.Get("currency")),
+	}
+
+	var err error
+	if v := values.Get("page"); v != "" {
+		if query.Page, err = strconv.Atoi(v); err != nil {
+			return query, fmt.Errorf("invalid page: %s", v)
+		}
+	}
+
+	if v := values.Get("size"); v != "" {
+		if query.Size, err = strconv.Atoi(v); err != nil {
+			return query, fmt.Errorf("invalid size: %s", v)
+		}
+	}
+
+	for _, v := range values["category"] {
+		for _, category := range strings.Split(v, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				query.Categories = append(query.Categories, category)
+			}
+		}
+	}
+
+	if query.MinPrice, err = parseAmountParam(values, "minPrice"); err != nil {
+		return query, err
+	}
+
+	if query.MaxPrice, err = parseAmountParam(values, "maxPrice"); err != nil {
+		return query, err
+	}
+
+	if values.Has("cursor") {
+		cursor := values.Get("cursor")
+		query.Cursor = &cursor
+	}
+
+	if v := values.Get("inStock"); v != "" {
+		if query.InStock, err = strconv.ParseBool(v); err != nil {
+			return query, fmt.Errorf("invalid inStock: %s", v)
+		}
+	}
+
+	return query, nil
+}
+
+// FilterFromQuery converts a validated list query into a store filter
+func FilterFromQuery(query types.ProductListQuery) types.ProductFilter {
+	return types.ProductFilter{
+		Search:     query.Search,
+		SKU:        query.SKU,
+		Categories: query.Categories,
+		MinPrice:   query.MinPrice,
+		MaxPrice:   query.MaxPrice,
+		InStock:    query.InStock,
+		Sort:       query.Sort,
+		Order:      query.Order,
+	}
+}
+
+// parseAmountParam reads a decimal price in the base currency into minor units
+func parseAmountParam(values url.Values, name string) (*int64, error) {
+	v := values.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	amount, err := money.ParseAmount(v, money.BaseCurrency())
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+
+	return &amount, nil
+}
@@ -0,0 +1,603 @@
+package product
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/services/price"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxVariants caps how many combinations GenerateVariants may create for one product
+const maxVariants = 500
+
+// priceBuckets are the lower bounds of the price facet ranges in whole units of the base currency,
+// the last one is open ended
+var priceBuckets = []int64{0, 50, 100, 250, 500, 1000}
+
+var sortColumns = map[string]string{
+	"price":  "price_amount",
+	"name":   "name",
+	"newest": "created_at",
+	"stock":  "stock",
+	"rating": "rating_average",
+}
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetProducts(page, size int, filter types.ProductFilter) ([]types.Product, int64, error) {
+	var products []types.Product
+	var total int64
+
+	query := ApplyFilter(s.db.Model(&types.Product{}), filter)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := query.
+		Preload("Category").
+		Order(orderClause(filter)).
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&products)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return products, total, nil
+}
+
+func (s *Store) GetProductsByCursor(filter types.ProductFilter, cursor string, limit int) ([]types.Product, *types.CursorPage, error) {
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		column = "id"
+	}
+
+	keyset := pagination.Keyset{
+		Column: column,
+		Desc:   sortDescending(filter),
+		Filter: filter,
+		Limit:  limit,
+		Cursor: cursor,
+	}
+
+	query := ApplyFilter(s.db.Model(&types.Product{}), filter).Preload("Category")
+
+	return pagination.Paginate(query, keyset, func(p types.Product) (any, uint) {
+		switch column {
+		case "price_amount":
+			return p.Price.Amount, p.ID
+		case "name":
+			return p.Name, p.ID
+		case "created_at":
+			return p.CreatedAt, p.ID
+		case "stock":
+			return p.Stock, p.ID
+		case "rating_average":
+			return p.Rating.Average, p.ID
+		}
+		return p.ID, p.ID
+	})
+}
+
+func (s *Store) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	facets := &types.ProductFacets{
+		Categories:   []types.FacetCount{},
+		PriceBuckets: []types.PriceBucket{},
+	}
+
+	// every facet is counted without its own filter so the client can show the alternatives
+	categoryFilter := filter
+	categoryFilter.Categories = nil
+
+	// like the filter, a category counts its own products and the products of every category below it
+	var categoryRows []struct {
+		CategoryID *uint
+		Count      int64
+	}
+	result := ApplyFilter(s.db.Model(&types.Product{}), categoryFilter).
+		Joins(`JOIN (
+			WITH RECURSIVE tree(ancestor_id, descendant_id) AS (
+				SELECT id, id FROM categories
+				UNION
+				SELECT tree.ancestor_id, categories.id FROM categories JOIN tree ON categories.parent_id = tree.descendant_id
+			)
+			SELECT ancestor_id, descendant_id FROM tree
+		) AS tree ON tree.descendant_id = products.category_id`).
+		Select("tree.ancestor_id AS category_id, COUNT(*) AS count").
+		Group("tree.ancestor_id").
+		Scan(&categoryRows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	ids := make([]uint, 0, len(categoryRows))
+	for _, row := range categoryRows {
+		if row.CategoryID != nil {
+			ids = append(ids, *row.CategoryID)
+		}
+	}
+
+	var categories []types.Category
+	if len(ids) > 0 {
+		if err := s.db.Where("id IN ?", ids).Order("position, name").Find(&categories).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	counts := make(map[uint]int64, len(categoryRows))
+	for _, row := range categoryRows {
+		if row.CategoryID != nil {
+			counts[*row.CategoryID] = row.Count
+		}
+	}
+
+	for _, category := range categories {
+		facets.Categories = append(facets.Categories, types.FacetCount{
+			Value: category.Slug,
+			Label: category.Name,
+			Count: counts[category.ID],
+		})
+	}
+
+	priceFilter := filter
+	priceFilter.MinPrice = nil
+	priceFilter.MaxPrice = nil
+
+	base := money.BaseCurrency()
+	unit := int64(math.Pow10(money.Exponent(base)))
+
+	var cases strings.Builder
+	cases.WriteString("CASE")
+	for i := len(priceBuckets) - 1; i > 0; i-- {
+		cases.WriteString(fmt.Sprintf(" WHEN price_amount >= %d THEN %d", priceBuckets[i]*unit, i))
+	}
+	cases.WriteString(" ELSE 0 END")
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	result = ApplyFilter(s.db.Model(&types.Product{}), priceFilter).
+		Select(cases.String() + " AS bucket, COUNT(*) AS count").
+		Group("bucket").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	bucketCounts := make(map[int]int64, len(rows))
+	for _, row := range rows {
+		bucketCounts[row.Bucket] = row.Count
+	}
+
+	for i, min := range priceBuckets {
+		bucket := types.PriceBucket{Min: money.New(min*unit, base), Count: bucketCounts[i]}
+		if i+1 < len(priceBuckets) {
+			max := money.New(priceBuckets[i+1]*unit, base)
+			bucket.Max = &max
+		}
+		facets.PriceBuckets = append(facets.PriceBuckets, bucket)
+	}
+
+	return facets, nil
+}
+
+// ApplyFilter narrows a products query down to the given filter, sorting is left to the caller
+func ApplyFilter(query *gorm.DB, filter types.ProductFilter) *gorm.DB {
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where(
+			"name LIKE ? OR description LIKE ? OR sku LIKE ? OR id IN (SELECT product_id FROM product_variants WHERE sku LIKE ?)",
+			like, like, like, like,
+		)
+	}
+
+	if filter.SKU != "" {
+		query = query.Where("sku = ? OR id IN (SELECT product_id FROM product_variants WHERE sku = ?)", filter.SKU, filter.SKU)
+	}
+
+	// a category matches its own products and the products of every category below it
+	if len(filter.Categories) > 0 {
+		query = query.Where(`category_id IN (
+			WITH RECURSIVE tree(id) AS (
+				SELECT id FROM categories WHERE slug IN ?
+				UNION
+				SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+			)
+			SELECT id FROM tree
+		)`, filter.Categories)
+	}
+
+	if filter.MinPrice != nil {
+		query = query.Where("price_amount >= ?", *filter.MinPrice)
+	}
+
+	if filter.MaxPrice != nil {
+		query = query.Where("price_amount <= ?", *filter.MaxPrice)
+	}
+
+	if filter.InStock {
+		query = query.Where("stock > 0 OR id IN (SELECT product_id FROM product_variants WHERE stock > 0)")
+	}
+
+	return query
+}
+
+func orderClause(filter types.ProductFilter) string {
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		return "id ASC"
+	}
+
+	direction := "ASC"
+	if sortDescending(filter) {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction)
+}
+
+// sortDescending defaults to descending for newest first, everything else ascends unless asked otherwise
+func sortDescending(filter types.ProductFilter) bool {
+	return filter.Order == "desc" || (filter.Order == "" && filter.Sort == "newest")
+}
+
+func (s *Store) GetProductByID(id uint) (*types.Product, error) {
+	var product types.Product
+	result := s.db.
+		Preload("Category").
+		Preload("Prices", func(db *gorm.DB) *gorm.DB {
+			return db.Order("currency")
+		}).
+		Preload("Options", orderByPosition).
+		Preload("Options.Values", orderByPosition).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Variants.OptionValues").
+		Preload("Variants.StockLevels.Warehouse").
+		Preload("StockLevels", "variant_id IS NULL").
+		Preload("StockLevels.Warehouse").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		First(&product, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("product not found")
+		}
+		return nil, result.Error
+	}
+	return &product, nil
+}
+
+func (s *Store) GetProductBySKU(sku string) (*types.Product, error) {
+	var product types.Product
+	result := s.db.Where("sku = ?", sku).First(&product)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("product not found")
+		}
+		return nil, result.Error
+	}
+	return s.GetProductByID(product.ID)
+}
+
+// CreateProduct inserts the product together with its options and their values and its fixed prices,
+// its price starts the price history
+func (s *Store) CreateProduct(product *types.Product) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Category", "Variants", "StockLevels", "Images", "Stock", "Reserved", "Rating").Create(product)
+		if result.Error != nil {
+			return result.Error
+		}
+		return price.RecordTx(tx, product.ID, types.Money{}, product.Price, price.SourceCreated, nil)
+	})
+}
+
+// UpdateProduct leaves the stock counters alone, they only move through the inventory ledger, and so
+// does the rating, it only moves through review moderation. A new price is added to the price history.
+func (s *Store) UpdateProduct(product *types.Product) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var previous types.Product
+		if err := tx.Select("id", "price_amount", "price_currency").First(&previous, product.ID).Error; err != nil {
+			return err
+		}
+
+		result := tx.Omit(clause.Associations, "Stock", "Reserved", "Rating").Save(product)
+		if result.Error != nil {
+			return result.Error
+		}
+		return price.RecordTx(tx, product.ID, previous.Price, product.Price, price.SourceUpdated, nil)
+	})
+}
+
+// ReplaceProductOptions swaps the option set of a product. Options and values are matched by name so
+// variants survive as long as every value they are built from is kept and no option was added or removed.
+func (s *Store) ReplaceProductOptions(productID uint, options []types.ProductOption) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []types.ProductOption
+		if err := tx.Preload("Values").Where("product_id = ?", productID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		var variants []types.ProductVariant
+		if err := tx.Preload("OptionValues").Where("product_id = ?", productID).Find(&variants).Error; err != nil {
+			return err
+		}
+
+		existingOptions := make(map[string]types.ProductOption, len(existing))
+		for _, option := range existing {
+			existingOptions[strings.ToLower(option.Name)] = option
+		}
+
+		keptOptions := make(map[uint]bool)
+		keptValues := make(map[uint]bool)
+
+		for i, option := range options {
+			current, ok := existingOptions[strings.ToLower(option.Name)]
+			if !ok {
+				current = types.ProductOption{ProductID: productID}
+			}
+			current.Name = option.Name
+			current.Position = i
+
+			if err := tx.Omit("Values").Save(&current).Error; err != nil {
+				return err
+			}
+			keptOptions[current.ID] = true
+
+			existingValues := make(map[string]types.ProductOptionValue, len(current.Values))
+			for _, value := range current.Values {
+				existingValues[strings.ToLower(value.Value)] = value
+			}
+
+			for j, v := range option.Values {
+				value, ok := existingValues[strings.ToLower(v.Value)]
+				if !ok {
+					value = types.ProductOptionValue{OptionID: current.ID}
+				}
+				value.Value = v.Value
+				value.Position = j
+
+				if err := tx.Save(&value).Error; err != nil {
+					return err
+				}
+				keptValues[value.ID] = true
+			}
+		}
+
+		var removedValues []uint
+		for _, option := range existing {
+			for _, value := range option.Values {
+				if !keptValues[value.ID] {
+					removedValues = append(removedValues, value.ID)
+				}
+			}
+		}
+
+		for _, variant := range variants {
+			stale := len(variant.OptionValues) != len(options)
+			for _, value := range variant.OptionValues {
+				if !keptValues[value.ID] {
+					stale = true
+				}
+			}
+			if stale {
+				if err := deleteVariant(tx, variant.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(removedValues) > 0 {
+			if err := tx.Delete(&types.ProductOptionValue{}, removedValues).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, option := range existing {
+			if !keptOptions[option.ID] {
+				if err := tx.Delete(&types.ProductOption{}, option.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// GenerateVariants creates a variant for every combination of option values that doesn't have one yet
+func (s *Store) GenerateVariants(productID uint) ([]types.ProductVariant, error) {
+	product, err := s.GetProductByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(product.Options) == 0 {
+		return nil, fmt.Errorf("product has no options to build variants from")
+	}
+
+	combinations := [][]types.ProductOptionValue{{}}
+	for _, option := range product.Options {
+		var next [][]types.ProductOptionValue
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				next = append(next, append(append([]types.ProductOptionValue{}, combination...), value))
+			}
+		}
+		combinations = next
+	}
+
+	if len(combinations) > maxVariants {
+		return nil, fmt.Errorf("options result in %d variants, at most %d are allowed", len(combinations), maxVariants)
+	}
+
+	existing := make(map[string]bool, len(product.Variants))
+	for _, variant := range product.Variants {
+		existing[combinationKey(variant.OptionValues)] = true
+	}
+
+	base := fmt.Sprintf("P%d", product.ID)
+	if product.SKU != nil && *product.SKU != "" {
+		base = *product.SKU
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, combination := range combinations {
+			if existing[combinationKey(combination)] {
+				continue
+			}
+
+			parts := []string{base}
+			for _, value := range combination {
+				parts = append(parts, strings.ToUpper(utils.Slugify(value.Value)))
+			}
+
+			sku, err := uniqueSKU(tx, strings.Join(parts, "-"))
+			if err != nil {
+				return err
+			}
+
+			variant := types.ProductVariant{
+				ProductID:    product.ID,
+				SKU:          sku,
+				OptionValues: combination,
+			}
+			if err := tx.Omit("OptionValues.*").Create(&variant).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	product, err = s.GetProductByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	return product.Variants, nil
+}
+
+func (s *Store) GetVariantByID(id uint) (*types.ProductVariant, error) {
+	var variant types.ProductVariant
+	result := s.db.Preload("OptionValues").First(&variant, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("variant not found")
+		}
+		return nil, result.Error
+	}
+	return &variant, nil
+}
+
+func (s *Store) UpdateVariant(variant *types.ProductVariant) error {
+	result := s.db.Omit(clause.Associations, "Stock", "Reserved").Save(variant)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) DeleteVariant(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return deleteVariant(tx, id)
+	})
+}
+
+// IsSKUTaken checks products and variants alike since a SKU has to identify exactly one sellable item,
+// the product and variant passed in are the ones being saved and don't count as taken
+func (s *Store) IsSKUTaken(sku string, productID, variantID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&types.Product{}).Where("sku = ? AND id <> ?", sku, productID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := s.db.Model(&types.ProductVariant{}).Where("sku = ? AND id <> ?", sku, variantID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ReplaceProductPrices swaps the fixed prices a product has in other currencies
+func (s *Store) ReplaceProductPrices(productID uint, prices []types.ProductPrice) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&types.ProductPrice{}).Error; err != nil {
+			return err
+		}
+
+		for i := range prices {
+			prices[i].ID = 0
+			prices[i].ProductID = productID
+		}
+
+		if len(prices) > 0 {
+			if err := tx.Create(&prices).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteVariant(tx *gorm.DB, id uint) error {
+	if err := tx.Exec("DELETE FROM product_variant_option_values WHERE product_variant_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("variant_id = ?", id).Delete(&types.StockLevel{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&types.ProductVariant{}, id).Error
+}
+
+// uniqueSKU numbers sku until neither a product nor a variant uses it, like IsSKUTaken
+func uniqueSKU(tx *gorm.DB, sku string) (string, error) {
+	candidate := sku
+	for i := 2; ; i++ {
+		var products, variants int64
+		if err := tx.Model(&types.Product{}).Where("sku = ?", candidate).Count(&products).Error; err != nil {
+			return "", err
+		}
+		if err := tx.Model(&types.ProductVariant{}).Where("sku = ?", candidate).Count(&variants).Error; err != nil {
+			return "", err
+		}
+		if products+variants == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", sku, i)
+	}
+}
+
+func combinationKey(values []types.ProductOptionValue) string {
+	ids := make([]int, 0, len(values))
+	for _, value := range values {
+		ids = append(ids, int(value.ID))
+	}
+	sort.Ints(ids)
+	return fmt.Sprint(ids)
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
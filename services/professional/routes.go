@@ -0,0 +1,567 @@
+package professional
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.ProfessionalStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.ProfessionalStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/professionals", h.handleGetProfessionals).Methods("GET")
+	router.HandleFunc("/professionals/{id:[0-9]+}", h.handleGetProfessional).Methods("GET")
+
+	router.HandleFunc("/me/professional-profile", auth.WithRoles(h.handleGetMyProfile, h.userStore, "professional")).Methods("GET")
+	router.HandleFunc("/me/professional-profile", auth.WithRoles(h.handleCreateMyProfile, h.userStore, "professional")).Methods("POST")
+	router.HandleFunc("/me/professional-profile", auth.WithRoles(h.handleUpdateMyProfile, h.userStore, "professional")).Methods("PUT")
+	router.HandleFunc("/me/professional-profile/services", auth.WithRoles(h.handleCreateService, h.userStore, "professional")).Methods("POST")
+	router.HandleFunc("/me/professional-profile/services/{serviceId:[0-9]+}", auth.WithRoles(h.handleUpdateService, h.userStore, "professional")).Methods("PUT")
+	router.HandleFunc("/me/professional-profile/services/{serviceId:[0-9]+}", auth.WithRoles(h.handleDeleteService, h.userStore, "professional")).Methods("DELETE")
+
+	router.HandleFunc("/professional-profiles", auth.WithRoles(h.handleGetProfiles, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/professional-profiles/{id:[0-9]+}", auth.WithRoles(h.handleGetProfile, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/professional-profiles/{id:[0-9]+}/approve", auth.WithRoles(h.handleApproveProfile, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/professional-profiles/{id:[0-9]+}/reject", auth.WithRoles(h.handleRejectProfile, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/professional-profiles/{id:[0-9]+}/suspend", auth.WithRoles(h.handleSuspendProfile, h.userStore, "admin")).Methods("POST")
+}
+
+// @Summary Search professionals
+// @Description Lists approved professional profiles with their active services
+// @Tags Professionals
+// @Produce json
+// @Param search query string false "Search in name, headline, bio and skills"
+// @Param skill query string false "Exact skill"
+// @Param country query string false "ISO 3166 country of the service area"
+// @Param city query string false "City of the service area, remote professionals always match"
+// @Param remote query bool false "Only professionals who work remotely"
+// @Param sort query string false "Sort by" Enums(newest, oldest, name)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Profiles and cursors"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Router /professionals [get]
+func (h *Handler) handleGetProfessionals(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := types.ProfessionalFilter{
+		Search:  strings.TrimSpace(query.Get("search")),
+		Skill:   strings.TrimSpace(query.Get("skill")),
+		Country: strings.TrimSpace(query.Get("country")),
+		City:    strings.TrimSpace(query.Get("city")),
+		Status:  StatusApproved,
+		Sort:    query.Get("sort"),
+	}
+	if _, ok := sorts[filter.Sort]; filter.Sort != "" && !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sort must be newest, oldest or name"))
+		return
+	}
+	if v := query.Get("remote"); v != "" {
+		remote, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid remote"))
+			return
+		}
+		filter.Remote = remote
+	}
+
+	profiles, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+	for i := range profiles {
+		public(&profiles[i])
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": profiles, "cursor": page})
+}
+
+// @Summary Get professional
+// @Description Shows an approved professional profile with its active services
+// @Tags Professionals
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /professionals/{id} [get]
+func (h *Handler) handleGetProfessional(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.profileFromRequest(r)
+	if err != nil || profile.Status != StatusApproved {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("professional profile not found"))
+		return
+	}
+
+	public(profile)
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// @Summary My professional profile
+// @Description Shows the profile of the logged in professional in any review status, with every service
+// @Tags Professionals
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /me/professional-profile [get]
+func (h *Handler) handleGetMyProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.store.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// @Summary Create professional profile
+// @Description Creates the profile of the logged in professional, it is listed once an admin approves it
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param professionalProfilePayload body types.ProfessionalProfilePayload true "Profile"
+// @Success 201 {object} types.ProfessionalProfile
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 409 {object} map[string]string "Profile exists already"
+// @Router /me/professional-profile [post]
+func (h *Handler) handleCreateMyProfile(w http.ResponseWriter, r *http.Request) {
+	var payload types.ProfessionalProfilePayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	profile := &types.ProfessionalProfile{UserID: auth.GetUserIDFromContext(r.Context())}
+	applyPayload(profile, payload)
+	if err := h.store.CreateProfile(profile); err != nil {
+		if errors.Is(err, ErrProfileExists) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, profile)
+}
+
+// @Summary Update professional profile
+// @Description Updates the profile of the logged in professional, it goes back to review and is unlisted until approved again
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param professionalProfilePayload body types.ProfessionalProfilePayload true "Profile"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /me/professional-profile [put]
+func (h *Handler) handleUpdateMyProfile(w http.ResponseWriter, r *http.Request) {
+	var payload types.ProfessionalProfilePayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	profile, err := h.store.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	applyPayload(profile, payload)
+	if err := h.store.UpdateProfile(profile); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// @Summary Add service
+// @Description Lists a service on the profile of the logged in professional, services don't need review
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param professionalServicePayload body types.ProfessionalServicePayload true "Service"
+// @Success 201 {object} types.ProfessionalService
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /me/professional-profile/services [post]
+func (h *Handler) handleCreateService(w http.ResponseWriter, r *http.Request) {
+	var payload types.ProfessionalServicePayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+	if err := checkCurrency(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.store.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	service := &types.ProfessionalService{ProfileID: profile.ID, Active: true}
+	applyServicePayload(service, payload)
+	if err := h.store.CreateService(service); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, service)
+}
+
+// @Summary Update service
+// @Description Updates a service of the logged in professional, inactive services are hidden from customers
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param serviceId path int true "Service ID"
+// @Param professionalServicePayload body types.ProfessionalServicePayload true "Service"
+// @Success 200 {object} types.ProfessionalService
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Service not found"
+// @Router /me/professional-profile/services/{serviceId} [put]
+func (h *Handler) handleUpdateService(w http.ResponseWriter, r *http.Request) {
+	var payload types.ProfessionalServicePayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+	if err := checkCurrency(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	service, err := h.myService(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	applyServicePayload(service, payload)
+	if err := h.store.UpdateService(service); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, service)
+}
+
+// @Summary Delete service
+// @Description Removes a service from the profile of the logged in professional
+// @Tags Professionals
+// @Security BearerAuth
+// @Param serviceId path int true "Service ID"
+// @Success 204
+// @Failure 404 {object} map[string]string "Service not found"
+// @Router /me/professional-profile/services/{serviceId} [delete]
+func (h *Handler) handleDeleteService(w http.ResponseWriter, r *http.Request) {
+	service, err := h.myService(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteService(service.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Profile review queue
+// @Description Lists professional profiles to review, by default the pending ones oldest first
+// @Tags Professionals
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Review status" Enums(pending, approved, rejected, suspended) default(pending)
+// @Param search query string false "Search in name, headline, bio and skills"
+// @Param sort query string false "Sort by" Enums(newest, oldest, name) default(oldest)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Profiles and cursors"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Router /professional-profiles [get]
+func (h *Handler) handleGetProfiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := types.ProfessionalFilter{
+		Search: strings.TrimSpace(query.Get("search")),
+		Status: query.Get("status"),
+		Sort:   query.Get("sort"),
+	}
+	if filter.Status == "" {
+		filter.Status = StatusPending
+	}
+	if !slices.Contains([]string{StatusPending, StatusApproved, StatusRejected, StatusSuspended}, filter.Status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be %s, %s, %s or %s", StatusPending, StatusApproved, StatusRejected, StatusSuspended))
+		return
+	}
+	if filter.Sort == "" {
+		filter.Sort = "oldest"
+	}
+	if _, ok := sorts[filter.Sort]; !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sort must be newest, oldest or name"))
+		return
+	}
+
+	profiles, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": profiles, "cursor": page})
+}
+
+// @Summary Get profile for review
+// @Description Shows a professional profile in any review status, with every service
+// @Tags Professionals
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /professional-profiles/{id} [get]
+func (h *Handler) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.profileFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// @Summary Approve profile
+// @Description Lists a pending or suspended profile publicly
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Param professionalReviewPayload body types.ProfessionalReviewPayload false "Review note"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 400 {object} map[string]string "Profile can't be approved in its status"
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /professional-profiles/{id}/approve [post]
+func (h *Handler) handleApproveProfile(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, StatusApproved)
+}
+
+// @Summary Reject profile
+// @Description Rejects a pending profile, the owner can edit it to send it back to review
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Param professionalReviewPayload body types.ProfessionalReviewPayload false "Reason"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 400 {object} map[string]string "Profile isn't pending"
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /professional-profiles/{id}/reject [post]
+func (h *Handler) handleRejectProfile(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, StatusRejected)
+}
+
+// @Summary Suspend profile
+// @Description Unlists an approved profile until an admin approves it again
+// @Tags Professionals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Profile ID"
+// @Param professionalReviewPayload body types.ProfessionalReviewPayload false "Reason"
+// @Success 200 {object} types.ProfessionalProfile
+// @Failure 400 {object} map[string]string "Profile isn't approved"
+// @Failure 404 {object} map[string]string "Profile not found"
+// @Router /professional-profiles/{id}/suspend [post]
+func (h *Handler) handleSuspendProfile(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, StatusSuspended)
+}
+
+func (h *Handler) review(w http.ResponseWriter, r *http.Request, status string) {
+	var payload types.ProfessionalReviewPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	profile, err := h.profileFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.ReviewProfile(profile, status, payload.Note, auth.GetUserIDFromContext(r.Context())); err != nil {
+		if errors.Is(err, ErrInvalidReview) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// list loads a page of profiles, false means the error was written
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, filter types.ProfessionalFilter) ([]types.ProfessionalProfile, *types.CursorPage, bool) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return nil, nil, false
+		}
+		size = n
+	}
+
+	profiles, page, err := h.store.GetProfiles(filter, r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return nil, nil, false
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	return profiles, page, true
+}
+
+func (h *Handler) profileFromRequest(r *http.Request) (*types.ProfessionalProfile, error) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return h.store.GetProfileByID(id)
+}
+
+// myService loads the service of the path, services of other professionals are reported as not found
+func (h *Handler) myService(r *http.Request) (*types.ProfessionalService, error) {
+	id, err := parseID(r, "serviceId")
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := h.store.GetProfileByUserID(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := h.store.GetServiceByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if service.ProfileID != profile.ID {
+		return nil, fmt.Errorf("service not found")
+	}
+	return service, nil
+}
+
+// parsePayload reads and validates the request body into payload, false means the error was written
+func parsePayload(w http.ResponseWriter, r *http.Request, payload any) bool {
+	if err := utils.ParseJSON(r, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return false
+	}
+	return true
+}
+
+// applyPayload copies the payload onto the profile, skills are kept lower case and once each so they
+// can be searched exactly
+func applyPayload(profile *types.ProfessionalProfile, payload types.ProfessionalProfilePayload) {
+	profile.DisplayName = strings.TrimSpace(payload.DisplayName)
+	profile.Headline = strings.TrimSpace(payload.Headline)
+	profile.Bio = strings.TrimSpace(payload.Bio)
+
+	profile.Skills = make([]string, 0, len(payload.Skills))
+	for _, skill := range payload.Skills {
+		skill = strings.ToLower(strings.TrimSpace(skill))
+		if skill != "" && !slices.Contains(profile.Skills, skill) {
+			profile.Skills = append(profile.Skills, skill)
+		}
+	}
+
+	profile.ServiceArea = types.ServiceArea{
+		Country: strings.ToUpper(payload.ServiceArea.Country),
+		City:    strings.TrimSpace(payload.ServiceArea.City),
+		Radius:  payload.ServiceArea.Radius,
+		Remote:  payload.ServiceArea.Remote,
+	}
+}
+
+func applyServicePayload(service *types.ProfessionalService, payload types.ProfessionalServicePayload) {
+	service.Name = strings.TrimSpace(payload.Name)
+	service.Description = strings.TrimSpace(payload.Description)
+	service.Duration = payload.Duration
+	service.Price = money.New(payload.Price.Amount, payload.Price.Currency)
+	if payload.Active != nil {
+		service.Active = *payload.Active
+	}
+}
+
+// checkCurrency makes sure services are priced in the base currency like products
+func checkCurrency(payload types.ProfessionalServicePayload) error {
+	if base := money.BaseCurrency(); !strings.EqualFold(payload.Price.Currency, base) {
+		return fmt.Errorf("price has to be in the base currency %s", base)
+	}
+	return nil
+}
+
+// public hides the review details and the inactive services of a profile shown to customers
+func public(profile *types.ProfessionalProfile) {
+	profile.ReviewNote = ""
+	profile.ReviewedBy = nil
+	profile.ReviewedAt = nil
+
+	services := make([]types.ProfessionalService, 0, len(profile.Services))
+	for _, service := range profile.Services {
+		if service.Active {
+			services = append(services, service)
+		}
+	}
+	profile.Services = services
+}
+
+func parseID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
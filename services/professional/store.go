@@ -0,0 +1,231 @@
+package professional
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusSuspended = "suspended"
+)
+
+// ErrProfileExists is returned for a second profile of the same user, they edit the first one
+var ErrProfileExists = errors.New("professional profile exists already")
+
+var ErrInvalidReview = errors.New("invalid profile review")
+
+// transitions are the decisions an admin can take on a profile in each status, edits by the owner
+// send any profile back to pending
+var transitions = map[string][]string{
+	StatusPending:   {StatusApproved, StatusRejected},
+	StatusApproved:  {StatusSuspended},
+	StatusSuspended: {StatusApproved},
+}
+
+// sorts maps the sort parameter to the keyset of the listing
+var sorts = map[string]pagination.Keyset{
+	"newest": {Column: "created_at", Desc: true},
+	"oldest": {Column: "created_at"},
+	"name":   {Column: "display_name"},
+}
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetProfiles lists profiles with their services by filter.Sort, newest first by default
+func (s *Store) GetProfiles(filter types.ProfessionalFilter, cursor string, limit int) ([]types.ProfessionalProfile, *types.CursorPage, error) {
+	keyset, ok := sorts[filter.Sort]
+	if !ok {
+		keyset = sorts["newest"]
+	}
+	keyset.Filter = filter
+	keyset.Limit = limit
+	keyset.Cursor = cursor
+
+	query := s.db.Model(&types.ProfessionalProfile{}).Preload("Services", orderByID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Search != "" {
+		term := "%" + filter.Search + "%"
+		query = query.Where("display_name LIKE ? OR headline LIKE ? OR bio LIKE ? OR skills LIKE ?", term, term, term, term)
+	}
+	if filter.Skill != "" {
+		// skills are stored lower case as a JSON array, the quotes match whole skills only
+		query = query.Where("skills LIKE ?", `%"`+strings.ToLower(filter.Skill)+`"%`)
+	}
+	if filter.Country != "" {
+		query = query.Where("area_country = ?", strings.ToUpper(filter.Country))
+	}
+	if filter.City != "" {
+		query = query.Where("(LOWER(area_city) = LOWER(?) OR area_remote = ?)", filter.City, true)
+	}
+	if filter.Remote {
+		query = query.Where("area_remote = ?", true)
+	}
+
+	return pagination.Paginate(query, keyset, func(p types.ProfessionalProfile) (any, uint) {
+		if keyset.Column == "display_name" {
+			return p.DisplayName, p.ID
+		}
+		return p.CreatedAt, p.ID
+	})
+}
+
+func (s *Store) GetProfileByID(id uint) (*types.ProfessionalProfile, error) {
+	return s.getProfile(s.db.Where("id = ?", id))
+}
+
+func (s *Store) GetProfileByUserID(userID int) (*types.ProfessionalProfile, error) {
+	return s.getProfile(s.db.Where("user_id = ?", userID))
+}
+
+func (s *Store) getProfile(query *gorm.DB) (*types.ProfessionalProfile, error) {
+	var profile types.ProfessionalProfile
+	result := query.Preload("Services", orderByID).First(&profile)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("professional profile not found")
+		}
+		return nil, result.Error
+	}
+	return &profile, nil
+}
+
+// CreateProfile stores the profile for review, a user has one profile
+func (s *Store) CreateProfile(profile *types.ProfessionalProfile) error {
+	profile.Status = StatusPending
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.ProfessionalProfile{}).Where("user_id = ?", profile.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrProfileExists
+		}
+		return tx.Omit("Services").Create(profile).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	created, err := s.GetProfileByID(profile.ID)
+	if err != nil {
+		return err
+	}
+	*profile = *created
+	dispatch(*profile, "")
+	return nil
+}
+
+// UpdateProfile takes an approved profile offline until it is approved again
+func (s *Store) UpdateProfile(profile *types.ProfessionalProfile) error {
+	var from string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var stored types.ProfessionalProfile
+		if err := tx.First(&stored, profile.ID).Error; err != nil {
+			return err
+		}
+		from = stored.Status
+
+		profile.Status = StatusPending
+		return tx.Omit("Services").Save(profile).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.GetProfileByID(profile.ID)
+	if err != nil {
+		return err
+	}
+	*profile = *updated
+	dispatch(*profile, from)
+	return nil
+}
+
+func (s *Store) ReviewProfile(profile *types.ProfessionalProfile, status, note string, userID int) error {
+	from := profile.Status
+	if !CanTransition(from, status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidReview, from, status)
+	}
+
+	// the status in the condition keeps a decision from overriding an edit or another decision made meanwhile
+	result := s.db.Model(&types.ProfessionalProfile{}).Where("id = ? AND status = ?", profile.ID, from).UpdateColumns(map[string]any{
+		"status":      status,
+		"review_note": note,
+		"reviewed_by": userID,
+		"reviewed_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: profile %d changed meanwhile", ErrInvalidReview, profile.ID)
+	}
+
+	reviewed, err := s.GetProfileByID(profile.ID)
+	if err != nil {
+		return err
+	}
+	*profile = *reviewed
+	dispatch(*profile, from)
+	return nil
+}
+
+func (s *Store) GetServiceByID(id uint) (*types.ProfessionalService, error) {
+	var service types.ProfessionalService
+	result := s.db.First(&service, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("service not found")
+		}
+		return nil, result.Error
+	}
+	return &service, nil
+}
+
+func (s *Store) CreateService(service *types.ProfessionalService) error {
+	return s.db.Create(service).Error
+}
+
+func (s *Store) UpdateService(service *types.ProfessionalService) error {
+	return s.db.Save(service).Error
+}
+
+func (s *Store) DeleteService(id uint) error {
+	return s.db.Delete(&types.ProfessionalService{}, id).Error
+}
+
+// CanTransition reports whether an admin may move a profile in status from to status to
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// dispatch emits professional.<status> for the status the profile is in now
+func dispatch(profile types.ProfessionalProfile, from string) {
+	event.Dispatch(types.Event{
+		Name:    "professional." + profile.Status,
+		Payload: types.ProfessionalEvent{Profile: profile, From: from},
+	})
+}
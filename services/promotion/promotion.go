@@ -0,0 +1,302 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/category"
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	TypePercentage   = "percentage"
+	TypeFixed        = "fixed"
+	TypeFreeShipping = "free_shipping"
+	TypeBuyXGetY     = "buy_x_get_y"
+)
+
+// errNotQualified means the cart doesn't meet the conditions of a promotion yet, a coupon stays on the cart
+// and applies once it does
+var errNotQualified = errors.New("cart doesn't qualify")
+
+// Engine applies the running promotions to carts. Promotions apply in order of priority and stack,
+// every one discounts what the ones before left of a line.
+type Engine struct {
+	store      types.PromotionStore
+	categories types.CategoryStore
+}
+
+func NewEngine(store types.PromotionStore, categories types.CategoryStore) *Engine {
+	return &Engine{store: store, categories: categories}
+}
+
+// Apply works out the discounts of the cart from its subtotal. A coupon that expired, was removed or
+// is used up is taken off the cart.
+func (e *Engine) Apply(cart *types.Cart) error {
+	currency := cart.Subtotal.Currency
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+	for i := range cart.Items {
+		cart.Items[i].Discount = money.New(0, currency)
+		cart.Items[i].Discounts = nil
+	}
+	cart.Discount = money.New(0, currency)
+	cart.Discounts = nil
+	cart.FreeShipping = false
+	cart.Total = cart.Subtotal
+
+	if len(cart.Items) == 0 {
+		return nil
+	}
+
+	code := ""
+	if cart.CouponCode != nil {
+		code = *cart.CouponCode
+	}
+	promotions, err := e.store.GetActivePromotions(time.Now(), code)
+	if err != nil {
+		return err
+	}
+	categories, err := e.categoriesFor(promotions)
+	if err != nil {
+		return err
+	}
+
+	couponValid := false
+	for i := range promotions {
+		p := &promotions[i]
+		err := e.eligible(p, cart, categories)
+		if p.Code != nil && (err == nil || errors.Is(err, errNotQualified)) {
+			couponValid = true
+		}
+		if errors.Is(err, errNotQualified) || errors.Is(err, ErrUsedUp) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		apply(p, cart, categories)
+	}
+	if code != "" && !couponValid {
+		cart.CouponCode = nil
+	}
+
+	cart.Total.Amount = cart.Subtotal.Amount - cart.Discount.Amount
+	return nil
+}
+
+// Check tells why the coupon of the code can't go on the cart, if it can't
+func (e *Engine) Check(cart *types.Cart, code string) error {
+	p, err := e.store.GetPromotionByCode(code)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !p.Active || (p.StartsAt != nil && now.Before(*p.StartsAt)) {
+		return fmt.Errorf("coupon %s isn't valid yet", *p.Code)
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return fmt.Errorf("coupon %s has expired", *p.Code)
+	}
+
+	categories, err := e.categoriesFor([]types.Promotion{*p})
+	if err != nil {
+		return err
+	}
+	return e.eligible(p, cart, categories)
+}
+
+// eligible checks the limits and conditions of the promotion against the cart. Per customer limits of
+// guests are checked when they check out.
+func (e *Engine) eligible(p *types.Promotion, cart *types.Cart, categories []types.Category) error {
+	if p.UsageLimit != nil && p.UsageCount >= *p.UsageLimit {
+		return fmt.Errorf("%w: %s", ErrUsedUp, p.Name)
+	}
+	if p.PerCustomerLimit != nil && cart.UserID != nil {
+		count, err := e.store.CountRedemptions(p.ID, *cart.UserID)
+		if err != nil {
+			return err
+		}
+		if count >= *p.PerCustomerLimit {
+			return fmt.Errorf("%w: %s is limited to %d uses per customer", ErrUsedUp, p.Name, *p.PerCustomerLimit)
+		}
+	}
+
+	if cart.Subtotal.Amount < p.MinSubtotal {
+		minimum := money.New(p.MinSubtotal, cart.Subtotal.Currency)
+		return fmt.Errorf("%w: %s needs a subtotal of at least %s %s", errNotQualified, p.Name, money.Format(minimum), minimum.Currency)
+	}
+
+	if p.Type == TypeFreeShipping {
+		return nil
+	}
+	units := 0
+	for _, item := range cart.Items {
+		if matches(p, item, categories) {
+			units += item.Quantity
+		}
+	}
+	if units == 0 {
+		return fmt.Errorf("%w: none of the items are part of %s", errNotQualified, p.Name)
+	}
+	if p.Type == TypeBuyXGetY && units < p.BuyQuantity+p.GetQuantity {
+		return fmt.Errorf("%w: %s needs %d qualifying items", errNotQualified, p.Name, p.BuyQuantity+p.GetQuantity)
+	}
+	return nil
+}
+
+// categoriesFor loads the category tree when one of the promotions is scoped to categories
+func (e *Engine) categoriesFor(promotions []types.Promotion) ([]types.Category, error) {
+	for _, p := range promotions {
+		if len(p.CategoryIDs) > 0 {
+			return e.categories.GetCategories()
+		}
+	}
+	return nil, nil
+}
+
+// matches reports whether the promotion discounts the item. Unscoped promotions discount everything,
+// scoped ones the listed products and the products of the listed categories and their subcategories.
+func matches(p *types.Promotion, item types.CartItem, categories []types.Category) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	if slices.Contains(p.ProductIDs, item.ProductID) {
+		return true
+	}
+	if item.CategoryID == nil {
+		return false
+	}
+	for _, id := range p.CategoryIDs {
+		if *item.CategoryID == id || category.IsDescendant(categories, *item.CategoryID, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply discounts the lines the promotion matches and explains the discount on every line it touched
+func apply(p *types.Promotion, cart *types.Cart, categories []types.Category) {
+	var lines []int
+	for i, item := range cart.Items {
+		if matches(p, item, categories) && remaining(item) > 0 {
+			lines = append(lines, i)
+		}
+	}
+
+	amounts := make(map[int]int64)
+	switch p.Type {
+	case TypeFreeShipping:
+		cart.FreeShipping = true
+
+	case TypePercentage:
+		for _, i := range lines {
+			amounts[i] = remaining(cart.Items[i]) * int64(p.Percent) / 100
+		}
+
+	case TypeFixed:
+		var total int64
+		for _, i := range lines {
+			total += remaining(cart.Items[i])
+		}
+		amount := min(p.Amount, total)
+		if total == 0 || amount == 0 {
+			break
+		}
+
+		// spread it over the lines by their share, the rounding rest goes to the first lines with room
+		var spread int64
+		for _, i := range lines {
+			amounts[i] = amount * remaining(cart.Items[i]) / total
+			spread += amounts[i]
+		}
+		for _, i := range lines {
+			extra := min(amount-spread, remaining(cart.Items[i])-amounts[i])
+			amounts[i] += extra
+			spread += extra
+		}
+
+	case TypeBuyXGetY:
+		// every buy+get units the cheapest get units are discounted
+		type unit struct {
+			line  int
+			price int64
+		}
+		var units []unit
+		for _, i := range lines {
+			for range cart.Items[i].Quantity {
+				units = append(units, unit{line: i, price: cart.Items[i].UnitPrice.Amount})
+			}
+		}
+		sort.SliceStable(units, func(a, b int) bool {
+			return units[a].price < units[b].price
+		})
+
+		free := len(units) / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+		for _, u := range units[:free] {
+			amounts[u.line] += u.price * int64(getPercent(p)) / 100
+		}
+		for i, amount := range amounts {
+			amounts[i] = min(amount, remaining(cart.Items[i]))
+		}
+	}
+
+	applied := types.AppliedDiscount{PromotionID: p.ID, Name: p.Name, Description: describe(p, cart.Subtotal.Currency)}
+	if p.Code != nil {
+		applied.Code = *p.Code
+	}
+
+	for _, i := range lines {
+		if amounts[i] <= 0 {
+			continue
+		}
+		item := &cart.Items[i]
+		line := applied
+		line.Amount = amounts[i]
+		item.Discount.Amount += line.Amount
+		item.Discounts = append(item.Discounts, line)
+		applied.Amount += line.Amount
+	}
+
+	if applied.Amount > 0 || p.Type == TypeFreeShipping {
+		cart.Discount.Amount += applied.Amount
+		cart.Discounts = append(cart.Discounts, applied)
+	}
+}
+
+// remaining is what's left of the line total after the promotions applied so far
+func remaining(item types.CartItem) int64 {
+	return item.Total.Amount - item.Discount.Amount
+}
+
+// getPercent is the discount on the units a buy x get y promotion gives, without a percent they're free
+func getPercent(p *types.Promotion) int {
+	if p.Percent == 0 {
+		return 100
+	}
+	return p.Percent
+}
+
+func describe(p *types.Promotion, currency string) string {
+	switch p.Type {
+	case TypePercentage:
+		return fmt.Sprintf("%d%% off", p.Percent)
+	case TypeFixed:
+		return fmt.Sprintf("%s %s off", money.Format(money.New(p.Amount, currency)), currency)
+	case TypeBuyXGetY:
+		if getPercent(p) == 100 {
+			return fmt.Sprintf("buy %d get %d free", p.BuyQuantity, p.GetQuantity)
+		}
+		return fmt.Sprintf("buy %d get %d at %d%% off", p.BuyQuantity, p.GetQuantity, p.Percent)
+	case TypeFreeShipping:
+		return "free shipping"
+	}
+	return p.Type
+}
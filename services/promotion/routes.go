@@ -0,0 +1,231 @@
+package promotion
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.PromotionStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.PromotionStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/promotions", auth.WithRoles(h.handleGetPromotions, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/promotions/{id:[0-9]+}", auth.WithRoles(h.handleGetPromotion, h.userStore, "admin")).Methods("GET")
+	router.HandleFunc("/promotions", auth.WithRoles(h.handleCreatePromotion, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/promotions/{id:[0-9]+}", auth.WithRoles(h.handleUpdatePromotion, h.userStore, "admin")).Methods("PUT")
+	router.HandleFunc("/promotions/{id:[0-9]+}", auth.WithRoles(h.handleDeletePromotion, h.userStore, "admin")).Methods("DELETE")
+}
+
+// @Summary List promotions
+// @Description Lists all promotions and coupons in the order they apply, with how often they were used
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.Promotion
+// @Router /promotions [get]
+func (h *Handler) handleGetPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.store.GetPromotions()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, promotions)
+}
+
+// @Summary Get promotion
+// @Description Returns a single promotion
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} types.Promotion
+// @Failure 404 {object} map[string]string "Promotion not found"
+// @Router /promotions/{id} [get]
+func (h *Handler) handleGetPromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	promotion, err := h.store.GetPromotionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, promotion)
+}
+
+// @Summary Create promotion
+// @Description Creates a promotion. With a code it's a coupon customers put on their cart, without one it applies
+// @Description to every cart that qualifies. Amounts are in minor units of the base currency.
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param promotionPayload body types.PromotionPayload true "Promotion payload"
+// @Success 201 {object} types.Promotion
+// @Failure 400 {object} map[string]string "Invalid promotion data"
+// @Router /promotions [post]
+func (h *Handler) handleCreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var payload types.PromotionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	promotion := types.Promotion{}
+	if err := h.applyPayload(&promotion, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreatePromotion(&promotion); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, promotion)
+}
+
+// @Summary Update promotion
+// @Description Replaces the rules of a promotion, carts pick them up on their next recalculation. Placed orders keep their discounts.
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Param promotionPayload body types.PromotionPayload true "Promotion payload"
+// @Success 200 {object} types.Promotion
+// @Failure 400 {object} map[string]string "Invalid promotion data"
+// @Failure 404 {object} map[string]string "Promotion not found"
+// @Router /promotions/{id} [put]
+func (h *Handler) handleUpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	promotion, err := h.store.GetPromotionByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.PromotionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyPayload(promotion, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdatePromotion(promotion); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, promotion)
+}
+
+// @Summary Delete promotion
+// @Description Deletes a promotion, carts lose its discount while placed orders keep it
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} map[string]string "Promotion deleted"
+// @Failure 404 {object} map[string]string "Promotion not found"
+// @Router /promotions/{id} [delete]
+func (h *Handler) handleDeletePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeletePromotion(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Promotion deleted"})
+}
+
+// applyPayload copies the payload onto the promotion after checking the code is free and the window makes sense
+func (h *Handler) applyPayload(promotion *types.Promotion, payload types.PromotionPayload) error {
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+	if payload.Type == TypeBuyXGetY && (payload.BuyQuantity < 1 || payload.GetQuantity < 1) {
+		return fmt.Errorf("buy x get y promotions need a buyQuantity and getQuantity of at least 1")
+	}
+
+	promotion.Code = nil
+	if code := normalizeCode(payload.Code); code != "" {
+		if existing, err := h.store.GetPromotionByCode(code); err == nil && existing.ID != promotion.ID {
+			return fmt.Errorf("coupon with code %s already exists", code)
+		}
+		promotion.Code = &code
+	}
+
+	promotion.Name = payload.Name
+	promotion.Type = payload.Type
+	promotion.Percent = payload.Percent
+	promotion.Amount = payload.Amount
+	promotion.BuyQuantity = payload.BuyQuantity
+	promotion.GetQuantity = payload.GetQuantity
+	promotion.ProductIDs = payload.ProductIDs
+	promotion.CategoryIDs = payload.CategoryIDs
+	promotion.MinSubtotal = payload.MinSubtotal
+	promotion.StartsAt = payload.StartsAt
+	promotion.EndsAt = payload.EndsAt
+	promotion.UsageLimit = payload.UsageLimit
+	promotion.PerCustomerLimit = payload.PerCustomerLimit
+	promotion.Priority = payload.Priority
+	promotion.Active = true
+	if payload.Active != nil {
+		promotion.Active = *payload.Active
+	}
+
+	return nil
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,187 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+// ErrUsedUp is returned at checkout when a promotion reached one of its usage limits in the meantime
+var ErrUsedUp = errors.New("promotion is used up")
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetPromotions() ([]types.Promotion, error) {
+	var promotions []types.Promotion
+	result := s.db.Order("priority DESC, id").Find(&promotions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return promotions, nil
+}
+
+func (s *Store) GetPromotionByID(id uint) (*types.Promotion, error) {
+	var promotion types.Promotion
+	result := s.db.First(&promotion, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, result.Error
+	}
+	return &promotion, nil
+}
+
+func (s *Store) GetPromotionByCode(code string) (*types.Promotion, error) {
+	var promotion types.Promotion
+	result := s.db.Where("code = ?", normalizeCode(code)).First(&promotion)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("coupon not found")
+		}
+		return nil, result.Error
+	}
+	return &promotion, nil
+}
+
+// GetActivePromotions returns the promotions that run at the time and apply by themselves, plus the
+// coupon of the code. They are ordered by the priority they apply in.
+func (s *Store) GetActivePromotions(at time.Time, code string) ([]types.Promotion, error) {
+	var promotions []types.Promotion
+	query := s.db.
+		Where("active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at)
+	if code != "" {
+		query = query.Where("code IS NULL OR code = ?", normalizeCode(code))
+	} else {
+		query = query.Where("code IS NULL")
+	}
+
+	result := query.Order("priority DESC, id").Find(&promotions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return promotions, nil
+}
+
+// CountRedemptions counts the orders of the user that used the promotion
+func (s *Store) CountRedemptions(promotionID uint, userID int) (int, error) {
+	var count int64
+	result := s.db.Model(&types.PromotionRedemption{}).
+		Where("promotion_id = ? AND user_id = ?", promotionID, userID).
+		Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int(count), nil
+}
+
+func (s *Store) CreatePromotion(promotion *types.Promotion) error {
+	result := s.db.Create(promotion)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// UpdatePromotion saves the promotion, the usage count is left to the redemptions
+func (s *Store) UpdatePromotion(promotion *types.Promotion) error {
+	result := s.db.Omit("usage_count").Save(promotion)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) DeletePromotion(id uint) error {
+	result := s.db.Delete(&types.Promotion{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("promotion not found")
+	}
+	return nil
+}
+
+// RedeemTx counts the promotions of the order towards their limits, inside the transaction that creates the order.
+// The condition on the usage count keeps two checkouts from both taking the last use.
+func RedeemTx(tx *gorm.DB, order *types.Order) error {
+	for _, discount := range order.Discounts {
+		var promotion types.Promotion
+		if err := tx.First(&promotion, discount.PromotionID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("%w: %s was removed", ErrUsedUp, discount.Name)
+			}
+			return err
+		}
+
+		if promotion.PerCustomerLimit != nil {
+			var count int64
+			err := tx.Model(&types.PromotionRedemption{}).
+				Where("promotion_id = ? AND user_id = ?", promotion.ID, order.UserID).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if int(count) >= *promotion.PerCustomerLimit {
+				return fmt.Errorf("%w: %s is limited to %d uses per customer", ErrUsedUp, promotion.Name, *promotion.PerCustomerLimit)
+			}
+		}
+
+		result := tx.Model(&types.Promotion{}).
+			Where("id = ? AND (usage_limit IS NULL OR usage_count < usage_limit)", promotion.ID).
+			Update("usage_count", gorm.Expr("usage_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", ErrUsedUp, promotion.Name)
+		}
+
+		redemption := types.PromotionRedemption{
+			PromotionID: promotion.ID,
+			OrderID:     order.ID,
+			UserID:      order.UserID,
+			Amount:      discount.Amount,
+		}
+		if err := tx.Create(&redemption).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseTx gives the uses of a cancelled order back to its promotions
+func ReleaseTx(tx *gorm.DB, orderID uint) error {
+	var redemptions []types.PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	for _, redemption := range redemptions {
+		err := tx.Model(&types.Promotion{}).
+			Where("id = ? AND usage_count > 0", redemption.PromotionID).
+			Update("usage_count", gorm.Expr("usage_count - 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("order_id = ?", orderID).Delete(&types.PromotionRedemption{}).Error
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
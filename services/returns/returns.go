@@ -0,0 +1,181 @@
+package returns
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/services/payment"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// returnable are the statuses of orders whose goods left the warehouse, unshipped orders are refunded instead
+var returnable = []string{order.StatusFulfilled, order.StatusDelivered}
+
+// Desk takes return requests, moves them through approval and receipt and refunds them through the payments
+type Desk struct {
+	// refunding keeps two refunds of the same return from both reaching the provider
+	refunding sync.Mutex
+	store     types.ReturnStore
+	orders    types.OrderStore
+	payments  *payment.Processor
+}
+
+func NewDesk(store types.ReturnStore, orders types.OrderStore, payments *payment.Processor) *Desk {
+	return &Desk{store: store, orders: orders, payments: payments}
+}
+
+// Request asks to return lines of an order within the return window. Each line is worth what was paid
+// for its quantity after discounts and with the tax, the shipping isn't part of it.
+func (d *Desk) Request(o *types.Order, payload types.ReturnPayload, userID int) (*types.Return, error) {
+	if !slices.Contains(returnable, o.Status) {
+		return nil, fmt.Errorf("only shipped orders can be returned, this one is %s", o.Status)
+	}
+	if window := Window(); window > 0 && time.Since(shippedAt(o)) > window {
+		return nil, fmt.Errorf("the return window of %d days has closed", int(window.Hours()/24))
+	}
+
+	r := &types.Return{
+		OrderID:  o.ID,
+		UserID:   o.UserID,
+		Note:     payload.Note,
+		Refund:   money.New(0, o.Total.Currency),
+		Refunded: money.New(0, o.Total.Currency),
+	}
+	for _, requested := range payload.Items {
+		i := slices.IndexFunc(o.Items, func(item types.OrderItem) bool {
+			return item.ID == requested.OrderItemID
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("order item %d not found", requested.OrderItemID)
+		}
+		if slices.ContainsFunc(r.Items, func(item types.ReturnItem) bool {
+			return item.OrderItemID == requested.OrderItemID
+		}) {
+			return nil, fmt.Errorf("order item %d is listed twice", requested.OrderItemID)
+		}
+
+		line := o.Items[i]
+		if requested.Quantity > line.Quantity {
+			return nil, fmt.Errorf("only %d of %s were ordered", line.Quantity, line.Name)
+		}
+
+		refund := money.New(worth(line, o.TaxInclusive, requested.Quantity), o.Total.Currency)
+		r.Items = append(r.Items, types.ReturnItem{
+			OrderItemID: line.ID,
+			ProductID:   line.ProductID,
+			VariantID:   line.VariantID,
+			Name:        line.Name,
+			SKU:         line.SKU,
+			Quantity:    requested.Quantity,
+			Reason:      requested.Reason,
+			Refund:      refund,
+			Restock:     true,
+		})
+		r.Refund.Amount += refund.Amount
+	}
+
+	if err := d.store.CreateReturn(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Decide approves or rejects a return, or cancels it for the customer
+func (d *Desk) Decide(r *types.Return, status, note string, userID int) error {
+	return d.store.TransitionReturn(r, status, note, &userID)
+}
+
+// Receive books the goods of an approved return in, the discarded items came back unsellable and stay out of stock
+func (d *Desk) Receive(r *types.Return, discard []uint, note string, userID int) error {
+	for _, id := range discard {
+		if !slices.ContainsFunc(r.Items, func(item types.ReturnItem) bool { return item.ID == id }) {
+			return fmt.Errorf("return item %d not found", id)
+		}
+	}
+	for i := range r.Items {
+		r.Items[i].Restock = !slices.Contains(discard, r.Items[i].ID)
+	}
+	return d.store.TransitionReturn(r, StatusReceived, note, &userID)
+}
+
+// Refund gives the money of a received return back through the payment provider, amount replaces the
+// worth of the returned lines when set. Refunding everything that was captured refunds the order.
+// The return is marked refunding with the amount before the provider is called, a refund that failed
+// on the way is retried with the same amount and key and pays nothing out twice.
+func (d *Desk) Refund(r *types.Return, amount *int64, note string, userID int) error {
+	d.refunding.Lock()
+	defer d.refunding.Unlock()
+
+	current, err := d.store.GetReturnByID(r.ID)
+	if err != nil {
+		return err
+	}
+	*r = *current
+
+	o, err := d.orders.GetOrderByID(r.OrderID)
+	if err != nil {
+		return err
+	}
+
+	switch r.Status {
+	case StatusReceived:
+		r.Refunded = r.Refund
+		if amount != nil {
+			r.Refunded.Amount = *amount
+		}
+		refundable, err := d.payments.Refundable(o, r.Refunded.Currency)
+		if err != nil {
+			return err
+		}
+		if r.Refunded.Amount <= 0 || r.Refunded.Amount > refundable {
+			return fmt.Errorf("refund of %s %s doesn't fit the %s %s captured and not refunded", money.Format(r.Refunded), r.Refunded.Currency,
+				money.Format(money.New(refundable, r.Refunded.Currency)), r.Refunded.Currency)
+		}
+		if err := d.store.TransitionReturn(r, StatusRefunding, note, &userID); err != nil {
+			return err
+		}
+	case StatusRefunding:
+		if amount != nil && *amount != r.Refunded.Amount {
+			return fmt.Errorf("a refund of %s %s is under way, retry it with the same amount", money.Format(r.Refunded), r.Refunded.Currency)
+		}
+	default:
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, r.Status, StatusRefunded)
+	}
+
+	if err := d.payments.Refund(o, r.Refunded, fmt.Sprintf("return %d", r.ID), fmt.Sprintf("return-%d", r.ID)); err != nil {
+		return err
+	}
+
+	return d.store.TransitionReturn(r, StatusRefunded, note, &userID)
+}
+
+// worth is the share of what was paid for the line that quantity units of it make up
+func worth(line types.OrderItem, taxInclusive bool, quantity int) int64 {
+	paid := line.Total.Amount - line.Discount.Amount
+	if !taxInclusive {
+		paid += line.Tax.Amount
+	}
+	if quantity == line.Quantity {
+		return paid
+	}
+	return money.Round(new(big.Rat).SetFrac(big.NewInt(paid*int64(quantity)), big.NewInt(int64(line.Quantity))))
+}
+
+// shippedAt is when the return window of the order opened, its delivery or else its fulfillment
+func shippedAt(o *types.Order) time.Time {
+	at := o.UpdatedAt
+	for _, transition := range o.Transitions {
+		switch transition.To {
+		case order.StatusDelivered:
+			return transition.CreatedAt
+		case order.StatusFulfilled:
+			at = transition.CreatedAt
+		}
+	}
+	return at
+}
@@ -0,0 +1,390 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store      types.ReturnStore
+	orderStore types.OrderStore
+	desk       *Desk
+	userStore  types.UserStore
+}
+
+func NewHandler(store types.ReturnStore, orderStore types.OrderStore, desk *Desk, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orderStore: orderStore, desk: desk, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/orders/{id:[0-9]+}/returns", auth.WithJWTAuth(h.handleRequestReturn, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/returns", auth.WithJWTAuth(h.handleGetMyReturns, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/returns/{id:[0-9]+}", auth.WithJWTAuth(h.handleGetMyReturn, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/returns/{id:[0-9]+}/cancel", auth.WithJWTAuth(h.handleCancelMyReturn, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/returns", auth.WithRoles(h.handleGetReturns, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/returns/{id:[0-9]+}", auth.WithRoles(h.handleGetReturn, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/returns/{id:[0-9]+}/approve", auth.WithRoles(h.handleApproveReturn, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/returns/{id:[0-9]+}/reject", auth.WithRoles(h.handleRejectReturn, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/returns/{id:[0-9]+}/receive", auth.WithRoles(h.handleReceiveReturn, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/returns/{id:[0-9]+}/refund", auth.WithRoles(h.handleRefundReturn, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary Request a return
+// @Description Asks to return lines of a shipped order of the logged in user within the return window.
+// @Description Reasons are damaged, defective, wrong_item, not_as_described, no_longer_needed or other.
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param returnPayload body types.ReturnPayload true "Lines to return"
+// @Success 201 {object} types.Return
+// @Failure 400 {object} map[string]string "Order or lines can't be returned"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /me/orders/{id}/returns [post]
+func (h *Handler) handleRequestReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ReturnPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	order, err := h.orderStore.GetOrderByID(id)
+	if err != nil || order.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+
+	ret, err := h.desk.Request(order, payload, userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, ret)
+}
+
+// @Summary My returns
+// @Description Lists the returns of the logged in user, newest first
+// @Tags Returns
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.Return
+// @Router /me/returns [get]
+func (h *Handler) handleGetMyReturns(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	returns, err := h.store.GetReturns(types.ReturnFilter{UserID: &userID})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+// @Summary My return
+// @Description Returns a return of the logged in user with its items and status history
+// @Tags Returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} types.Return
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /me/returns/{id} [get]
+func (h *Handler) handleGetMyReturn(w http.ResponseWriter, r *http.Request) {
+	ret, err := h.myReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// @Summary Cancel my return
+// @Description Cancels a return of the logged in user whose goods haven't been received yet
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param returnDecisionPayload body types.ReturnDecisionPayload false "Reason"
+// @Success 200 {object} types.Return
+// @Failure 400 {object} map[string]string "Return can't be cancelled"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /me/returns/{id}/cancel [post]
+func (h *Handler) handleCancelMyReturn(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parseDecision(w, r)
+	if !ok {
+		return
+	}
+
+	ret, err := h.myReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	h.decide(w, r, ret, StatusCancelled, payload.Note)
+}
+
+// @Summary List returns
+// @Description Lists all returns, newest first
+// @Tags Returns
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only returns in this status"
+// @Param orderId query int false "Only returns of this order"
+// @Success 200 {array} types.Return
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Router /returns [get]
+func (h *Handler) handleGetReturns(w http.ResponseWriter, r *http.Request) {
+	filter := types.ReturnFilter{Status: r.URL.Query().Get("status")}
+	if v := r.URL.Query().Get("orderId"); v != "" {
+		orderID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid orderId"))
+			return
+		}
+		filter.OrderID = uint(orderID)
+	}
+
+	returns, err := h.store.GetReturns(filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+// @Summary Get return
+// @Description Returns a return with its items and status history
+// @Tags Returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} types.Return
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id} [get]
+func (h *Handler) handleGetReturn(w http.ResponseWriter, r *http.Request) {
+	ret, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// @Summary Approve return
+// @Description Approves a requested return, the customer sends the goods back next
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param returnDecisionPayload body types.ReturnDecisionPayload false "Note for the customer"
+// @Success 200 {object} types.Return
+// @Failure 400 {object} map[string]string "Return can't be approved"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id}/approve [post]
+func (h *Handler) handleApproveReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleDecision(w, r, StatusApproved)
+}
+
+// @Summary Reject return
+// @Description Rejects a requested return, or a received one whose goods don't qualify for a refund
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param returnDecisionPayload body types.ReturnDecisionPayload false "Reason"
+// @Success 200 {object} types.Return
+// @Failure 400 {object} map[string]string "Return can't be rejected"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id}/reject [post]
+func (h *Handler) handleRejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.handleDecision(w, r, StatusRejected)
+}
+
+// @Summary Receive return
+// @Description Books the goods of an approved return in and puts them back into the warehouse they shipped from.
+// @Description Items listed as discarded came back unsellable and aren't restocked.
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param returnReceivePayload body types.ReturnReceivePayload false "Discarded items"
+// @Success 200 {object} types.Return
+// @Failure 400 {object} map[string]string "Return can't be received"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id}/receive [post]
+func (h *Handler) handleReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReturnReceivePayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	ret, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.desk.Receive(ret, payload.DiscardItemIDs, payload.Note, auth.GetUserIDFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// @Summary Refund return
+// @Description Refunds a received return through the payment provider, by default the amount paid for the returned lines.
+// @Description Refunding everything that was captured refunds the order. A refund that failed halfway leaves the return
+// @Description refunding, calling this again finishes it with the same amount.
+// @Tags Returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param returnRefundPayload body types.ReturnRefundPayload false "Amount in minor units"
+// @Success 200 {object} types.Return
+// @Failure 400 {object} map[string]string "Return can't be refunded"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id}/refund [post]
+func (h *Handler) handleRefundReturn(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReturnRefundPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	ret, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.desk.Refund(ret, payload.Amount, payload.Note, auth.GetUserIDFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+func (h *Handler) handleDecision(w http.ResponseWriter, r *http.Request, status string) {
+	payload, ok := parseDecision(w, r)
+	if !ok {
+		return
+	}
+
+	ret, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	h.decide(w, r, ret, status, payload.Note)
+}
+
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, ret *types.Return, status, note string) {
+	if err := h.desk.Decide(ret, status, note, auth.GetUserIDFromContext(r.Context())); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+func (h *Handler) getReturn(r *http.Request) (*types.Return, error) {
+	id, err := parseID(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.store.GetReturnByID(id)
+}
+
+func (h *Handler) myReturn(r *http.Request) (*types.Return, error) {
+	ret, err := h.getReturn(r)
+	if err != nil {
+		return nil, err
+	}
+	if ret.UserID != auth.GetUserIDFromContext(r.Context()) {
+		return nil, fmt.Errorf("return not found")
+	}
+	return ret, nil
+}
+
+// parseDecision reads the optional note of a decision, false means the error was written
+func parseDecision(w http.ResponseWriter, r *http.Request) (types.ReturnDecisionPayload, bool) {
+	var payload types.ReturnDecisionPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return payload, false
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return payload, false
+	}
+	return payload, true
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,52 @@
+package returns
+
+import (
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusCancelled = "cancelled"
+	StatusReceived  = "received"
+	StatusRefunding = "refunding"
+	StatusRefunded  = "refunded"
+)
+
+var ErrInvalidTransition = errors.New("invalid return transition")
+
+// transitions lists the statuses a return can move to from each status. The customer cancels returns
+// until the goods arrived, goods that arrived unfit for a refund can still be rejected. Refunding claims
+// the refund before the money goes out, so a failed refund is retried and never paid out twice.
+var transitions = map[string][]string{
+	StatusRequested: {StatusApproved, StatusRejected, StatusCancelled},
+	StatusApproved:  {StatusReceived, StatusCancelled},
+	StatusReceived:  {StatusRefunding, StatusRejected},
+	StatusRefunding: {StatusRefunded},
+}
+
+// open are the statuses of returns whose quantities can't be returned again
+var open = []string{StatusRequested, StatusApproved, StatusReceived, StatusRefunding, StatusRefunded}
+
+// CanTransition reports whether a return in status from may move to status to
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Window is how long after delivery an order can be returned, RETURN_WINDOW of 0 accepts returns
+// at any time
+func Window() time.Duration {
+	if v := os.Getenv("RETURN_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err == nil && window >= 0 {
+			return window
+		}
+		log.Printf("invalid RETURN_WINDOW %q, using 720h", v)
+	}
+	return 30 * 24 * time.Hour
+}
@@ -0,0 +1,194 @@
+package returns
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/inventory"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetReturns lists returns with their items, newest first
+func (s *Store) GetReturns(filter types.ReturnFilter) ([]types.Return, error) {
+	query := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("id DESC")
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.OrderID != 0 {
+		query = query.Where("order_id = ?", filter.OrderID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var returns []types.Return
+	if err := query.Find(&returns).Error; err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+func (s *Store) GetReturnByID(id uint) (*types.Return, error) {
+	var r types.Return
+	result := s.db.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&r, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("return not found")
+		}
+		return nil, result.Error
+	}
+	return &r, nil
+}
+
+// CreateReturn stores the return with its items and first step. The quantities of the order lines that
+// open returns hold are counted in the same transaction, so two requests can't return a unit twice.
+func (s *Store) CreateReturn(r *types.Return) error {
+	r.Status = StatusRequested
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range r.Items {
+			var line types.OrderItem
+			if err := tx.Where("id = ? AND order_id = ?", item.OrderItemID, r.OrderID).First(&line).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return fmt.Errorf("order item %d not found", item.OrderItemID)
+				}
+				return err
+			}
+
+			var returned int
+			result := tx.Model(&types.ReturnItem{}).
+				Joins("JOIN returns ON returns.id = return_items.return_id").
+				Where("return_items.order_item_id = ? AND returns.status IN ?", item.OrderItemID, open).
+				Select("COALESCE(SUM(return_items.quantity), 0)").
+				Scan(&returned)
+			if result.Error != nil {
+				return result.Error
+			}
+			if returned+item.Quantity > line.Quantity {
+				return fmt.Errorf("only %d of %s can be returned", line.Quantity-returned, line.Name)
+			}
+		}
+
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		return tx.Create(&types.ReturnTransition{
+			ReturnID: r.ID,
+			To:       StatusRequested,
+			Note:     r.Note,
+			UserID:   &r.UserID,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	created, err := s.GetReturnByID(r.ID)
+	if err != nil {
+		return err
+	}
+	*r = *created
+	dispatch(*r, "")
+	return nil
+}
+
+// TransitionReturn moves the return on and records the step. Receiving puts the items marked Restock
+// back into the warehouse their order shipped from, items of untracked products aren't restocked.
+func (s *Store) TransitionReturn(r *types.Return, status, note string, userID *int) error {
+	from := r.Status
+	if !CanTransition(from, status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, status)
+	}
+
+	var movements []*types.StockMovement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// the status in the condition keeps two concurrent transitions from both passing
+		result := tx.Model(&types.Return{}).Where("id = ? AND status = ?", r.ID, from).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: return %d changed meanwhile", ErrInvalidTransition, r.ID)
+		}
+
+		switch status {
+		case StatusReceived:
+			for _, item := range r.Items {
+				var line types.OrderItem
+				if err := tx.First(&line, item.OrderItemID).Error; err != nil {
+					return err
+				}
+
+				restock := item.Restock && line.ReservationID != nil
+				if restock {
+					movement, err := inventory.ReturnTx(tx, *line.ReservationID, item.Quantity, fmt.Sprintf("return-%d", r.ID), userID)
+					if err != nil {
+						return err
+					}
+					movements = append(movements, movement)
+				}
+				if err := tx.Model(&types.ReturnItem{}).Where("id = ?", item.ID).Update("restock", restock).Error; err != nil {
+					return err
+				}
+			}
+		case StatusRefunding, StatusRefunded:
+			result := tx.Model(&types.Return{}).Where("id = ?", r.ID).Updates(map[string]any{
+				"refunded_amount":   r.Refunded.Amount,
+				"refunded_currency": r.Refunded.Currency,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return tx.Create(&types.ReturnTransition{
+			ReturnID: r.ID,
+			From:     from,
+			To:       status,
+			Note:     note,
+			UserID:   userID,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	inventory.CheckStock(s.db, movements...)
+
+	changed, err := s.GetReturnByID(r.ID)
+	if err != nil {
+		return err
+	}
+	*r = *changed
+	dispatch(*r, from)
+	return nil
+}
+
+// dispatch emits return.<status> for the status the return is in now
+func dispatch(r types.Return, from string) {
+	event.Dispatch(types.Event{
+		Name: "return." + r.Status,
+		Payload: types.ReturnEvent{
+			Return: r,
+			From:   from,
+			To:     r.Status,
+		},
+	})
+}
@@ -0,0 +1,431 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store        types.ReviewStore
+	productStore types.ProductStore
+	userStore    types.UserStore
+}
+
+func NewHandler(store types.ReviewStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, productStore: productStore, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/reviews", h.handleGetProductReviews).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/reviews", auth.WithJWTAuth(h.handleCreateReview, h.userStore)).Methods("POST")
+
+	router.HandleFunc("/me/reviews", auth.WithJWTAuth(h.handleGetMyReviews, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/reviews/{id:[0-9]+}", auth.WithJWTAuth(h.handleUpdateMyReview, h.userStore)).Methods("PUT")
+	router.HandleFunc("/me/reviews/{id:[0-9]+}", auth.WithJWTAuth(h.handleDeleteMyReview, h.userStore)).Methods("DELETE")
+
+	router.HandleFunc("/reviews", auth.WithRoles(h.handleGetReviews, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/reviews/{id:[0-9]+}/approve", auth.WithRoles(h.handleApproveReview, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/reviews/{id:[0-9]+}/reject", auth.WithRoles(h.handleRejectReview, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary Product reviews
+// @Description Lists the approved reviews of a product together with its rating
+// @Tags Reviews
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param sort query string false "Sort by" Enums(newest, oldest, highest, lowest)
+// @Param rating query int false "Only reviews with this rating"
+// @Param verified query bool false "Only reviews of verified purchases"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Reviews, cursors and rating"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{id}/reviews [get]
+func (h *Handler) handleGetProductReviews(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	filter := types.ReviewFilter{ProductID: product.ID, Status: StatusApproved, Sort: r.URL.Query().Get("sort")}
+	if _, ok := sorts[filter.Sort]; filter.Sort != "" && !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sort must be newest, oldest, highest or lowest"))
+		return
+	}
+	if v := r.URL.Query().Get("rating"); v != "" {
+		rating, err := strconv.Atoi(v)
+		if err != nil || rating < 1 || rating > 5 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("rating must be between 1 and 5"))
+			return
+		}
+		filter.Rating = rating
+	}
+	if v := r.URL.Query().Get("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid verified"))
+			return
+		}
+		filter.Verified = verified
+	}
+
+	reviews, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": reviews, "cursor": page, "rating": product.Rating})
+}
+
+// @Summary Review product
+// @Description Reviews a product as the logged in user, the review is shown once approved.
+// @Description Reviews of customers who bought the product are marked as verified.
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param reviewPayload body types.ReviewPayload true "Review payload"
+// @Success 201 {object} types.Review
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Failure 409 {object} map[string]string "Product is reviewed already"
+// @Router /products/{id}/reviews [post]
+func (h *Handler) handleCreateReview(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ReviewPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	user, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	verified, err := h.store.HasPurchased(userID, product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	review := &types.Review{
+		ProductID: product.ID,
+		UserID:    userID,
+		Author:    author(user),
+		Verified:  verified,
+	}
+	applyPayload(review, payload)
+	if err := h.store.CreateReview(review); err != nil {
+		if errors.Is(err, ErrAlreadyReviewed) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, review)
+}
+
+// @Summary My reviews
+// @Description Lists the reviews of the logged in user in every moderation status, newest first
+// @Tags Reviews
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Reviews and cursors"
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /me/reviews [get]
+func (h *Handler) handleGetMyReviews(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	reviews, page, ok := h.list(w, r, types.ReviewFilter{UserID: &userID})
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": reviews, "cursor": page})
+}
+
+// @Summary Edit my review
+// @Description Changes a review of the logged in user, the edited review goes back to moderation
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param reviewPayload body types.ReviewPayload true "Review payload"
+// @Success 200 {object} types.Review
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Router /me/reviews/{id} [put]
+func (h *Handler) handleUpdateMyReview(w http.ResponseWriter, r *http.Request) {
+	var payload types.ReviewPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	review, err := h.myReview(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	applyPayload(review, payload)
+	if err := h.store.UpdateReview(review); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, review)
+}
+
+// @Summary Delete my review
+// @Description Deletes a review of the logged in user and takes it out of the product rating
+// @Tags Reviews
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 204
+// @Failure 404 {object} map[string]string "Review not found"
+// @Router /me/reviews/{id} [delete]
+func (h *Handler) handleDeleteMyReview(w http.ResponseWriter, r *http.Request) {
+	review, err := h.myReview(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.DeleteReview(review); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Moderation queue
+// @Description Lists reviews to moderate, by default the pending ones oldest first
+// @Tags Reviews
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Moderation status" Enums(pending, approved, rejected) default(pending)
+// @Param productId query int false "Only reviews of this product"
+// @Param sort query string false "Sort by" Enums(newest, oldest, highest, lowest) default(oldest)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{} "Reviews and cursors"
+// @Failure 400 {object} map[string]string "Invalid filter, cursor or size"
+// @Router /reviews [get]
+func (h *Handler) handleGetReviews(w http.ResponseWriter, r *http.Request) {
+	filter := types.ReviewFilter{Status: r.URL.Query().Get("status"), Sort: r.URL.Query().Get("sort")}
+	if filter.Status == "" {
+		filter.Status = StatusPending
+	}
+	if filter.Status != StatusPending && filter.Status != StatusApproved && filter.Status != StatusRejected {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be %s, %s or %s", StatusPending, StatusApproved, StatusRejected))
+		return
+	}
+	if filter.Sort == "" {
+		filter.Sort = "oldest"
+	}
+	if _, ok := sorts[filter.Sort]; !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sort must be newest, oldest, highest or lowest"))
+		return
+	}
+	if v := r.URL.Query().Get("productId"); v != "" {
+		productID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid productId"))
+			return
+		}
+		filter.ProductID = uint(productID)
+	}
+
+	reviews, page, ok := h.list(w, r, filter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{"data": reviews, "cursor": page})
+}
+
+// @Summary Approve review
+// @Description Shows a review on its product and counts it towards the product rating
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param reviewModerationPayload body types.ReviewModerationPayload false "Moderation note"
+// @Success 200 {object} types.Review
+// @Failure 400 {object} map[string]string "Review is approved already"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Router /reviews/{id}/approve [post]
+func (h *Handler) handleApproveReview(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, StatusApproved)
+}
+
+// @Summary Reject review
+// @Description Hides a review, an approved review leaves the product rating
+// @Tags Reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param reviewModerationPayload body types.ReviewModerationPayload false "Reason"
+// @Success 200 {object} types.Review
+// @Failure 400 {object} map[string]string "Review is rejected already"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Router /reviews/{id}/reject [post]
+func (h *Handler) handleRejectReview(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, StatusRejected)
+}
+
+func (h *Handler) moderate(w http.ResponseWriter, r *http.Request, status string) {
+	var payload types.ReviewModerationPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	review, err := h.store.GetReviewByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.store.ModerateReview(review, status, payload.Note, auth.GetUserIDFromContext(r.Context())); err != nil {
+		if errors.Is(err, ErrInvalidModeration) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, review)
+}
+
+// list loads a page of reviews, false means the error was written
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, filter types.ReviewFilter) ([]types.Review, *types.CursorPage, bool) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return nil, nil, false
+		}
+		size = n
+	}
+
+	reviews, page, err := h.store.GetReviews(filter, r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return nil, nil, false
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	return reviews, page, true
+}
+
+// myReview loads the review of the path, reviews of other users are reported as not found
+func (h *Handler) myReview(r *http.Request) (*types.Review, error) {
+	id, err := parseID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := h.store.GetReviewByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != auth.GetUserIDFromContext(r.Context()) {
+		return nil, fmt.Errorf("review not found")
+	}
+	return review, nil
+}
+
+func applyPayload(review *types.Review, payload types.ReviewPayload) {
+	review.Rating = payload.Rating
+	review.Title = strings.TrimSpace(payload.Title)
+	review.Body = strings.TrimSpace(payload.Body)
+}
+
+// author is how a review is signed, the first name and the initial of the last name
+func author(user *types.User) string {
+	name := strings.TrimSpace(user.FirstName)
+	if last := []rune(strings.TrimSpace(user.LastName)); len(last) > 0 {
+		name += " " + string(last[0]) + "."
+	}
+	return name
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,250 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// ErrAlreadyReviewed is returned for a second review of the same product by the same customer, they edit the first one
+var ErrAlreadyReviewed = errors.New("product is reviewed already")
+
+var ErrInvalidModeration = errors.New("invalid review moderation")
+
+// sorts maps the sort parameter to the keyset of the listing
+var sorts = map[string]pagination.Keyset{
+	"newest":  {Column: "created_at", Desc: true},
+	"oldest":  {Column: "created_at"},
+	"highest": {Column: "rating", Desc: true},
+	"lowest":  {Column: "rating"},
+}
+
+// stars are the distribution columns of the product rating by review rating
+var stars = map[int]string{
+	1: "rating_one",
+	2: "rating_two",
+	3: "rating_three",
+	4: "rating_four",
+	5: "rating_five",
+}
+
+// purchased are the statuses of orders whose customer bought the goods and kept them
+var purchased = []string{order.StatusPaid, order.StatusFulfilled, order.StatusDelivered}
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetReviews lists reviews by filter.Sort, newest first by default
+func (s *Store) GetReviews(filter types.ReviewFilter, cursor string, limit int) ([]types.Review, *types.CursorPage, error) {
+	keyset, ok := sorts[filter.Sort]
+	if !ok {
+		keyset = sorts["newest"]
+	}
+	keyset.Filter = filter
+	keyset.Limit = limit
+	keyset.Cursor = cursor
+
+	query := s.db.Model(&types.Review{})
+	if filter.ProductID != 0 {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Rating != 0 {
+		query = query.Where("rating = ?", filter.Rating)
+	}
+	if filter.Verified {
+		query = query.Where("verified = ?", true)
+	}
+
+	return pagination.Paginate(query, keyset, func(r types.Review) (any, uint) {
+		if keyset.Column == "rating" {
+			return r.Rating, r.ID
+		}
+		return r.CreatedAt, r.ID
+	})
+}
+
+func (s *Store) GetReviewByID(id uint) (*types.Review, error) {
+	var review types.Review
+	result := s.db.First(&review, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("review not found")
+		}
+		return nil, result.Error
+	}
+	return &review, nil
+}
+
+func (s *Store) HasPurchased(userID int, productID uint) (bool, error) {
+	var count int64
+	result := s.db.Model(&types.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status IN ? AND order_items.product_id = ?", userID, purchased, productID).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// CreateReview stores the review for moderation, a customer reviews a product once
+func (s *Store) CreateReview(review *types.Review) error {
+	review.Status = StatusPending
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.Review{}).Where("product_id = ? AND user_id = ?", review.ProductID, review.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReviewed
+		}
+		return tx.Create(review).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	dispatch(*review, "")
+	return nil
+}
+
+// UpdateReview takes an approved review out of the rating of its product until it is approved again
+func (s *Store) UpdateReview(review *types.Review) error {
+	var from string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var stored types.Review
+		if err := tx.First(&stored, review.ID).Error; err != nil {
+			return err
+		}
+		from = stored.Status
+		if from == StatusApproved {
+			if err := rate(tx, stored.ProductID, stored.Rating, -1); err != nil {
+				return err
+			}
+		}
+
+		review.Status = StatusPending
+		review.ModerationNote = ""
+		review.ModeratedBy = nil
+		review.ModeratedAt = nil
+		return tx.Save(review).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	dispatch(*review, from)
+	return nil
+}
+
+func (s *Store) DeleteReview(review *types.Review) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var stored types.Review
+		if err := tx.First(&stored, review.ID).Error; err != nil {
+			return err
+		}
+		if stored.Status == StatusApproved {
+			if err := rate(tx, stored.ProductID, stored.Rating, -1); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&types.Review{}, review.ID).Error
+	})
+}
+
+// ModerateReview approves or rejects the review. Approved reviews count towards the rating of their
+// product, rejecting an approved review takes it out again.
+func (s *Store) ModerateReview(review *types.Review, status, note string, userID int) error {
+	from := review.Status
+	if from == status || (status != StatusApproved && status != StatusRejected) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidModeration, from, status)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// the status in the condition keeps two moderators from counting the review twice
+		result := tx.Model(&types.Review{}).Where("id = ? AND status = ?", review.ID, from).UpdateColumns(map[string]any{
+			"status":          status,
+			"moderation_note": note,
+			"moderated_by":    userID,
+			"moderated_at":    time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: review %d changed meanwhile", ErrInvalidModeration, review.ID)
+		}
+
+		switch {
+		case status == StatusApproved:
+			return rate(tx, review.ProductID, review.Rating, 1)
+		case from == StatusApproved:
+			return rate(tx, review.ProductID, review.Rating, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	moderated, err := s.GetReviewByID(review.ID)
+	if err != nil {
+		return err
+	}
+	*review = *moderated
+	dispatch(*review, from)
+	return nil
+}
+
+// rate adds a review of the given stars to the rating of the product, delta -1 takes it away again.
+// The average follows from the counters in the same transaction.
+func rate(tx *gorm.DB, productID uint, rating, delta int) error {
+	column, ok := stars[rating]
+	if !ok {
+		return fmt.Errorf("invalid rating %d", rating)
+	}
+
+	result := tx.Model(&types.Product{}).Where("id = ?", productID).UpdateColumns(map[string]any{
+		"rating_count": gorm.Expr("rating_count + ?", delta),
+		"rating_stars": gorm.Expr("rating_stars + ?", delta*rating),
+		column:         gorm.Expr(column+" + ?", delta),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return tx.Model(&types.Product{}).Where("id = ?", productID).
+		UpdateColumn("rating_average", gorm.Expr("CASE WHEN rating_count > 0 THEN ROUND(CAST(rating_stars AS REAL) / rating_count, 2) ELSE 0 END")).
+		Error
+}
+
+// dispatch emits review.<status> for the status the review is in now
+func dispatch(review types.Review, from string) {
+	event.Dispatch(types.Event{
+		Name:    "review." + review.Status,
+		Payload: types.ReviewEvent{Review: review, From: from},
+	})
+}
@@ -0,0 +1,63 @@
+package shipping
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// NewCarriers returns the carriers shipping methods can name
+func NewCarriers() map[string]types.Carrier {
+	carriers := make(map[string]types.Carrier)
+	for _, carrier := range []types.Carrier{&Manual{}, &Fake{}} {
+		carriers[carrier.Name()] = carrier
+	}
+	return carriers
+}
+
+// Manual is for parcels handed to a carrier outside of the system, operators enter the tracking
+// number when they ship and report the progress themselves
+type Manual struct{}
+
+func (m *Manual) Name() string {
+	return "manual"
+}
+
+func (m *Manual) CreateShipment(order types.Order, shipment types.Shipment) (*types.CarrierLabel, error) {
+	if shipment.TrackingNumber == "" {
+		return nil, fmt.Errorf("manual shipments need a tracking number")
+	}
+	return &types.CarrierLabel{TrackingNumber: shipment.TrackingNumber}, nil
+}
+
+func (m *Manual) Track(shipment types.Shipment) (*types.ShipmentUpdate, error) {
+	return nil, nil
+}
+
+// Fake makes up tracking numbers and moves its shipments one step closer to delivery every time
+// they're tracked, for development and demos
+type Fake struct{}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateShipment(order types.Order, shipment types.Shipment) (*types.CarrierLabel, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &types.CarrierLabel{TrackingNumber: fmt.Sprintf("FAKE%d%s", order.ID, hex.EncodeToString(b))}, nil
+}
+
+func (f *Fake) Track(shipment types.Shipment) (*types.ShipmentUpdate, error) {
+	switch shipment.Status {
+	case StatusShipped:
+		return &types.ShipmentUpdate{Status: StatusInTransit, Message: "parcel is on its way"}, nil
+	case StatusInTransit:
+		return &types.ShipmentUpdate{Status: StatusDelivered, Message: "parcel was delivered"}, nil
+	}
+	return nil, nil
+}
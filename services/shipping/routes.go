@@ -0,0 +1,449 @@
+package shipping
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store      types.ShippingStore
+	orderStore types.OrderStore
+	tracker    *Tracker
+	userStore  types.UserStore
+}
+
+func NewHandler(store types.ShippingStore, orderStore types.OrderStore, tracker *Tracker, userStore types.UserStore) *Handler {
+	return &Handler{store: store, orderStore: orderStore, tracker: tracker, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/shipping/zones", auth.WithRoles(h.handleGetZones, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/shipping/zones", auth.WithRoles(h.handleCreateZone, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/shipping/zones/{id:[0-9]+}", auth.WithRoles(h.handleUpdateZone, h.userStore, "admin")).Methods("PUT")
+	router.HandleFunc("/shipping/zones/{id:[0-9]+}", auth.WithRoles(h.handleDeleteZone, h.userStore, "admin")).Methods("DELETE")
+	router.HandleFunc("/shipping/zones/{id:[0-9]+}/methods", auth.WithRoles(h.handleCreateMethod, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/shipping/methods/{id:[0-9]+}", auth.WithRoles(h.handleUpdateMethod, h.userStore, "admin")).Methods("PUT")
+	router.HandleFunc("/shipping/methods/{id:[0-9]+}", auth.WithRoles(h.handleDeleteMethod, h.userStore, "admin")).Methods("DELETE")
+
+	router.HandleFunc("/orders/{id:[0-9]+}/ship", auth.WithRoles(h.handleShipOrder, h.userStore, "admin", "operator")).Methods("POST")
+	router.HandleFunc("/shipments/{id:[0-9]+}/updates", auth.WithRoles(h.handleUpdateShipment, h.userStore, "admin", "operator")).Methods("POST")
+}
+
+// @Summary List shipping zones
+// @Description Lists the shipping zones with their methods
+// @Tags Shipping
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.ShippingZone
+// @Router /shipping/zones [get]
+func (h *Handler) handleGetZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.store.GetZones()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zones)
+}
+
+// @Summary Create shipping zone
+// @Description Creates a shipping zone for a list of countries, a zone without countries covers the rest of the world
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param shippingZonePayload body types.ShippingZonePayload true "Shipping zone payload"
+// @Success 201 {object} types.ShippingZone
+// @Failure 400 {object} map[string]string "Invalid zone data"
+// @Router /shipping/zones [post]
+func (h *Handler) handleCreateZone(w http.ResponseWriter, r *http.Request) {
+	var payload types.ShippingZonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	zone := types.ShippingZone{}
+	if err := h.applyZonePayload(&zone, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreateZone(&zone); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, zone)
+}
+
+// @Summary Update shipping zone
+// @Description Renames a shipping zone and replaces its countries
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Zone ID"
+// @Param shippingZonePayload body types.ShippingZonePayload true "Shipping zone payload"
+// @Success 200 {object} types.ShippingZone
+// @Failure 400 {object} map[string]string "Invalid zone data"
+// @Failure 404 {object} map[string]string "Zone not found"
+// @Router /shipping/zones/{id} [put]
+func (h *Handler) handleUpdateZone(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	zone, err := h.store.GetZoneByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ShippingZonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyZonePayload(zone, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateZone(zone); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zone)
+}
+
+// @Summary Delete shipping zone
+// @Description Deletes a shipping zone with its methods, carts that chose one of them lose their shipping method
+// @Tags Shipping
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Zone ID"
+// @Success 200 {object} map[string]string "Zone deleted"
+// @Failure 404 {object} map[string]string "Zone not found"
+// @Router /shipping/zones/{id} [delete]
+func (h *Handler) handleDeleteZone(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteZone(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Shipping zone deleted"})
+}
+
+// @Summary Create shipping method
+// @Description Adds a shipping method to a zone. Flat methods cost price, weight and price methods look the
+// @Description cart weight in grams or the discounted cart value up in their rate table.
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Zone ID"
+// @Param shippingMethodPayload body types.ShippingMethodPayload true "Shipping method payload"
+// @Success 201 {object} types.ShippingMethod
+// @Failure 400 {object} map[string]string "Invalid method data"
+// @Failure 404 {object} map[string]string "Zone not found"
+// @Router /shipping/zones/{id}/methods [post]
+func (h *Handler) handleCreateMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.store.GetZoneByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ShippingMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	method := types.ShippingMethod{ZoneID: id}
+	if err := h.applyMethodPayload(&method, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreateMethod(&method); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, method)
+}
+
+// @Summary Update shipping method
+// @Description Replaces the name, carrier and rates of a shipping method, placed orders keep what they paid
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Method ID"
+// @Param shippingMethodPayload body types.ShippingMethodPayload true "Shipping method payload"
+// @Success 200 {object} types.ShippingMethod
+// @Failure 400 {object} map[string]string "Invalid method data"
+// @Failure 404 {object} map[string]string "Method not found"
+// @Router /shipping/methods/{id} [put]
+func (h *Handler) handleUpdateMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	method, err := h.store.GetMethodByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.ShippingMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyMethodPayload(method, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateMethod(method); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, method)
+}
+
+// @Summary Delete shipping method
+// @Description Deletes a shipping method, shipments keep its name
+// @Tags Shipping
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Method ID"
+// @Success 200 {object} map[string]string "Method deleted"
+// @Failure 404 {object} map[string]string "Method not found"
+// @Router /shipping/methods/{id} [delete]
+func (h *Handler) handleDeleteMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.DeleteMethod(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Shipping method deleted"})
+}
+
+// @Summary Ship order
+// @Description Hands the shipment of a paid order to its carrier and fulfills the order. Manual carriers need
+// @Description the tracking number, other carriers create one.
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param shipPayload body types.ShipPayload false "Tracking number"
+// @Success 200 {object} types.Shipment
+// @Failure 400 {object} map[string]string "Order can't be shipped"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Router /orders/{id}/ship [post]
+func (h *Handler) handleShipOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ShipPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	order, err := h.orderStore.GetOrderByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	shipment, err := h.tracker.Ship(order, strings.TrimSpace(payload.TrackingNumber))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, shipment)
+}
+
+// @Summary Update shipment
+// @Description Reports the progress of a shipment by hand, for carriers that don't report it themselves.
+// @Description Delivered shipments deliver their order.
+// @Tags Shipping
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Param shipmentUpdatePayload body types.ShipmentUpdatePayload true "Shipment update payload"
+// @Success 200 {object} types.Shipment
+// @Failure 400 {object} map[string]string "Shipment can't move to the status"
+// @Failure 404 {object} map[string]string "Shipment not found"
+// @Router /shipments/{id}/updates [post]
+func (h *Handler) handleUpdateShipment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.ShipmentUpdatePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	shipment, err := h.store.GetShipmentByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := h.tracker.Update(shipment, types.ShipmentUpdate{Status: payload.Status, Message: payload.Message}); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, shipment)
+}
+
+func (h *Handler) applyZonePayload(zone *types.ShippingZone, payload types.ShippingZonePayload) error {
+	countries := make([]string, 0, len(payload.Countries))
+	for _, country := range payload.Countries {
+		countries = append(countries, strings.ToUpper(country))
+	}
+
+	zones, err := h.store.GetZones()
+	if err != nil {
+		return err
+	}
+	for _, other := range zones {
+		if other.ID == zone.ID {
+			continue
+		}
+		if len(countries) == 0 && len(other.Countries) == 0 {
+			return fmt.Errorf("zone %s already covers the rest of the world", other.Name)
+		}
+		for _, country := range countries {
+			for _, taken := range other.Countries {
+				if country == taken {
+					return fmt.Errorf("%s belongs to zone %s already", country, other.Name)
+				}
+			}
+		}
+	}
+
+	zone.Name = payload.Name
+	zone.Countries = countries
+	return nil
+}
+
+func (h *Handler) applyMethodPayload(method *types.ShippingMethod, payload types.ShippingMethodPayload) error {
+	if _, ok := h.tracker.Carrier(payload.Carrier); !ok {
+		return fmt.Errorf("unknown carrier %s", payload.Carrier)
+	}
+	for i, row := range payload.Rates {
+		if row.Max != 0 && row.Max <= row.Min {
+			return fmt.Errorf("rate %d: max must be above min", i+1)
+		}
+	}
+
+	method.Name = payload.Name
+	method.Carrier = payload.Carrier
+	method.RateType = payload.RateType
+	method.Price = payload.Price
+	method.Rates = payload.Rates
+	method.VolumetricDivisor = payload.VolumetricDivisor
+	method.Position = payload.Position
+	method.Active = true
+	if payload.Active != nil {
+		method.Active = *payload.Active
+	}
+	return nil
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,209 @@
+package shipping
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+const (
+	RateFlat   = "flat"
+	RateWeight = "weight"
+	RatePrice  = "price"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusShipped   = "shipped"
+	StatusInTransit = "in_transit"
+	StatusDelivered = "delivered"
+	StatusException = "exception"
+	StatusReturned  = "returned"
+	StatusCancelled = "cancelled"
+)
+
+// Engine prices shipping from the zones and rate tables
+type Engine struct {
+	store types.ShippingStore
+}
+
+func NewEngine(store types.ShippingStore) *Engine {
+	return &Engine{store: store}
+}
+
+// Quote lists the active methods of the zone of the country that can deliver the cart, cheapest first.
+// Carts with free shipping pay nothing for any method.
+func (e *Engine) Quote(cart *types.Cart, country string) ([]types.ShippingQuote, error) {
+	quotes := []types.ShippingQuote{}
+	if country == "" || len(cart.Items) == 0 {
+		return quotes, nil
+	}
+
+	zones, err := e.store.GetZones()
+	if err != nil {
+		return nil, err
+	}
+	zone := zoneFor(zones, strings.ToUpper(country))
+	if zone == nil {
+		return quotes, nil
+	}
+
+	currency := cart.Subtotal.Currency
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+	for _, method := range zone.Methods {
+		if !method.Active {
+			continue
+		}
+		price, ok := rate(method, cart)
+		if !ok {
+			continue
+		}
+		if cart.FreeShipping {
+			price = 0
+		}
+		quotes = append(quotes, types.ShippingQuote{
+			MethodID: method.ID,
+			Name:     method.Name,
+			Carrier:  method.Carrier,
+			Zone:     zone.Name,
+			Price:    money.New(price, currency),
+		})
+	}
+
+	slices.SortStableFunc(quotes, func(a, b types.ShippingQuote) int {
+		return cmp.Compare(a.Price.Amount, b.Price.Amount)
+	})
+	return quotes, nil
+}
+
+// Apply adds the price of the chosen method to the cart total. A method that can't deliver the cart
+// anymore, e.g. after the destination changed, is taken off the cart.
+func (e *Engine) Apply(cart *types.Cart) error {
+	currency := cart.Subtotal.Currency
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+	cart.Shipping = money.New(0, currency)
+	cart.Weight = 0
+	for _, item := range cart.Items {
+		cart.Weight += item.Weight * item.Quantity
+	}
+
+	if cart.ShippingMethodID == nil {
+		return nil
+	}
+
+	quotes, err := e.Quote(cart, cart.Country)
+	if err != nil {
+		return err
+	}
+	for _, quote := range quotes {
+		if quote.MethodID == *cart.ShippingMethodID {
+			cart.Shipping = quote.Price
+			cart.Total.Amount += quote.Price.Amount
+			return nil
+		}
+	}
+
+	cart.ShippingMethodID = nil
+	return nil
+}
+
+// Check tells why the method can't deliver the cart, if it can't
+func (e *Engine) Check(cart *types.Cart, methodID uint) error {
+	if cart.Country == "" {
+		return fmt.Errorf("set the destination of the cart first")
+	}
+
+	quotes, err := e.Quote(cart, cart.Country)
+	if err != nil {
+		return err
+	}
+	for _, quote := range quotes {
+		if quote.MethodID == methodID {
+			return nil
+		}
+	}
+	return fmt.Errorf("shipping method %d doesn't deliver this cart to %s", methodID, cart.Country)
+}
+
+// Ready tells why the cart can't be checked out without a shipping method, shops without zones
+// don't ship and take every cart
+func (e *Engine) Ready(cart *types.Cart) error {
+	if cart.ShippingMethodID != nil {
+		return nil
+	}
+
+	zones, err := e.store.GetZones()
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+	if cart.Country == "" {
+		return fmt.Errorf("set the destination of the cart first")
+	}
+
+	quotes, err := e.Quote(cart, cart.Country)
+	if err != nil {
+		return err
+	}
+	if len(quotes) == 0 {
+		return fmt.Errorf("no shipping method delivers this cart to %s", cart.Country)
+	}
+	return fmt.Errorf("choose a shipping method")
+}
+
+// zoneFor finds the zone that lists the country, or else the zone without countries
+func zoneFor(zones []types.ShippingZone, country string) *types.ShippingZone {
+	var fallback *types.ShippingZone
+	for i := range zones {
+		if len(zones[i].Countries) == 0 {
+			if fallback == nil {
+				fallback = &zones[i]
+			}
+			continue
+		}
+		if slices.Contains(zones[i].Countries, country) {
+			return &zones[i]
+		}
+	}
+	return fallback
+}
+
+// rate prices the method for the cart, false means the rate table has no row for it
+func rate(method types.ShippingMethod, cart *types.Cart) (int64, bool) {
+	var value int64
+	switch method.RateType {
+	case RateFlat:
+		return method.Price, true
+	case RateWeight:
+		var weight, volume int64
+		for _, item := range cart.Items {
+			weight += int64(item.Weight) * int64(item.Quantity)
+			volume += item.Volume * int64(item.Quantity)
+		}
+		if method.VolumetricDivisor > 0 {
+			weight = max(weight, volume/int64(method.VolumetricDivisor))
+		}
+		value = weight
+	case RatePrice:
+		value = cart.Subtotal.Amount - cart.Discount.Amount
+	default:
+		return 0, false
+	}
+
+	for _, row := range method.Rates {
+		if value >= row.Min && (row.Max == 0 || value < row.Max) {
+			return row.Price, true
+		}
+	}
+	return 0, false
+}
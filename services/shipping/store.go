@@ -0,0 +1,176 @@
+package shipping
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetZones() ([]types.ShippingZone, error) {
+	var zones []types.ShippingZone
+	result := s.db.Preload("Methods", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Order("id").Find(&zones)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return zones, nil
+}
+
+func (s *Store) GetZoneByID(id uint) (*types.ShippingZone, error) {
+	var zone types.ShippingZone
+	result := s.db.Preload("Methods", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).First(&zone, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("shipping zone not found")
+		}
+		return nil, result.Error
+	}
+	return &zone, nil
+}
+
+func (s *Store) CreateZone(zone *types.ShippingZone) error {
+	result := s.db.Omit("Methods").Create(zone)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) UpdateZone(zone *types.ShippingZone) error {
+	result := s.db.Omit("Methods").Save(zone)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// DeleteZone removes the zone with its methods, shipments keep the name of their method
+func (s *Store) DeleteZone(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("zone_id = ?", id).Delete(&types.ShippingMethod{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&types.ShippingZone{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("shipping zone not found")
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetMethodByID(id uint) (*types.ShippingMethod, error) {
+	var method types.ShippingMethod
+	result := s.db.First(&method, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("shipping method not found")
+		}
+		return nil, result.Error
+	}
+	return &method, nil
+}
+
+func (s *Store) CreateMethod(method *types.ShippingMethod) error {
+	result := s.db.Create(method)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) UpdateMethod(method *types.ShippingMethod) error {
+	result := s.db.Save(method)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (s *Store) DeleteMethod(id uint) error {
+	result := s.db.Delete(&types.ShippingMethod{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("shipping method not found")
+	}
+	return nil
+}
+
+func (s *Store) GetShipmentByID(id uint) (*types.Shipment, error) {
+	var shipment types.Shipment
+	result := s.db.Preload("Updates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&shipment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("shipment not found")
+		}
+		return nil, result.Error
+	}
+	return &shipment, nil
+}
+
+func (s *Store) GetShipmentsByOrderID(orderID uint) ([]types.Shipment, error) {
+	var shipments []types.Shipment
+	result := s.db.Preload("Updates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("order_id = ?", orderID).Order("id").Find(&shipments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return shipments, nil
+}
+
+func (s *Store) GetShipmentsByStatus(statuses ...string) ([]types.Shipment, error) {
+	var shipments []types.Shipment
+	result := s.db.Where("status IN ?", statuses).Order("id").Find(&shipments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return shipments, nil
+}
+
+func (s *Store) CreateShipment(shipment *types.Shipment) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Updates").Create(shipment).Error; err != nil {
+			return err
+		}
+		update := types.ShipmentUpdate{ShipmentID: shipment.ID, Status: shipment.Status}
+		if err := tx.Create(&update).Error; err != nil {
+			return err
+		}
+		shipment.Updates = []types.ShipmentUpdate{update}
+		return nil
+	})
+}
+
+// UpdateShipment saves the shipment and records the update in its history
+func (s *Store) UpdateShipment(shipment *types.Shipment, update types.ShipmentUpdate) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Updates").Save(shipment).Error; err != nil {
+			return err
+		}
+		update.ShipmentID = shipment.ID
+		if err := tx.Create(&update).Error; err != nil {
+			return err
+		}
+		shipment.Updates = append(shipment.Updates, update)
+		return nil
+	})
+}
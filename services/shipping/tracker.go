@@ -0,0 +1,224 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/order"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Tracker hands shipments to their carriers and keeps them and their orders in line with what the carriers report
+type Tracker struct {
+	store    types.ShippingStore
+	orders   types.OrderStore
+	carriers map[string]types.Carrier
+}
+
+func NewTracker(store types.ShippingStore, orders types.OrderStore, carriers map[string]types.Carrier) *Tracker {
+	return &Tracker{store: store, orders: orders, carriers: carriers}
+}
+
+// Carrier finds a carrier by name
+func (t *Tracker) Carrier(name string) (types.Carrier, bool) {
+	carrier, ok := t.carriers[name]
+	return carrier, ok
+}
+
+// Ship hands the pending shipment of a paid order to its carrier and fulfills the order if that didn't happen yet. Orders that lost
+// their shipment, e.g. because creating it at checkout failed, get it created here.
+func (t *Tracker) Ship(o *types.Order, trackingNumber string) (*types.Shipment, error) {
+	if o.Status != order.StatusPaid && o.Status != order.StatusFulfilled {
+		return nil, fmt.Errorf("only paid orders can be shipped, this one is %s", o.Status)
+	}
+
+	shipment, err := t.pendingShipment(o)
+	if err != nil {
+		return nil, err
+	}
+
+	carrier, ok := t.Carrier(shipment.Carrier)
+	if !ok {
+		return nil, fmt.Errorf("unknown carrier %s", shipment.Carrier)
+	}
+
+	shipment.TrackingNumber = trackingNumber
+	label, err := carrier.CreateShipment(*o, *shipment)
+	if err != nil {
+		return nil, err
+	}
+	shipment.TrackingNumber = label.TrackingNumber
+	shipment.LabelURL = label.LabelURL
+
+	if err := t.Update(shipment, types.ShipmentUpdate{Status: StatusShipped, Message: "handed to " + carrier.Name()}); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// Update moves the shipment to the status of the update. Shipped orders are fulfilled and delivered
+// orders delivered, orders that moved on in the meantime stay as they are.
+func (t *Tracker) Update(shipment *types.Shipment, update types.ShipmentUpdate) error {
+	from := shipment.Status
+	if from == StatusDelivered || from == StatusReturned || from == StatusCancelled {
+		return fmt.Errorf("shipment %d is %s already", shipment.ID, from)
+	}
+	if update.Status != StatusShipped && update.Status != StatusCancelled && from == StatusPending {
+		return fmt.Errorf("shipment %d isn't shipped yet", shipment.ID)
+	}
+
+	now := time.Now()
+	shipment.Status = update.Status
+	switch update.Status {
+	case StatusShipped:
+		shipment.ShippedAt = &now
+	case StatusDelivered:
+		shipment.DeliveredAt = &now
+	}
+	if err := t.store.UpdateShipment(shipment, update); err != nil {
+		return err
+	}
+
+	var err error
+	switch update.Status {
+	case StatusShipped:
+		note := "shipped with " + shipment.Carrier
+		if shipment.TrackingNumber != "" {
+			note += ", tracking number " + shipment.TrackingNumber
+		}
+		_, err = t.orders.TransitionOrder(shipment.OrderID, order.StatusFulfilled, note, nil)
+	case StatusDelivered:
+		_, err = t.orders.TransitionOrder(shipment.OrderID, order.StatusDelivered, "delivered by "+shipment.Carrier, nil)
+	}
+	if err != nil && !errors.Is(err, order.ErrInvalidTransition) {
+		return err
+	}
+
+	event.Dispatch(types.Event{
+		Name:    "shipment." + shipment.Status,
+		Payload: types.ShipmentEvent{Shipment: *shipment, From: from},
+	})
+	return nil
+}
+
+// Track asks the carriers about every shipment on its way and applies what changed
+func (t *Tracker) Track() {
+	shipments, err := t.store.GetShipmentsByStatus(StatusShipped, StatusInTransit, StatusException)
+	if err != nil {
+		log.Printf("failed to load shipments to track: %v", err)
+		return
+	}
+
+	for i := range shipments {
+		shipment := &shipments[i]
+		carrier, ok := t.Carrier(shipment.Carrier)
+		if !ok {
+			continue
+		}
+
+		update, err := carrier.Track(*shipment)
+		if err != nil {
+			log.Printf("failed to track shipment %d with %s: %v", shipment.ID, carrier.Name(), err)
+			continue
+		}
+		if update == nil || update.Status == shipment.Status {
+			continue
+		}
+		if err := t.Update(shipment, *update); err != nil {
+			log.Printf("failed to update shipment %d: %v", shipment.ID, err)
+		}
+	}
+}
+
+// TrackShipmentsEvery tracks the shipments on their way until the process exits
+func TrackShipmentsEvery(tracker *Tracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		tracker.Track()
+	}
+}
+
+// RegisterListeners creates the shipment of new orders with a shipping method and cancels the
+// shipments of cancelled and refunded orders that didn't leave yet
+func (t *Tracker) RegisterListeners() {
+	event.Register("order."+order.StatusPending, func(e types.Event) {
+		created, ok := e.Payload.(types.OrderEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+		if created.From != "" || created.Order.ShippingMethodID == nil {
+			return
+		}
+		if _, err := t.pendingShipment(&created.Order); err != nil {
+			log.Printf("failed to create the shipment of order %d: %v", created.Order.ID, err)
+		}
+	})
+
+	cancel := func(e types.Event) {
+		changed, ok := e.Payload.(types.OrderEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+		shipments, err := t.store.GetShipmentsByOrderID(changed.Order.ID)
+		if err != nil {
+			log.Printf("failed to load the shipments of order %d: %v", changed.Order.ID, err)
+			return
+		}
+		for i := range shipments {
+			if shipments[i].Status != StatusPending {
+				continue
+			}
+			update := types.ShipmentUpdate{Status: StatusCancelled, Message: "order " + changed.To}
+			if err := t.Update(&shipments[i], update); err != nil {
+				log.Printf("failed to cancel shipment %d: %v", shipments[i].ID, err)
+			}
+		}
+	}
+	event.Register("order."+order.StatusCancelled, cancel)
+	event.Register("order."+order.StatusRefunded, cancel)
+}
+
+// pendingShipment finds the shipment of the order that didn't leave yet or creates it from the
+// shipping method chosen at checkout
+func (t *Tracker) pendingShipment(o *types.Order) (*types.Shipment, error) {
+	shipments, err := t.store.GetShipmentsByOrderID(o.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shipments {
+		if shipments[i].Status == StatusPending {
+			return &shipments[i], nil
+		}
+	}
+	if len(shipments) > 0 {
+		return nil, fmt.Errorf("order %d is shipped already", o.ID)
+	}
+	if o.ShippingMethodID == nil {
+		return nil, fmt.Errorf("order %d has no shipping method", o.ID)
+	}
+
+	shipment := &types.Shipment{
+		OrderID:  o.ID,
+		MethodID: o.ShippingMethodID,
+		Carrier:  "manual",
+		Price:    o.Shipping,
+		Status:   StatusPending,
+	}
+	// the method may be gone by now, the shipment then goes out by hand
+	if method, err := t.store.GetMethodByID(*o.ShippingMethodID); err == nil {
+		shipment.Method = method.Name
+		shipment.Carrier = method.Carrier
+	}
+
+	if err := t.store.CreateShipment(shipment); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// PrivatePrefix starts the keys of files that are only handed out through the API, like exports. Local
+// never serves them, public read policies on a bucket should leave the prefix out.
+const PrivatePrefix = "private/"
+
+// Storage keeps blobs such as product images under slash separated keys
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is where clients can download the object from
+	URL(key string) string
+}
+
+// New builds the storage selected by STORAGE_DRIVER, local disk unless it says s3
+func New() (Storage, error) {
+	switch driver := env("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocal(env("STORAGE_DIR", "uploads"), env("STORAGE_URL", "/uploads"))
+	case "s3":
+		config := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    env("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    env("S3_USE_SSL", "true") == "true",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		}
+		s3, err := NewS3(config)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s3.EnsureBucket(ctx, config.Region); err != nil {
+			return nil, fmt.Errorf("bucket %s: %v", config.Bucket, err)
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// CleanKey rejects keys that could escape the storage root
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return key, nil
+}
+
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,63 @@
+package tax
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.TaxRateStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.TaxRateStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/tax-rates", h.handleGetTaxRates).Methods("GET")
+	router.HandleFunc("/tax-rates/reload", auth.WithRoles(h.handleReloadTaxRates, h.userStore, "admin")).Methods("POST")
+}
+
+// @Summary List tax rates
+// @Description Lists the tax rates per country, region and tax class
+// @Tags Taxes
+// @Produce json
+// @Success 200 {array} types.TaxRate
+// @Router /tax-rates [get]
+func (h *Handler) handleGetTaxRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.store.GetTaxRates()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rates)
+}
+
+// @Summary Reload tax rates
+// @Description Reads the tax rate file from disk again and replaces the stored rates with its rates
+// @Tags Taxes
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.TaxRate
+// @Failure 400 {object} map[string]string "Tax rates file is missing or invalid"
+// @Router /tax-rates/reload [post]
+func (h *Handler) handleReloadTaxRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := LoadRatesFile(RatesFilePath())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.ReplaceTaxRates(rates); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.handleGetTaxRates(w, r)
+}
@@ -0,0 +1,116 @@
+package tax
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// RatesFile is the layout of the tax rate file, e.g.
+// {"rates": [{"country": "DE", "taxClass": "standard", "name": "VAT", "rate": "19"}]}
+type RatesFile struct {
+	Rates []types.TaxRate `json:"rates"`
+}
+
+// RatesFilePath is where LoadRatesFile reads from unless TAX_RATES_FILE says otherwise
+func RatesFilePath() string {
+	if path := os.Getenv("TAX_RATES_FILE"); path != "" {
+		return path
+	}
+	return "tax_rates.json"
+}
+
+// LoadRatesFile reads and checks a tax rate file without touching the database
+func LoadRatesFile(path string) ([]types.TaxRate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file RatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tax rates file %s: %v", path, err)
+	}
+
+	rates := make([]types.TaxRate, 0, len(file.Rates))
+	seen := make(map[string]bool)
+	for i, rate := range file.Rates {
+		rate.ID = 0
+		rate.Country = strings.ToUpper(strings.TrimSpace(rate.Country))
+		rate.Region = strings.ToUpper(strings.TrimSpace(rate.Region))
+		rate.TaxClass = strings.TrimSpace(rate.TaxClass)
+		rate.Name = strings.TrimSpace(rate.Name)
+		if rate.TaxClass == "" {
+			rate.TaxClass = ClassStandard
+		}
+
+		if len(rate.Country) != 2 {
+			return nil, fmt.Errorf("invalid tax rates file %s: rate %d needs a two letter country", path, i+1)
+		}
+		if rate.Name == "" {
+			return nil, fmt.Errorf("invalid tax rates file %s: rate %d needs a name", path, i+1)
+		}
+		r, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok || r.Sign() < 0 || r.Cmp(big.NewRat(100, 1)) > 0 {
+			return nil, fmt.Errorf("invalid tax rates file %s: rate %q of %s %s", path, rate.Rate, rate.Country, rate.Name)
+		}
+
+		key := strings.Join([]string{rate.Country, rate.Region, rate.TaxClass, rate.Name}, "|")
+		if seen[key] {
+			return nil, fmt.Errorf("invalid tax rates file %s: %s %s %s is listed twice", path, rate.Country, rate.TaxClass, rate.Name)
+		}
+		seen[key] = true
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+func (s *Store) GetTaxRates() ([]types.TaxRate, error) {
+	var rates []types.TaxRate
+	result := s.db.Order("country, region, tax_class, name").Find(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rates, nil
+}
+
+// GetTaxRatesFor returns the rates of the country and those of the region within it
+func (s *Store) GetTaxRatesFor(country, region string) ([]types.TaxRate, error) {
+	var rates []types.TaxRate
+	result := s.db.
+		Where("country = ? AND (region = '' OR region = ?)", strings.ToUpper(country), strings.ToUpper(region)).
+		Order("region, name").
+		Find(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rates, nil
+}
+
+// ReplaceTaxRates swaps all rates for the given ones, rates that are gone from the file stop applying
+func (s *Store) ReplaceTaxRates(rates []types.TaxRate) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&types.TaxRate{}).Error; err != nil {
+			return err
+		}
+		if len(rates) == 0 {
+			return nil
+		}
+		return tx.Create(&rates).Error
+	})
+}
@@ -0,0 +1,145 @@
+package tax
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// Table calculates taxes from the tax rate table. Every line is taxed with all rates of its tax class
+// in the country and the region.
+type Table struct {
+	store    types.TaxRateStore
+	rounding string
+}
+
+func NewTable(store types.TaxRateStore, rounding string) *Table {
+	return &Table{store: store, rounding: rounding}
+}
+
+func (t *Table) Name() string {
+	return "table"
+}
+
+func (t *Table) Calculate(request types.TaxRequest) (*types.TaxResult, error) {
+	rates, err := t.store.GetTaxRatesFor(request.Country, request.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	byClass := make(map[string][]types.TaxRate)
+	for _, rate := range rates {
+		byClass[rate.TaxClass] = append(byClass[rate.TaxClass], rate)
+	}
+
+	// exact[i][j] is the unrounded tax of line i at rate j of its class
+	exact := make([][]*big.Rat, len(request.Lines))
+	result := &types.TaxResult{Lines: make([]types.TaxLineResult, len(request.Lines))}
+	for i, line := range request.Lines {
+		class := line.TaxClass
+		if class == "" {
+			class = ClassStandard
+		}
+
+		classRates := byClass[class]
+		percents := make([]*big.Rat, len(classRates))
+		total := new(big.Rat)
+		for j, rate := range classRates {
+			percent, ok := new(big.Rat).SetString(rate.Rate)
+			if !ok {
+				return nil, fmt.Errorf("invalid rate %q of %s %s", rate.Rate, rate.Country, rate.Name)
+			}
+			percents[j] = percent.Quo(percent, big.NewRat(100, 1))
+			total.Add(total, percents[j])
+		}
+
+		// inclusive amounts are the net amount plus all rates, the tax is taken out of them
+		base := new(big.Rat).SetInt64(line.Amount)
+		if request.Inclusive {
+			base.Quo(base, total.Add(total, big.NewRat(1, 1)))
+		}
+
+		exact[i] = make([]*big.Rat, len(classRates))
+		for j, rate := range classRates {
+			exact[i][j] = new(big.Rat).Mul(base, percents[j])
+			result.Lines[i].Taxes = append(result.Lines[i].Taxes, types.TaxBreakdown{Name: rate.Name, Rate: rate.Rate})
+		}
+	}
+
+	if t.rounding == RoundingOrder {
+		roundPerOrder(result, exact)
+	} else {
+		for i := range result.Lines {
+			for j := range result.Lines[i].Taxes {
+				result.Lines[i].Taxes[j].Amount = money.Round(exact[i][j])
+			}
+		}
+	}
+
+	// totals per rate in the order the rates first show up
+	index := make(map[[2]string]int)
+	for i := range result.Lines {
+		for _, tax := range result.Lines[i].Taxes {
+			result.Lines[i].Amount += tax.Amount
+
+			key := [2]string{tax.Name, tax.Rate}
+			k, ok := index[key]
+			if !ok {
+				k = len(result.Taxes)
+				index[key] = k
+				result.Taxes = append(result.Taxes, types.TaxBreakdown{Name: tax.Name, Rate: tax.Rate})
+			}
+			result.Taxes[k].Amount += tax.Amount
+		}
+		result.Total += result.Lines[i].Amount
+	}
+
+	return result, nil
+}
+
+// roundPerOrder rounds the sum of every rate once and hands the units out to the lines, the lines
+// with the largest remainders get the units that rounding down left over
+func roundPerOrder(result *types.TaxResult, exact [][]*big.Rat) {
+	type share struct {
+		line, tax int
+		rest      *big.Rat
+	}
+	groups := make(map[[2]string][]share)
+	sums := make(map[[2]string]*big.Rat)
+	var keys [][2]string
+
+	for i := range result.Lines {
+		for j, tax := range result.Lines[i].Taxes {
+			key := [2]string{tax.Name, tax.Rate}
+			if _, ok := sums[key]; !ok {
+				sums[key] = new(big.Rat)
+				keys = append(keys, key)
+			}
+			sums[key].Add(sums[key], exact[i][j])
+
+			floor := new(big.Int).Quo(exact[i][j].Num(), exact[i][j].Denom())
+			result.Lines[i].Taxes[j].Amount = floor.Int64()
+			rest := new(big.Rat).Sub(exact[i][j], new(big.Rat).SetInt(floor))
+			groups[key] = append(groups[key], share{line: i, tax: j, rest: rest})
+		}
+	}
+
+	for _, key := range keys {
+		shares := groups[key]
+		left := money.Round(sums[key])
+		for _, s := range shares {
+			left -= result.Lines[s.line].Taxes[s.tax].Amount
+		}
+
+		sort.SliceStable(shares, func(a, b int) bool {
+			return shares[a].rest.Cmp(shares[b].rest) > 0
+		})
+		for k := 0; left > 0 && k < len(shares); k++ {
+			result.Lines[shares[k].line].Taxes[shares[k].tax].Amount++
+			left--
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tax
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yahyaammar-dev/pacebe/services/money"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// ClassStandard is the tax class of products that don't name another one
+const ClassStandard = "standard"
+
+const (
+	PricesExclusive = "exclusive"
+	PricesInclusive = "inclusive"
+)
+
+const (
+	RoundingLine  = "line"
+	RoundingOrder = "order"
+)
+
+// PricesMode is TAX_PRICES. Inclusive prices contain the tax already, exclusive ones get it added on top.
+func PricesMode() string {
+	if strings.EqualFold(os.Getenv("TAX_PRICES"), PricesInclusive) {
+		return PricesInclusive
+	}
+	return PricesExclusive
+}
+
+// RoundingMode is TAX_ROUNDING, the tax is rounded on every line or once per rate over the whole order
+func RoundingMode() string {
+	if strings.EqualFold(os.Getenv("TAX_ROUNDING"), RoundingOrder) {
+		return RoundingOrder
+	}
+	return RoundingLine
+}
+
+// New creates the provider from TAX_PROVIDER, the tax rate table is the default
+func New(store types.TaxRateStore) (types.TaxProvider, error) {
+	switch name := strings.ToLower(os.Getenv("TAX_PROVIDER")); name {
+	case "table", "":
+		return NewTable(store, RoundingMode()), nil
+	default:
+		return nil, fmt.Errorf("unknown TAX_PROVIDER %q", name)
+	}
+}
+
+// Engine adds the taxes of the provider to carts. Carts without a destination are taxed as if they
+// went to TAX_COUNTRY and TAX_REGION.
+type Engine struct {
+	provider       types.TaxProvider
+	inclusive      bool
+	defaultCountry string
+	defaultRegion  string
+}
+
+func NewEngine(provider types.TaxProvider) *Engine {
+	engine := &Engine{
+		provider:       provider,
+		inclusive:      PricesMode() == PricesInclusive,
+		defaultCountry: strings.ToUpper(os.Getenv("TAX_COUNTRY")),
+		defaultRegion:  strings.ToUpper(os.Getenv("TAX_REGION")),
+	}
+	if engine.defaultCountry == "" {
+		log.Println("TAX_COUNTRY is not set, carts without a destination are not taxed")
+	}
+	return engine
+}
+
+// Apply taxes the discounted lines of the cart and adds the tax to the total unless prices include it
+func (e *Engine) Apply(cart *types.Cart) error {
+	currency := cart.Subtotal.Currency
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+	for i := range cart.Items {
+		cart.Items[i].Tax = money.New(0, currency)
+		cart.Items[i].Taxes = nil
+	}
+	cart.Tax = money.New(0, currency)
+	cart.Taxes = nil
+	cart.TaxInclusive = e.inclusive
+
+	country, region := cart.Country, cart.Region
+	if country == "" {
+		country, region = e.defaultCountry, e.defaultRegion
+	}
+	if len(cart.Items) == 0 || country == "" {
+		return nil
+	}
+
+	request := types.TaxRequest{Country: country, Region: region, Currency: currency, Inclusive: e.inclusive}
+	for _, item := range cart.Items {
+		request.Lines = append(request.Lines, types.TaxLine{
+			TaxClass: item.TaxClass,
+			Amount:   item.Total.Amount - item.Discount.Amount,
+		})
+	}
+
+	result, err := e.provider.Calculate(request)
+	if err != nil {
+		return fmt.Errorf("%s tax: %w", e.provider.Name(), err)
+	}
+	if len(result.Lines) != len(cart.Items) {
+		return fmt.Errorf("%s tax: got %d lines for %d items", e.provider.Name(), len(result.Lines), len(cart.Items))
+	}
+
+	for i, line := range result.Lines {
+		cart.Items[i].Tax.Amount = line.Amount
+		cart.Items[i].Taxes = line.Taxes
+	}
+	cart.Tax.Amount = result.Total
+	cart.Taxes = result.Taxes
+	if !e.inclusive {
+		cart.Total.Amount += result.Total
+	}
+	return nil
+}
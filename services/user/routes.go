@@ -1,15 +1,19 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/yahyaammar-dev/pacebe/configs"
 	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/services/cart"
 	email "github.com/yahyaammar-dev/pacebe/services/emails"
 	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
 	"github.com/yahyaammar-dev/pacebe/types"
 	"github.com/yahyaammar-dev/pacebe/utils"
 )
@@ -27,6 +31,7 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/register", h.handleRegister).Methods("POST")
 	router.HandleFunc("/reset-password", h.handleResetPassword).Methods("POST")
 	router.HandleFunc("/create-password", h.handleCreatePassword).Methods("POST")
+	router.HandleFunc("/users", auth.WithRoles(h.handleGetUsers, h.store, "admin")).Methods("GET")
 }
 
 // @Summary Login
@@ -69,6 +74,17 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the guest cart is merged into the user's cart by its listener
+	login := types.UserLoggedInEvent{UserID: u.ID}
+	if cookie, err := r.Cookie(cart.CookieName); err == nil {
+		login.CartToken = cookie.Value
+		cart.ClearCookie(w)
+	}
+	event.Dispatch(types.Event{
+		Name:    "user.logged_in",
+		Payload: login,
+	})
+
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"token": token})
 }
 
@@ -218,3 +234,37 @@ func (h *Handler) handleCreatePassword(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Password Updated Successfully"})
 }
+
+// @Summary List users
+// @Description Lists users ordered by id using cursor pagination, admins only
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param size query int false "Page size" default(20)
+// @Success 200 {object} types.UserListResponse
+// @Failure 400 {object} map[string]string "Invalid cursor or size"
+// @Router /users [get]
+func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
+	size := 20
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("size must be between 1 and 100"))
+			return
+		}
+		size = n
+	}
+
+	users, page, err := h.store.GetUsers(r.URL.Query().Get("cursor"), size)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.UserListResponse{Data: users, Cursor: page})
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"fmt"
 
+	"github.com/yahyaammar-dev/pacebe/services/pagination"
 	"github.com/yahyaammar-dev/pacebe/types"
 	"gorm.io/gorm"
 )
@@ -37,7 +38,7 @@ func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 
 func (s *Store) GetUserByID(id int) (*types.User, error) {
 	var user types.User
-	result := s.db.First(&user, id)
+	result := s.db.Preload("Roles").First(&user, id)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("user not found")
@@ -77,3 +78,11 @@ func (s *Store) UpdatePasswordOfUser(user *types.User, password string) error {
 	}
 	return nil
 }
+
+func (s *Store) GetUsers(cursor string, limit int) ([]types.User, *types.CursorPage, error) {
+	keyset := pagination.Keyset{Column: "id", Limit: limit, Cursor: cursor}
+
+	return pagination.Paginate(s.db.Model(&types.User{}), keyset, func(u types.User) (any, uint) {
+		return u.ID, uint(u.ID)
+	})
+}
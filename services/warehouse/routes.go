@@ -0,0 +1,219 @@
+package warehouse
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store     types.WarehouseStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.WarehouseStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/warehouses", auth.WithRoles(h.handleGetWarehouses, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/warehouses/{id:[0-9]+}", auth.WithRoles(h.handleGetWarehouse, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/warehouses/{id:[0-9]+}/stock", auth.WithRoles(h.handleGetStockLevels, h.userStore, "admin", "operator")).Methods("GET")
+	router.HandleFunc("/warehouses", auth.WithRoles(h.handleCreateWarehouse, h.userStore, "admin")).Methods("POST")
+	router.HandleFunc("/warehouses/{id:[0-9]+}", auth.WithRoles(h.handleUpdateWarehouse, h.userStore, "admin")).Methods("PUT")
+}
+
+// @Summary List warehouses
+// @Description Lists all warehouses in allocation priority order
+// @Tags Warehouses
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.Warehouse
+// @Router /warehouses [get]
+func (h *Handler) handleGetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.store.GetWarehouses()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouses)
+}
+
+// @Summary Get warehouse
+// @Description Returns a single warehouse
+// @Tags Warehouses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Success 200 {object} types.Warehouse
+// @Failure 404 {object} map[string]string "Warehouse not found"
+// @Router /warehouses/{id} [get]
+func (h *Handler) handleGetWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	warehouse, err := h.store.GetWarehouseByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouse)
+}
+
+// @Summary Warehouse stock
+// @Description Lists the stock of every product and variant held in the warehouse
+// @Tags Warehouses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Success 200 {array} types.StockLevel
+// @Failure 404 {object} map[string]string "Warehouse not found"
+// @Router /warehouses/{id}/stock [get]
+func (h *Handler) handleGetStockLevels(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := h.store.GetWarehouseByID(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	levels, err := h.store.GetStockLevels(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, levels)
+}
+
+// @Summary Create warehouse
+// @Description Creates a stock location, lower priorities are allocated first
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param warehousePayload body types.WarehousePayload true "Warehouse payload"
+// @Success 201 {object} types.Warehouse
+// @Failure 400 {object} map[string]string "Invalid warehouse data"
+// @Router /warehouses [post]
+func (h *Handler) handleCreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var payload types.WarehousePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	warehouse := types.Warehouse{Active: true}
+	if err := h.applyPayload(&warehouse, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.CreateWarehouse(&warehouse); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, warehouse)
+}
+
+// @Summary Update warehouse
+// @Description Updates a warehouse, inactive warehouses keep their stock but are no longer allocated
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Param warehousePayload body types.WarehousePayload true "Warehouse payload"
+// @Success 200 {object} types.Warehouse
+// @Failure 400 {object} map[string]string "Invalid warehouse data"
+// @Failure 404 {object} map[string]string "Warehouse not found"
+// @Router /warehouses/{id} [put]
+func (h *Handler) handleUpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	warehouse, err := h.store.GetWarehouseByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var payload types.WarehousePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.applyPayload(warehouse, payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateWarehouse(warehouse); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouse)
+}
+
+func (h *Handler) applyPayload(warehouse *types.Warehouse, payload types.WarehousePayload) error {
+	code := strings.ToUpper(payload.Code)
+	if existing, err := h.store.GetWarehouseByCode(code); err == nil && existing.ID != warehouse.ID {
+		return fmt.Errorf("warehouse with code %s already exists", code)
+	}
+
+	warehouse.Name = payload.Name
+	warehouse.Code = code
+	warehouse.Address = payload.Address
+	warehouse.Country = strings.ToUpper(payload.Country)
+	warehouse.Region = payload.Region
+	warehouse.Latitude = payload.Latitude
+	warehouse.Longitude = payload.Longitude
+	warehouse.Priority = payload.Priority
+	if payload.Active != nil {
+		warehouse.Active = *payload.Active
+	}
+
+	return nil
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,81 @@
+package warehouse
+
+import (
+	"fmt"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetWarehouses() ([]types.Warehouse, error) {
+	var warehouses []types.Warehouse
+	result := s.db.Order("priority, id").Find(&warehouses)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return warehouses, nil
+}
+
+func (s *Store) GetWarehouseByID(id uint) (*types.Warehouse, error) {
+	var warehouse types.Warehouse
+	result := s.db.First(&warehouse, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("warehouse not found")
+		}
+		return nil, result.Error
+	}
+	return &warehouse, nil
+}
+
+func (s *Store) GetWarehouseByCode(code string) (*types.Warehouse, error) {
+	var warehouse types.Warehouse
+	result := s.db.Where("code = ?", code).First(&warehouse)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("warehouse not found")
+		}
+		return nil, result.Error
+	}
+	return &warehouse, nil
+}
+
+func (s *Store) CreateWarehouse(warehouse *types.Warehouse) error {
+	// gorm skips zero values that have a default, an inactive warehouse would come back active
+	result := s.db.Create(warehouse)
+	if result.Error != nil {
+		return result.Error
+	}
+	if !warehouse.Active {
+		return s.db.Model(warehouse).Update("active", false).Error
+	}
+	return nil
+}
+
+func (s *Store) UpdateWarehouse(warehouse *types.Warehouse) error {
+	result := s.db.Save(warehouse)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetStockLevels lists what the warehouse holds, variants come after their product
+func (s *Store) GetStockLevels(warehouseID uint) ([]types.StockLevel, error) {
+	var levels []types.StockLevel
+	result := s.db.Where("warehouse_id = ?", warehouseID).
+		Order("product_id, variant_id").
+		Find(&levels)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return levels, nil
+}
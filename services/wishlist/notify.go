@@ -0,0 +1,55 @@
+package wishlist
+
+import (
+	"fmt"
+	"log"
+
+	email "github.com/yahyaammar-dev/pacebe/services/emails"
+	"github.com/yahyaammar-dev/pacebe/services/event"
+	"github.com/yahyaammar-dev/pacebe/types"
+)
+
+// RegisterListeners mails the subscribers of a product when it comes back in stock. Every
+// subscription is claimed before its mail goes out, so each subscriber hears about it once.
+func (h *Handler) RegisterListeners() {
+	event.Register("inventory.restocked", func(e types.Event) {
+		restocked, ok := e.Payload.(types.RestockedEvent)
+		if !ok {
+			fmt.Println("Invalid payload")
+			return
+		}
+
+		subscriptions, err := h.store.GetWaitingSubscriptions(restocked.ProductID)
+		if err != nil {
+			log.Printf("failed to load the stock subscriptions of product %d: %v", restocked.ProductID, err)
+			return
+		}
+		if len(subscriptions) == 0 {
+			return
+		}
+
+		product, err := h.productStore.GetProductByID(restocked.ProductID)
+		if err != nil {
+			log.Printf("failed to load restocked product %d: %v", restocked.ProductID, err)
+			return
+		}
+
+		for _, subscription := range subscriptions {
+			claimed, err := h.store.MarkNotified(subscription.ID)
+			if err != nil {
+				log.Printf("failed to claim stock subscription %d: %v", subscription.ID, err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			user, err := h.userStore.GetUserByID(subscription.UserID)
+			if err != nil {
+				log.Printf("failed to mail stock subscription %d: %v", subscription.ID, err)
+				continue
+			}
+			email.SendBackInStock(user.Email, product.Name)
+		}
+	})
+}
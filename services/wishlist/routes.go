@@ -0,0 +1,214 @@
+package wishlist
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/yahyaammar-dev/pacebe/services/auth"
+	"github.com/yahyaammar-dev/pacebe/types"
+	"github.com/yahyaammar-dev/pacebe/utils"
+)
+
+type Handler struct {
+	store        types.WishlistStore
+	productStore types.ProductStore
+	userStore    types.UserStore
+}
+
+func NewHandler(store types.WishlistStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, productStore: productStore, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/wishlist", auth.WithJWTAuth(h.handleGetWishlist, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/wishlist", auth.WithJWTAuth(h.handleAddToWishlist, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/wishlist/{productId:[0-9]+}", auth.WithJWTAuth(h.handleRemoveFromWishlist, h.userStore)).Methods("DELETE")
+
+	router.HandleFunc("/me/stock-subscriptions", auth.WithJWTAuth(h.handleGetSubscriptions, h.userStore)).Methods("GET")
+	router.HandleFunc("/me/stock-subscriptions", auth.WithJWTAuth(h.handleSubscribe, h.userStore)).Methods("POST")
+	router.HandleFunc("/me/stock-subscriptions/{productId:[0-9]+}", auth.WithJWTAuth(h.handleUnsubscribe, h.userStore)).Methods("DELETE")
+}
+
+// @Summary My wishlist
+// @Description Lists the products the logged in user saved, last saved first
+// @Tags Wishlist
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.WishlistItem
+// @Router /me/wishlist [get]
+func (h *Handler) handleGetWishlist(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store.GetWishlist(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, items)
+}
+
+// @Summary Save product
+// @Description Adds a product to the wishlist of the logged in user, saving it again changes nothing
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param wishlistPayload body types.WishlistPayload true "Product"
+// @Success 201 {object} types.WishlistItem
+// @Failure 400 {object} map[string]string "Invalid payload"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /me/wishlist [post]
+func (h *Handler) handleAddToWishlist(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.productStore.GetProductByID(payload.ProductID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	item := &types.WishlistItem{UserID: auth.GetUserIDFromContext(r.Context()), ProductID: payload.ProductID}
+	if err := h.store.AddToWishlist(item); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, item)
+}
+
+// @Summary Remove saved product
+// @Description Takes a product off the wishlist of the logged in user
+// @Tags Wishlist
+// @Security BearerAuth
+// @Param productId path int true "Product ID"
+// @Success 204
+// @Failure 404 {object} map[string]string "Product is not on the wishlist"
+// @Router /me/wishlist/{productId} [delete]
+func (h *Handler) handleRemoveFromWishlist(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseProductID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.RemoveFromWishlist(auth.GetUserIDFromContext(r.Context()), productID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary My stock subscriptions
+// @Description Lists the out of stock products the logged in user waits for, notified ones included
+// @Tags Wishlist
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} types.StockSubscription
+// @Router /me/stock-subscriptions [get]
+func (h *Handler) handleGetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.store.GetStockSubscriptions(auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, subscriptions)
+}
+
+// @Summary Subscribe to restock
+// @Description Mails the logged in user once when an out of stock product is back in stock.
+// @Description Subscribing again after the mail waits for the next restock.
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param wishlistPayload body types.WishlistPayload true "Product"
+// @Success 201 {object} types.StockSubscription
+// @Failure 400 {object} map[string]string "Product is in stock"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /me/stock-subscriptions [post]
+func (h *Handler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(payload.ProductID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// the stock of products with variants is kept per variant, any of them in stock is the product in stock
+	stock := product.Stock
+	if len(product.Variants) > 0 {
+		stock = 0
+		for _, variant := range product.Variants {
+			stock += variant.Stock
+		}
+	}
+	if stock > 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("product is in stock"))
+		return
+	}
+
+	subscription := &types.StockSubscription{UserID: auth.GetUserIDFromContext(r.Context()), ProductID: product.ID}
+	if err := h.store.Subscribe(subscription); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, subscription)
+}
+
+// @Summary Unsubscribe from restock
+// @Description Stops waiting for the restock of a product
+// @Tags Wishlist
+// @Security BearerAuth
+// @Param productId path int true "Product ID"
+// @Success 204
+// @Failure 404 {object} map[string]string "Subscription not found"
+// @Router /me/stock-subscriptions/{productId} [delete]
+func (h *Handler) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	productID, err := parseProductID(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.Unsubscribe(auth.GetUserIDFromContext(r.Context()), productID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parsePayload reads the product of a wishlist or subscription request, false means the error was written
+func parsePayload(w http.ResponseWriter, r *http.Request) (types.WishlistPayload, bool) {
+	var payload types.WishlistPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return payload, false
+	}
+	return payload, true
+}
+
+func parseProductID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["productId"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid productId")
+	}
+	return uint(id), nil
+}
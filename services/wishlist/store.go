@@ -0,0 +1,98 @@
+package wishlist
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yahyaammar-dev/pacebe/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetWishlist lists the saved products of the user, last saved first
+func (s *Store) GetWishlist(userID int) ([]types.WishlistItem, error) {
+	var items []types.WishlistItem
+	result := s.db.Preload("Product").Where("user_id = ?", userID).Order("id DESC").Find(&items)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return items, nil
+}
+
+func (s *Store) AddToWishlist(item *types.WishlistItem) error {
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Product").Create(item)
+	if result.Error != nil {
+		return result.Error
+	}
+	return s.db.Preload("Product").Where("user_id = ? AND product_id = ?", item.UserID, item.ProductID).First(item).Error
+}
+
+func (s *Store) RemoveFromWishlist(userID int, productID uint) error {
+	result := s.db.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&types.WishlistItem{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("product is not on the wishlist")
+	}
+	return nil
+}
+
+func (s *Store) GetStockSubscriptions(userID int) ([]types.StockSubscription, error) {
+	var subscriptions []types.StockSubscription
+	result := s.db.Preload("Product").Where("user_id = ?", userID).Order("id DESC").Find(&subscriptions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return subscriptions, nil
+}
+
+func (s *Store) Subscribe(subscription *types.StockSubscription) error {
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]any{"notified_at": nil}),
+	}).Omit("Product").Create(subscription)
+	if result.Error != nil {
+		return result.Error
+	}
+	return s.db.Preload("Product").Where("user_id = ? AND product_id = ?", subscription.UserID, subscription.ProductID).First(subscription).Error
+}
+
+func (s *Store) Unsubscribe(userID int, productID uint) error {
+	result := s.db.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&types.StockSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+	return nil
+}
+
+func (s *Store) GetWaitingSubscriptions(productID uint) ([]types.StockSubscription, error) {
+	var subscriptions []types.StockSubscription
+	result := s.db.Where("product_id = ? AND notified_at IS NULL", productID).Order("id").Find(&subscriptions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return subscriptions, nil
+}
+
+// MarkNotified only sets the time on a waiting subscription, so two restocks close together mail once
+func (s *Store) MarkNotified(id uint) (bool, error) {
+	result := s.db.Model(&types.StockSubscription{}).
+		Where("id = ? AND notified_at IS NULL", id).
+		Update("notified_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
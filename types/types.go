@@ -1,6 +1,7 @@
 package types
 
 import (
+	"net/http"
 	"time"
 )
 
@@ -10,7 +11,7 @@ type User struct {
 	LastName      string  `json:"lastName"`
 	Email         string  `json:"email"`
 	Password      string  `json:"-"`
-	RememberToken *string `json:"rememberToken"`
+	RememberToken *string `json:"-"`
 	Roles         []Role  `json:"-" gorm:"many2many:user_roles;"`
 }
 
@@ -20,17 +21,1036 @@ type Role struct {
 }
 
 type Product struct {
+	ID                uint             `json:"id" gorm:"primaryKey"`
+	Name              string           `json:"name" gorm:"not null"`
+	Description       string           `json:"description"`
+	Price             Money            `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Prices            []ProductPrice   `json:"prices,omitempty"`
+	Stock             int              `json:"stock" gorm:"default:0"`
+	Reserved          int              `json:"reserved" gorm:"default:0"`
+	LowStockThreshold int              `json:"lowStockThreshold" gorm:"default:0"`
+	SKU               *string          `json:"sku" gorm:"uniqueIndex"`
+	CategoryID        *uint            `json:"categoryId" gorm:"index"`
+	Category          *Category        `json:"category,omitempty"`
+	TaxClass          string           `json:"taxClass" gorm:"not null;default:standard"`
+	Weight            int              `json:"weight" gorm:"default:0"`
+	Length            int              `json:"length" gorm:"default:0"`
+	Width             int              `json:"width" gorm:"default:0"`
+	Height            int              `json:"height" gorm:"default:0"`
+	ImageURL          string           `json:"imageUrl"`
+	Options           []ProductOption  `json:"options,omitempty"`
+	Variants          []ProductVariant `json:"variants,omitempty"`
+	StockLevels       []StockLevel     `json:"stockLevels,omitempty"`
+	Images            []ProductImage   `json:"images,omitempty"`
+	Rating            ProductRating    `json:"rating" gorm:"embedded;embeddedPrefix:rating_"`
+	CreatedAt         time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time        `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ProductRating sums up the approved reviews of a product, it only moves through review moderation
+type ProductRating struct {
+	Average      float64            `json:"average" gorm:"default:0"`
+	Count        int                `json:"count" gorm:"default:0"`
+	Stars        int                `json:"-" gorm:"default:0"`
+	Distribution RatingDistribution `json:"distribution" gorm:"embedded"`
+}
+
+// RatingDistribution counts the approved reviews per rating
+type RatingDistribution struct {
+	One   int `json:"1" gorm:"default:0"`
+	Two   int `json:"2" gorm:"default:0"`
+	Three int `json:"3" gorm:"default:0"`
+	Four  int `json:"4" gorm:"default:0"`
+	Five  int `json:"5" gorm:"default:0"`
+}
+
+type ProductOption struct {
+	ID        uint                 `json:"id" gorm:"primaryKey"`
+	ProductID uint                 `json:"productId" gorm:"index;not null"`
+	Name      string               `json:"name" gorm:"not null"`
+	Position  int                  `json:"position" gorm:"default:0"`
+	Values    []ProductOptionValue `json:"values" gorm:"foreignKey:OptionID"`
+}
+
+type ProductOptionValue struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	OptionID uint   `json:"optionId" gorm:"index;not null"`
+	Value    string `json:"value" gorm:"not null"`
+	Position int    `json:"position" gorm:"default:0"`
+}
+
+// ProductVariant is one sellable combination of option values, a nil PriceOverride sells at the product price.
+// The override is in minor units of the product currency.
+type ProductVariant struct {
+	ID            uint                 `json:"id" gorm:"primaryKey"`
+	ProductID     uint                 `json:"productId" gorm:"index;not null"`
+	SKU           string               `json:"sku" gorm:"uniqueIndex;not null"`
+	Barcode       string               `json:"barcode"`
+	PriceOverride *int64               `json:"priceOverride"`
+	Stock         int                  `json:"stock" gorm:"default:0"`
+	Reserved      int                  `json:"reserved" gorm:"default:0"`
+	OptionValues  []ProductOptionValue `json:"optionValues" gorm:"many2many:product_variant_option_values;"`
+	StockLevels   []StockLevel         `json:"stockLevels,omitempty" gorm:"foreignKey:VariantID"`
+	CreatedAt     time.Time            `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time            `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ProductImage is an uploaded image of a product, the image at position 0 is also the product's ImageURL
+type ProductImage struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	ProductID   uint             `json:"productId" gorm:"index;not null"`
+	Key         string           `json:"-" gorm:"not null"`
+	URL         string           `json:"url" gorm:"not null"`
+	ContentType string           `json:"contentType"`
+	Size        int64            `json:"size"`
+	Width       int              `json:"width"`
+	Height      int              `json:"height"`
+	Alt         string           `json:"alt"`
+	Position    int              `json:"position" gorm:"default:0"`
+	Thumbnails  []ImageThumbnail `json:"thumbnails" gorm:"serializer:json"`
+	CreatedAt   time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+type ImageThumbnail struct {
+	Name   string `json:"name"`
+	Key    string `json:"-"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Money is an amount in minor units of its currency, e.g. cents for USD
+type Money struct {
+	Amount   int64  `json:"amount" gorm:"not null;default:0"`
+	Currency string `json:"currency" gorm:"size:3"`
+}
+
+// ProductPrice is a fixed price of a product in another currency, it wins over converting the base price
+type ProductPrice struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ProductID uint   `json:"productId" gorm:"uniqueIndex:idx_product_prices_currency;not null"`
+	Currency  string `json:"currency" gorm:"uniqueIndex:idx_product_prices_currency;size:3;not null"`
+	Amount    int64  `json:"amount" gorm:"not null"`
+}
+
+// ExchangeRate says one unit of Base is worth Rate units of Quote, the rate is kept as a decimal string
+type ExchangeRate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Base      string    `json:"base" gorm:"uniqueIndex:idx_exchange_rates_pair;size:3;not null"`
+	Quote     string    `json:"quote" gorm:"uniqueIndex:idx_exchange_rates_pair;size:3;not null"`
+	Rate      string    `json:"rate" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ShippingZone groups the countries that share shipping methods. A zone without countries covers
+// every country no other zone lists.
+type ShippingZone struct {
+	ID        uint             `json:"id" gorm:"primaryKey"`
+	Name      string           `json:"name" gorm:"not null"`
+	Countries []string         `json:"countries" gorm:"serializer:json"`
+	Methods   []ShippingMethod `json:"methods,omitempty" gorm:"foreignKey:ZoneID"`
+	CreatedAt time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time        `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ShippingMethod is a way to deliver into a zone. Flat methods cost Price, weight and price methods look
+// the cart weight in grams or the discounted cart value up in Rates. With a VolumetricDivisor weight
+// methods charge bulky products by their volume in cubic millimetres divided by it when that's more.
+type ShippingMethod struct {
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	ZoneID            uint           `json:"zoneId" gorm:"index;not null"`
+	Name              string         `json:"name" gorm:"not null"`
+	Carrier           string         `json:"carrier" gorm:"not null"`
+	RateType          string         `json:"rateType" gorm:"not null"`
+	Price             int64          `json:"price"`
+	Rates             []ShippingRate `json:"rates" gorm:"serializer:json"`
+	VolumetricDivisor int            `json:"volumetricDivisor"`
+	Position          int            `json:"position" gorm:"default:0"`
+	Active            bool           `json:"active"`
+	CreatedAt         time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ShippingRate is a row of a rate table, it applies from Min up to but not including Max, a zero Max has no limit
+type ShippingRate struct {
+	Min   int64 `json:"min" validate:"min=0"`
+	Max   int64 `json:"max" validate:"min=0"`
+	Price int64 `json:"price" validate:"min=0"`
+}
+
+// ShippingQuote is what a shipping method costs for a cart
+type ShippingQuote struct {
+	MethodID uint   `json:"methodId"`
+	Name     string `json:"name"`
+	Carrier  string `json:"carrier"`
+	Zone     string `json:"zone"`
+	Price    Money  `json:"price"`
+}
+
+// Shipment is the delivery of an order with the method chosen at checkout
+type Shipment struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	OrderID        uint             `json:"orderId" gorm:"index;not null"`
+	MethodID       *uint            `json:"methodId"`
+	Method         string           `json:"method"`
+	Carrier        string           `json:"carrier" gorm:"not null"`
+	Price          Money            `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Status         string           `json:"status" gorm:"index;not null"`
+	TrackingNumber string           `json:"trackingNumber"`
+	LabelURL       string           `json:"labelUrl"`
+	Updates        []ShipmentUpdate `json:"updates,omitempty"`
+	ShippedAt      *time.Time       `json:"shippedAt"`
+	DeliveredAt    *time.Time       `json:"deliveredAt"`
+	CreatedAt      time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time        `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ShipmentUpdate is a step in the tracking history of a shipment
+type ShipmentUpdate struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ShipmentID uint      `json:"shipmentId" gorm:"index;not null"`
+	Status     string    `json:"status" gorm:"not null"`
+	Message    string    `json:"message"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// CarrierLabel is what a carrier hands back for a new shipment
+type CarrierLabel struct {
+	TrackingNumber string
+	LabelURL       string
+}
+
+// ShipmentEvent is the payload of the shipment.* events
+type ShipmentEvent struct {
+	Shipment Shipment
+	From     string
+}
+
+// TaxRate is one component of the tax of a tax class in a country or, with a Region, in a part of it.
+// The rates of the country and of the region add up. Rate is a percentage such as "19" or "7.25".
+type TaxRate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Country   string    `json:"country" gorm:"uniqueIndex:idx_tax_rates_rate;size:2;not null"`
+	Region    string    `json:"region" gorm:"uniqueIndex:idx_tax_rates_rate;not null;default:''"`
+	TaxClass  string    `json:"taxClass" gorm:"uniqueIndex:idx_tax_rates_rate;not null"`
+	Name      string    `json:"name" gorm:"uniqueIndex:idx_tax_rates_rate;not null"`
+	Rate      string    `json:"rate" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TaxBreakdown is the part of a tax that one rate makes up
+type TaxBreakdown struct {
+	Name   string `json:"name"`
+	Rate   string `json:"rate"`
+	Amount int64  `json:"amount"`
+}
+
+// TaxRequest asks a tax provider for the tax of lines shipped to a destination. Amounts are
+// in minor units and already discounted, with Inclusive they contain the tax.
+type TaxRequest struct {
+	Country   string
+	Region    string
+	Currency  string
+	Inclusive bool
+	Lines     []TaxLine
+}
+
+type TaxLine struct {
+	TaxClass string
+	Amount   int64
+}
+
+// TaxResult holds the tax of every requested line, in the same order, and the totals per rate
+type TaxResult struct {
+	Lines []TaxLineResult
+	Taxes []TaxBreakdown
+	Total int64
+}
+
+type TaxLineResult struct {
+	Amount int64
+	Taxes  []TaxBreakdown
+}
+
+// Warehouse is a location that holds stock. Lower priorities are allocated first,
+// the coordinates are used to find the warehouse nearest to a destination.
+type Warehouse struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Code      string    `json:"code" gorm:"uniqueIndex;not null"`
+	Address   string    `json:"address"`
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	Latitude  *float64  `json:"latitude"`
+	Longitude *float64  `json:"longitude"`
+	Priority  int       `json:"priority" gorm:"default:0"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// StockLevel is the stock of a product or variant in one warehouse. The Stock and Reserved
+// counters on products and variants are the sums over all warehouses.
+type StockLevel struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	WarehouseID uint       `json:"warehouseId" gorm:"index;not null"`
+	Warehouse   *Warehouse `json:"warehouse,omitempty"`
+	ProductID   uint       `json:"productId" gorm:"index;not null"`
+	VariantID   *uint      `json:"variantId" gorm:"index"`
+	Stock       int        `json:"stock" gorm:"default:0"`
+	Reserved    int        `json:"reserved" gorm:"default:0"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// GeoPoint is a position in decimal degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Destination is where reserved stock ships to. Point is used when it and the warehouse position are
+// known, otherwise warehouses in the same region and then the same country are nearest.
+type Destination struct {
+	Country string
+	Region  string
+	Point   *GeoPoint
+}
+
+// StockMovement is one entry of the inventory ledger. Quantity is the number of units moved, only
+// adjustments carry a sign. Available and Reserved are the balances of the warehouse right after the movement.
+type StockMovement struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	WarehouseID   uint      `json:"warehouseId" gorm:"index"`
+	ProductID     uint      `json:"productId" gorm:"index;not null"`
+	VariantID     *uint     `json:"variantId" gorm:"index"`
+	Type          string    `json:"type" gorm:"not null"`
+	Quantity      int       `json:"quantity" gorm:"not null"`
+	Available     int       `json:"available"`
+	Reserved      int       `json:"reserved"`
+	ReservationID *uint     `json:"reservationId" gorm:"index"`
+	Reference     string    `json:"reference"`
+	Note          string    `json:"note"`
+	CreatedBy     *int      `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// StockReservation holds stock for a cart or order until it ships, is released or expires
+type StockReservation struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	WarehouseID uint       `json:"warehouseId" gorm:"index"`
+	ProductID   uint       `json:"productId" gorm:"index;not null"`
+	VariantID   *uint      `json:"variantId" gorm:"index"`
+	Quantity    int        `json:"quantity" gorm:"not null"`
+	Status      string     `json:"status" gorm:"index;not null"`
+	Reference   string     `json:"reference" gorm:"index"`
+	ExpiresAt   *time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ReservationRequest asks for stock of a product or variant. Without a WarehouseID the warehouse
+// is allocated, Destination is only needed by the nearest rule. A zero TTL never expires.
+type ReservationRequest struct {
+	ProductID   uint
+	VariantID   *uint
+	Quantity    int
+	Reference   string
+	TTL         time.Duration
+	WarehouseID *uint
+	Destination *Destination
+}
+
+type LowStockEvent struct {
+	ProductID uint
+	VariantID *uint
+	Available int
+	Threshold int
+}
+
+// RestockedEvent is the payload of inventory.restocked, a product ran out of stock and has some again. Available
+// of a product with variants is the stock of all of them.
+type RestockedEvent struct {
+	ProductID uint
+	Available int
+}
+
+// WishlistItem is a product a customer saved for later
+type WishlistItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    int       `json:"userId" gorm:"uniqueIndex:idx_wishlist_items_user_product;not null"`
+	ProductID uint      `json:"productId" gorm:"uniqueIndex:idx_wishlist_items_user_product;not null"`
+	Product   *Product  `json:"product,omitempty"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// StockSubscription asks for a mail when an out of stock product is back, NotifiedAt is set once it was sent
+type StockSubscription struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     int        `json:"userId" gorm:"uniqueIndex:idx_stock_subscriptions_user_product;not null"`
+	ProductID  uint       `json:"productId" gorm:"uniqueIndex:idx_stock_subscriptions_user_product;index;not null"`
+	Product    *Product   `json:"product,omitempty"`
+	NotifiedAt *time.Time `json:"notifiedAt"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ProfessionalProfile is the public page of a user with the professional role, it is listed once an
+// admin approves it and goes back to review whenever its owner edits it
+type ProfessionalProfile struct {
+	ID          uint                  `json:"id" gorm:"primaryKey"`
+	UserID      int                   `json:"userId" gorm:"uniqueIndex;not null"`
+	DisplayName string                `json:"displayName" gorm:"not null"`
+	Headline    string                `json:"headline"`
+	Bio         string                `json:"bio"`
+	Skills      []string              `json:"skills" gorm:"serializer:json"`
+	ServiceArea ServiceArea           `json:"serviceArea" gorm:"embedded;embeddedPrefix:area_"`
+	Status      string                `json:"status" gorm:"index;not null"`
+	ReviewNote  string                `json:"reviewNote,omitempty"`
+	ReviewedBy  *int                  `json:"reviewedBy,omitempty"`
+	ReviewedAt  *time.Time            `json:"reviewedAt,omitempty"`
+	Services    []ProfessionalService `json:"services" gorm:"foreignKey:ProfileID"`
+	CreatedAt   time.Time             `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time             `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ServiceArea is where a professional works, Radius in km around City. Remote professionals serve anywhere.
+type ServiceArea struct {
+	Country string `json:"country" gorm:"size:2;index"`
+	City    string `json:"city" gorm:"index"`
+	Radius  int    `json:"radius" gorm:"default:0"`
+	Remote  bool   `json:"remote" gorm:"default:false"`
+}
+
+// ProfessionalService is a service listed on a profile, Duration is in minutes
+type ProfessionalService struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
+	ProfileID   uint      `json:"profileId" gorm:"index;not null"`
 	Name        string    `json:"name" gorm:"not null"`
 	Description string    `json:"description"`
-	Price       float64   `json:"price" gorm:"not null"`
-	Stock       int       `json:"stock" gorm:"default:0"`
-	Category    string    `json:"category"`
-	ImageURL    string    `json:"imageUrl"`
+	Duration    int       `json:"duration" gorm:"not null"`
+	Price       Money     `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Active      bool      `json:"active" gorm:"default:false"`
 	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
 }
 
+// ProfessionalEvent is the payload of the professional.<status> events
+type ProfessionalEvent struct {
+	Profile ProfessionalProfile
+	From    string
+}
+
+// BookingCalendar holds when and how a professional can be booked. Rules and exceptions are wall clock
+// times in TimeZone, Horizon is in days and every other setting in minutes.
+type BookingCalendar struct {
+	ID               uint                    `json:"id" gorm:"primaryKey"`
+	ProfileID        uint                    `json:"profileId" gorm:"uniqueIndex;not null"`
+	TimeZone         string                  `json:"timeZone" gorm:"not null"`
+	SlotInterval     int                     `json:"slotInterval" gorm:"not null"`
+	Buffer           int                     `json:"buffer" gorm:"not null"`
+	MinNotice        int                     `json:"minNotice" gorm:"not null"`
+	Horizon          int                     `json:"horizon" gorm:"not null"`
+	CancelNotice     int                     `json:"cancelNotice" gorm:"not null"`
+	RescheduleNotice int                     `json:"rescheduleNotice" gorm:"not null"`
+	MaxReschedules   int                     `json:"maxReschedules" gorm:"not null"`
+	FeedKey          string                  `json:"feedKey" gorm:"not null"`
+	Rules            []AvailabilityRule      `json:"rules" gorm:"foreignKey:CalendarID"`
+	Exceptions       []AvailabilityException `json:"exceptions" gorm:"foreignKey:CalendarID"`
+	CreatedAt        time.Time               `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time               `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// AvailabilityRule opens a weekday from StartTime to EndTime, as HH:MM with 24:00 for midnight.
+// Weekday 0 is Sunday.
+type AvailabilityRule struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	CalendarID uint   `json:"calendarId" gorm:"index;not null"`
+	Weekday    int    `json:"weekday" gorm:"not null"`
+	StartTime  string `json:"startTime" gorm:"size:5;not null"`
+	EndTime    string `json:"endTime" gorm:"size:5;not null"`
+}
+
+// AvailabilityException changes the days from StartDate to EndDate. Available exceptions replace the
+// weekly rules of those days with their hours, the others close their hours or, without any, the whole day.
+type AvailabilityException struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CalendarID uint      `json:"calendarId" gorm:"index;not null"`
+	StartDate  string    `json:"startDate" gorm:"size:10;index;not null"`
+	EndDate    string    `json:"endDate" gorm:"size:10;index;not null"`
+	Available  bool      `json:"available"`
+	StartTime  string    `json:"startTime,omitempty" gorm:"size:5"`
+	EndTime    string    `json:"endTime,omitempty" gorm:"size:5"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// Booking is an appointment of a customer with a professional. Service, duration and price are copied
+// from the service when booking.
+type Booking struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	ProfileID          uint       `json:"profileId" gorm:"index;not null"`
+	ServiceID          uint       `json:"serviceId" gorm:"not null"`
+	UserID             int        `json:"userId" gorm:"index;not null"`
+	CustomerName       string     `json:"customerName"`
+	ServiceName        string     `json:"serviceName" gorm:"not null"`
+	Duration           int        `json:"duration" gorm:"not null"`
+	Price              Money      `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	StartsAt           time.Time  `json:"startsAt" gorm:"index;not null"`
+	EndsAt             time.Time  `json:"endsAt" gorm:"index;not null"`
+	TimeZone           string     `json:"timeZone" gorm:"not null"`
+	Status             string     `json:"status" gorm:"index;not null"`
+	Note               string     `json:"note,omitempty"`
+	Reschedules        int        `json:"reschedules" gorm:"not null;default:0"`
+	CancelledBy        *int       `json:"cancelledBy,omitempty"`
+	CancelledAt        *time.Time `json:"cancelledAt,omitempty"`
+	CancellationReason string     `json:"cancellationReason,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// BookingEvent is the payload of the booking.confirmed, booking.rescheduled and booking.cancelled
+// events, PreviousStartsAt is set for reschedules
+type BookingEvent struct {
+	Booking          Booking
+	PreviousStartsAt *time.Time
+}
+
+// Slot is a start time a service can be booked at
+type Slot struct {
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+}
+
+// PriceChange is one entry of the price history of a product, written whenever its base price changes
+type PriceChange struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	ProductID        uint      `json:"productId" gorm:"index;not null"`
+	Price            Money     `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Previous         Money     `json:"previous" gorm:"embedded;embeddedPrefix:previous_"`
+	Source           string    `json:"source" gorm:"not null"`
+	ScheduledPriceID *uint     `json:"scheduledPriceId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ScheduledPrice sets the base price of a product from StartsAt. With an EndsAt the price before it is
+// put back then, unless the price was changed again meanwhile; without one the change stays.
+type ScheduledPrice struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	ProductID uint       `json:"productId" gorm:"index;not null"`
+	Price     Money      `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	StartsAt  time.Time  `json:"startsAt" gorm:"index;not null"`
+	EndsAt    *time.Time `json:"endsAt" gorm:"index"`
+	Status    string     `json:"status" gorm:"index;not null"`
+	Previous  Money      `json:"previous" gorm:"embedded;embeddedPrefix:previous_"`
+	Note      string     `json:"note,omitempty"`
+	CreatedBy *int       `json:"createdBy,omitempty"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ImportJob tracks a bulk product import, dry runs validate every row without saving anything
+type ImportJob struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	Status        string           `json:"status" gorm:"index;not null"`
+	DryRun        bool             `json:"dryRun"`
+	FileName      string           `json:"fileName"`
+	Format        string           `json:"format"`
+	TotalRows     int              `json:"totalRows"`
+	ProcessedRows int              `json:"processedRows"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	Failed        int              `json:"failed"`
+	Errors        []ImportRowError `json:"errors" gorm:"serializer:json"`
+	Error         string           `json:"error,omitempty"`
+	CreatedBy     *int             `json:"createdBy"`
+	CreatedAt     time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time        `json:"updatedAt" gorm:"autoUpdateTime"`
+	FinishedAt    *time.Time       `json:"finishedAt"`
+}
+
+// ImportRowError is one problem of an imported row, Row counts the header as row 1 like spreadsheets do
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ExportJob is a product export written to blob storage in the background. DownloadURL is
+// only filled in for finished jobs and expires, ask for the job again to get a fresh one.
+type ExportJob struct {
+	ID            uint          `json:"id" gorm:"primaryKey"`
+	Status        string        `json:"status" gorm:"index;not null"`
+	Format        string        `json:"format" gorm:"not null"`
+	Columns       []string      `json:"columns" gorm:"serializer:json"`
+	Filter        ProductFilter `json:"filter" gorm:"serializer:json"`
+	Currency      string        `json:"currency"`
+	TotalRows     int           `json:"totalRows"`
+	ProcessedRows int           `json:"processedRows"`
+	Key           string        `json:"-"`
+	Size          int64         `json:"size"`
+	Error         string        `json:"error,omitempty"`
+	DownloadURL   string        `json:"downloadUrl,omitempty" gorm:"-"`
+	CreatedBy     *int          `json:"createdBy"`
+	CreatedAt     time.Time     `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time     `json:"updatedAt" gorm:"autoUpdateTime"`
+	FinishedAt    *time.Time    `json:"finishedAt"`
+}
+
+// ExportFinishedEvent tells the user who asked for an export that it is done or failed
+type ExportFinishedEvent struct {
+	JobID       uint
+	Status      string
+	Email       string
+	DownloadURL string
+	Error       string
+}
+
+// Address is an entry of a user's address book. A user has at most one default shipping and one
+// default billing address, checkout falls back to them.
+type Address struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	UserID          int       `json:"userId" gorm:"index;not null"`
+	Name            string    `json:"name" gorm:"not null"`
+	Company         string    `json:"company"`
+	Line1           string    `json:"line1" gorm:"not null"`
+	Line2           string    `json:"line2"`
+	City            string    `json:"city" gorm:"not null"`
+	Region          string    `json:"region"`
+	PostalCode      string    `json:"postalCode"`
+	Country         string    `json:"country" gorm:"size:2;not null"`
+	Phone           string    `json:"phone"`
+	DefaultShipping bool      `json:"defaultShipping"`
+	DefaultBilling  bool      `json:"defaultBilling"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// OrderAddress is the copy of an address an order keeps, edits in the address book don't reach it
+type OrderAddress struct {
+	Name       string `json:"name"`
+	Company    string `json:"company"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone"`
+}
+
+// Cart belongs to a user or, for guests, to the token in the cart cookie.
+// Prices, discounts and totals are recalculated whenever the cart is read or changed.
+type Cart struct {
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	UserID           *int              `json:"userId" gorm:"uniqueIndex"`
+	Token            *string           `json:"-" gorm:"uniqueIndex"`
+	Items            []CartItem        `json:"items"`
+	ItemCount        int               `json:"itemCount"`
+	CouponCode       *string           `json:"couponCode"`
+	Country          string            `json:"country"`
+	Region           string            `json:"region"`
+	Subtotal         Money             `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount         Money             `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Discounts        []AppliedDiscount `json:"discounts" gorm:"serializer:json"`
+	FreeShipping     bool              `json:"freeShipping"`
+	Tax              Money             `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Taxes            []TaxBreakdown    `json:"taxes" gorm:"serializer:json"`
+	TaxInclusive     bool              `json:"taxInclusive"`
+	ShippingMethodID *uint             `json:"shippingMethodId"`
+	Shipping         Money             `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"`
+	Weight           int               `json:"weight" gorm:"-"`
+	Total            Money             `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	CreatedAt        time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// CartItem is a quantity of a product or one of its variants, Available is the stock that can still be sold.
+// Total is before Discount, Discounts explains which promotions make up the Discount and Taxes which rates
+// make up the Tax.
+type CartItem struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	CartID     uint              `json:"cartId" gorm:"index;not null"`
+	ProductID  uint              `json:"productId" gorm:"index;not null"`
+	VariantID  *uint             `json:"variantId" gorm:"index"`
+	CategoryID *uint             `json:"categoryId" gorm:"-"`
+	Name       string            `json:"name"`
+	SKU        string            `json:"sku"`
+	ImageURL   string            `json:"imageUrl"`
+	Quantity   int               `json:"quantity" gorm:"not null"`
+	UnitPrice  Money             `json:"unitPrice" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Total      Money             `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	Discount   Money             `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Discounts  []AppliedDiscount `json:"discounts" gorm:"serializer:json"`
+	TaxClass   string            `json:"taxClass" gorm:"-"`
+	Weight     int               `json:"weight" gorm:"-"`
+	Volume     int64             `json:"-" gorm:"-"`
+	Tax        Money             `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Taxes      []TaxBreakdown    `json:"taxes" gorm:"serializer:json"`
+	Available  int               `json:"available" gorm:"-"`
+	CreatedAt  time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// Promotion is a discount rule. Promotions without a Code apply by themselves, coupons only once their
+// code is on the cart. ProductIDs and CategoryIDs narrow the products a promotion discounts, categories
+// include their subcategories. Amounts are in minor units of the base currency.
+type Promotion struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	Name             string     `json:"name" gorm:"not null"`
+	Code             *string    `json:"code" gorm:"uniqueIndex"`
+	Type             string     `json:"type" gorm:"not null"`
+	Percent          int        `json:"percent"`
+	Amount           int64      `json:"amount"`
+	BuyQuantity      int        `json:"buyQuantity"`
+	GetQuantity      int        `json:"getQuantity"`
+	ProductIDs       []uint     `json:"productIds" gorm:"serializer:json"`
+	CategoryIDs      []uint     `json:"categoryIds" gorm:"serializer:json"`
+	MinSubtotal      int64      `json:"minSubtotal"`
+	StartsAt         *time.Time `json:"startsAt"`
+	EndsAt           *time.Time `json:"endsAt"`
+	UsageLimit       *int       `json:"usageLimit"`
+	PerCustomerLimit *int       `json:"perCustomerLimit"`
+	UsageCount       int        `json:"usageCount" gorm:"default:0"`
+	Priority         int        `json:"priority" gorm:"default:0"`
+	Active           bool       `json:"active"`
+	CreatedAt        time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// PromotionRedemption is the use of a promotion by an order, it counts towards the usage limits
+type PromotionRedemption struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PromotionID uint      `json:"promotionId" gorm:"index;not null"`
+	OrderID     uint      `json:"orderId" gorm:"index;not null"`
+	UserID      int       `json:"userId" gorm:"index;not null"`
+	Amount      int64     `json:"amount"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// AppliedDiscount explains how much a promotion took off a line or, summed up, off the whole cart
+type AppliedDiscount struct {
+	PromotionID uint   `json:"promotionId"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Description string `json:"description"`
+	Amount      int64  `json:"amount"`
+}
+
+// UserLoggedInEvent is dispatched after a login, CartToken is the guest cart the user had before
+type UserLoggedInEvent struct {
+	UserID    int
+	CartToken string
+}
+
+// Order is a checked out cart. Items and prices are copied at checkout and don't follow later product changes.
+type Order struct {
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	UserID           int               `json:"userId" gorm:"index;not null"`
+	Status           string            `json:"status" gorm:"index;not null"`
+	Items            []OrderItem       `json:"items,omitempty"`
+	ItemCount        int               `json:"itemCount"`
+	CouponCode       string            `json:"couponCode"`
+	Subtotal         Money             `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount         Money             `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Discounts        []AppliedDiscount `json:"discounts" gorm:"serializer:json"`
+	FreeShipping     bool              `json:"freeShipping"`
+	Tax              Money             `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Taxes            []TaxBreakdown    `json:"taxes" gorm:"serializer:json"`
+	TaxInclusive     bool              `json:"taxInclusive"`
+	ShippingMethodID *uint             `json:"shippingMethodId"`
+	Shipping         Money             `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"`
+	Total            Money             `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	Note             string            `json:"note"`
+	ShippingAddress  *OrderAddress     `json:"shippingAddress" gorm:"serializer:json"`
+	BillingAddress   *OrderAddress     `json:"billingAddress" gorm:"serializer:json"`
+	Shipments        []Shipment        `json:"shipments,omitempty"`
+	Transitions      []OrderTransition `json:"transitions,omitempty"`
+	CreatedAt        time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// OrderItem is a product or variant of an order, its stock is held by the reservation until it ships
+type OrderItem struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	OrderID       uint              `json:"orderId" gorm:"index;not null"`
+	ProductID     uint              `json:"productId" gorm:"index;not null"`
+	VariantID     *uint             `json:"variantId"`
+	Name          string            `json:"name"`
+	SKU           string            `json:"sku"`
+	ImageURL      string            `json:"imageUrl"`
+	Quantity      int               `json:"quantity" gorm:"not null"`
+	UnitPrice     Money             `json:"unitPrice" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Total         Money             `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	Discount      Money             `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Discounts     []AppliedDiscount `json:"discounts" gorm:"serializer:json"`
+	TaxClass      string            `json:"taxClass"`
+	Tax           Money             `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Taxes         []TaxBreakdown    `json:"taxes" gorm:"serializer:json"`
+	ReservationID *uint             `json:"reservationId"`
+}
+
+// OrderTransition is one step in the status history of an order, the first one has no From status
+type OrderTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"orderId" gorm:"index;not null"`
+	From      string    `json:"from"`
+	To        string    `json:"to" gorm:"not null"`
+	Note      string    `json:"note"`
+	UserID    *int      `json:"userId"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// OrderEvent is the payload of the order.<status> events, Order is the order after the transition
+type OrderEvent struct {
+	Order Order
+	From  string
+	To    string
+}
+
+// Payment is one attempt to pay an order through a payment provider, ProviderID is the provider's intent
+type Payment struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrderID        uint      `json:"orderId" gorm:"index;not null"`
+	Provider       string    `json:"provider" gorm:"uniqueIndex:idx_payments_provider_id;not null"`
+	ProviderID     string    `json:"providerId" gorm:"uniqueIndex:idx_payments_provider_id;not null"`
+	Status         string    `json:"status" gorm:"index;not null"`
+	Amount         Money     `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	RefundedAmount int64     `json:"refundedAmount"`
+	FailureMessage string    `json:"failureMessage"`
+	ClientSecret   string    `json:"clientSecret,omitempty" gorm:"-"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// WebhookEvent is a provider event that was handled, redeliveries of the same event are skipped
+type WebhookEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_webhook_events_event;not null"`
+	EventID   string    `json:"eventId" gorm:"uniqueIndex:idx_webhook_events_event;not null"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// PaymentIntent is the provider's side of a payment, the client confirms it with the ClientSecret
+type PaymentIntent struct {
+	ID           string
+	Status       string
+	Amount       Money
+	ClientSecret string
+}
+
+type PaymentRefund struct {
+	ID     string
+	Status string
+	Amount Money
+}
+
+// Refund is money given back on a payment under an idempotency key, e.g. for a return. A retry
+// with the same key finds it and leaves the payment alone.
+type Refund struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	PaymentID  uint      `json:"paymentId" gorm:"uniqueIndex:idx_refunds_key;not null"`
+	Key        string    `json:"key" gorm:"uniqueIndex:idx_refunds_key;not null"`
+	ProviderID string    `json:"providerId"`
+	Amount     Money     `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// PaymentEvent is a verified webhook event. Type is one of the payment event types of the payment
+// service, events that don't concern payments have an empty Type.
+type PaymentEvent struct {
+	ID       string
+	Type     string
+	IntentID string
+	Amount   int64
+	Message  string
+}
+
+// PaymentRefundedEvent is money that went back to the customer, Amount is this refund only
+type PaymentRefundedEvent struct {
+	Payment Payment
+	Amount  Money
+}
+
+// Invoice is an issued invoice or credit note. Number, amounts and PDF never change once issued,
+// credit notes with negative amounts correct invoices.
+type Invoice struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Number    string    `json:"number" gorm:"uniqueIndex;not null"`
+	Type      string    `json:"type" gorm:"index;not null"`
+	Year      int       `json:"year" gorm:"not null"`
+	Sequence  int       `json:"sequence" gorm:"not null"`
+	OrderID   uint      `json:"orderId" gorm:"index;not null"`
+	UserID    int       `json:"userId" gorm:"index;not null"`
+	InvoiceID *uint     `json:"invoiceId"`
+	Tax       Money     `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Total     Money     `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	Key       string    `json:"-"`
+	Size      int64     `json:"size"`
+	IssuedAt  time.Time `json:"issuedAt"`
+}
+
+// InvoiceSequence is the last number handed out for a document type in a year
+type InvoiceSequence struct {
+	Type   string `gorm:"primaryKey"`
+	Year   int    `gorm:"primaryKey;autoIncrement:false"`
+	Number int    `gorm:"not null"`
+}
+
+type InvoiceFilter struct {
+	Type string
+	Year int
+}
+
+// Return asks to send items of a delivered order back. Refund is what the returned lines were paid,
+// Refunded what actually went back to the customer.
+type Return struct {
+	ID          uint               `json:"id" gorm:"primaryKey"`
+	OrderID     uint               `json:"orderId" gorm:"index;not null"`
+	UserID      int                `json:"userId" gorm:"index;not null"`
+	Status      string             `json:"status" gorm:"index;not null"`
+	Note        string             `json:"note"`
+	Items       []ReturnItem       `json:"items,omitempty"`
+	Refund      Money              `json:"refund" gorm:"embedded;embeddedPrefix:refund_"`
+	Refunded    Money              `json:"refunded" gorm:"embedded;embeddedPrefix:refunded_"`
+	Transitions []ReturnTransition `json:"transitions,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time          `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ReturnItem is a quantity of an order line that goes back, Restock tells whether it was put back into stock
+type ReturnItem struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	ReturnID    uint   `json:"returnId" gorm:"index;not null"`
+	OrderItemID uint   `json:"orderItemId" gorm:"index;not null"`
+	ProductID   uint   `json:"productId" gorm:"not null"`
+	VariantID   *uint  `json:"variantId"`
+	Name        string `json:"name"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity" gorm:"not null"`
+	Reason      string `json:"reason" gorm:"not null"`
+	Refund      Money  `json:"refund" gorm:"embedded;embeddedPrefix:refund_"`
+	Restock     bool   `json:"restock"`
+}
+
+// ReturnTransition is one step in the status history of a return, the first one has no From status
+type ReturnTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReturnID  uint      `json:"returnId" gorm:"index;not null"`
+	From      string    `json:"from"`
+	To        string    `json:"to" gorm:"not null"`
+	Note      string    `json:"note"`
+	UserID    *int      `json:"userId"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ReturnEvent is the payload of the return.<status> events, Return is the return after the transition
+type ReturnEvent struct {
+	Return Return
+	From   string
+	To     string
+}
+
+// Review is a customer's rating of a product, it is shown once approved. Verified reviews come from
+// customers who bought the product.
+type Review struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ProductID      uint       `json:"productId" gorm:"uniqueIndex:idx_reviews_product_user;not null"`
+	UserID         int        `json:"userId" gorm:"uniqueIndex:idx_reviews_product_user;index;not null"`
+	Author         string     `json:"author"`
+	Rating         int        `json:"rating" gorm:"index;not null"`
+	Title          string     `json:"title"`
+	Body           string     `json:"body"`
+	Verified       bool       `json:"verified"`
+	Status         string     `json:"status" gorm:"index;not null"`
+	ModerationNote string     `json:"moderationNote,omitempty"`
+	ModeratedBy    *int       `json:"moderatedBy,omitempty"`
+	ModeratedAt    *time.Time `json:"moderatedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// ReviewEvent is the payload of the review.<status> events
+type ReviewEvent struct {
+	Review Review
+	From   string
+}
+
+type ReviewFilter struct {
+	ProductID uint
+	UserID    *int
+	Status    string
+	Rating    int
+	Verified  bool
+	Sort      string
+}
+
+type BookingFilter struct {
+	ProfileID uint
+	UserID    *int
+	Status    string
+	From      *time.Time
+	To        *time.Time
+	// Latest lists the latest bookings first instead of the next ones
+	Latest bool
+}
+
+type ProfessionalFilter struct {
+	Search  string
+	Skill   string
+	Country string
+	City    string
+	Remote  bool
+	Status  string
+	Sort    string
+}
+
+type ScheduledPriceFilter struct {
+	ProductID uint
+	Status    string
+}
+
+type ReturnFilter struct {
+	UserID  *int
+	OrderID uint
+	Status  string
+}
+
+type OrderFilter struct {
+	UserID *int
+	Status string
+}
+
+// OrderDetails is what checkout adds to the cart when it becomes an order
+type OrderDetails struct {
+	Note            string
+	ShippingAddress *OrderAddress
+	BillingAddress  *OrderAddress
+}
+
+type Category struct {
+	ID         uint                `json:"id" gorm:"primaryKey"`
+	ParentID   *uint               `json:"parentId" gorm:"index"`
+	Name       string              `json:"name" gorm:"not null"`
+	Slug       string              `json:"slug" gorm:"uniqueIndex;not null"`
+	Position   int                 `json:"position" gorm:"default:0"`
+	Attributes []CategoryAttribute `json:"attributes,omitempty"`
+	Children   []Category          `json:"children,omitempty" gorm:"-"`
+	CreatedAt  time.Time           `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time           `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+type CategoryAttribute struct {
+	ID         uint     `json:"id" gorm:"primaryKey"`
+	CategoryID uint     `json:"categoryId" gorm:"index;not null"`
+	Name       string   `json:"name" gorm:"not null"`
+	Type       string   `json:"type" gorm:"not null;default:text"`
+	Options    []string `json:"options,omitempty" gorm:"serializer:json"`
+	Required   bool     `json:"required"`
+	Position   int      `json:"position" gorm:"default:0"`
+}
+
 type UserStore interface {
 	GetUserByEmail(email string) (*User, error)
 	GetUserByID(id int) (*User, error)
@@ -38,10 +1058,362 @@ type UserStore interface {
 	UpdateUserRememberToken(*User, string) error
 	GetUserByRememberToken(rememberToken string) (*User, error)
 	UpdatePasswordOfUser(*User, string) error
+	GetUsers(cursor string, limit int) ([]User, *CursorPage, error)
 }
 
 type ProductStore interface {
-	GetProducts(page, size int, search, category string) ([]Product, int64, error)
+	GetProducts(page, size int, filter ProductFilter) ([]Product, int64, error)
+	GetProductsByCursor(filter ProductFilter, cursor string, limit int) ([]Product, *CursorPage, error)
+	GetProductFacets(filter ProductFilter) (*ProductFacets, error)
+	GetProductByID(id uint) (*Product, error)
+	GetProductBySKU(sku string) (*Product, error)
+	CreateProduct(*Product) error
+	UpdateProduct(*Product) error
+	ReplaceProductOptions(productID uint, options []ProductOption) error
+	GenerateVariants(productID uint) ([]ProductVariant, error)
+	GetVariantByID(id uint) (*ProductVariant, error)
+	UpdateVariant(*ProductVariant) error
+	DeleteVariant(id uint) error
+	IsSKUTaken(sku string, productID, variantID uint) (bool, error)
+	ReplaceProductPrices(productID uint, prices []ProductPrice) error
+}
+
+type InventoryStore interface {
+	Receive(warehouseID, productID uint, variantID *uint, quantity int, reference, note string, userID *int) (*StockMovement, error)
+	Adjust(warehouseID, productID uint, variantID *uint, quantity int, note string, userID *int) (*StockMovement, error)
+	Transfer(fromID, toID, productID uint, variantID *uint, quantity int, reference, note string, userID *int) ([]StockMovement, error)
+	Reserve(request ReservationRequest) (*StockReservation, error)
+	Release(reservationID uint) error
+	Ship(reservationID uint) error
+	ExpireReservations() (int, error)
+	GetStockMovements(productID uint, cursor string, limit int) ([]StockMovement, *CursorPage, error)
+}
+
+type ProductImageStore interface {
+	GetProductImages(productID uint) ([]ProductImage, error)
+	GetProductImage(productID, id uint) (*ProductImage, error)
+	CreateProductImages(productID uint, images []ProductImage) error
+	UpdateProductImage(*ProductImage) error
+	DeleteProductImage(productID, id uint) error
+	ReorderProductImages(productID uint, ids []uint) error
+}
+
+type ImportJobStore interface {
+	GetImportJobs(cursor string, limit int) ([]ImportJob, *CursorPage, error)
+	GetImportJobByID(id uint) (*ImportJob, error)
+	CreateImportJob(*ImportJob) error
+	UpdateImportJob(*ImportJob) error
+}
+
+type ExportJobStore interface {
+	GetExportJobs(cursor string, limit int) ([]ExportJob, *CursorPage, error)
+	GetExportJobByID(id uint) (*ExportJob, error)
+	GetUnfinishedExportJobs() ([]ExportJob, error)
+	CreateExportJob(*ExportJob) error
+	UpdateExportJob(*ExportJob) error
+}
+
+type CartStore interface {
+	GetCartByUserID(userID int) (*Cart, error)
+	GetCartByToken(token string) (*Cart, error)
+	CreateCart(*Cart) error
+	SaveCart(*Cart) error
+	DeleteCart(id uint) error
+}
+
+type PromotionStore interface {
+	GetPromotions() ([]Promotion, error)
+	GetPromotionByID(id uint) (*Promotion, error)
+	GetPromotionByCode(code string) (*Promotion, error)
+	GetActivePromotions(at time.Time, code string) ([]Promotion, error)
+	CountRedemptions(promotionID uint, userID int) (int, error)
+	CreatePromotion(*Promotion) error
+	UpdatePromotion(*Promotion) error
+	DeletePromotion(id uint) error
+}
+
+// PromotionEngine works out the discounts of a cart, the cart totals call it after every recalculation
+type PromotionEngine interface {
+	Apply(cart *Cart) error
+	Check(cart *Cart, code string) error
+}
+
+type OrderStore interface {
+	GetOrders(filter OrderFilter, cursor string, limit int) ([]Order, *CursorPage, error)
+	GetOrderByID(id uint) (*Order, error)
+	CreateOrder(cart *Cart, details OrderDetails) (*Order, error)
+	TransitionOrder(id uint, status, note string, userID *int) (*Order, error)
+	CancelPendingOrders(before time.Time) (int, error)
+}
+
+// PaymentProvider takes payments for orders, Status values of intents are the payment statuses of the payment service
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(order Order, idempotencyKey string) (*PaymentIntent, error)
+	GetIntent(intentID string) (*PaymentIntent, error)
+	Capture(intentID string) (*PaymentIntent, error)
+	Cancel(intentID string) (*PaymentIntent, error)
+	// Refund gives money back, a repeated idempotencyKey returns the first refund instead of refunding again
+	Refund(intentID string, amount Money, idempotencyKey string) (*PaymentRefund, error)
+	VerifyWebhook(payload []byte, header http.Header) (*PaymentEvent, error)
+}
+
+type PaymentStore interface {
+	GetPaymentsByOrderID(orderID uint) ([]Payment, error)
+	GetPaymentByID(id uint) (*Payment, error)
+	GetPaymentByProviderID(provider, providerID string) (*Payment, error)
+	CreatePayment(*Payment) error
+	UpdatePayment(*Payment) error
+	GetRefundsByKey(key string) ([]Refund, error)
+	// RecordRefund saves the refunded payment together with the refund
+	RecordRefund(payment *Payment, refund *Refund) error
+	RecordWebhookEvent(*WebhookEvent) (bool, error)
+	DeleteWebhookEvent(id uint) error
+}
+
+type InvoiceStore interface {
+	GetInvoices(filter InvoiceFilter) ([]Invoice, error)
+	GetInvoiceByID(id uint) (*Invoice, error)
+	GetInvoicesByOrderID(orderID uint) ([]Invoice, error)
+	// CreateInvoice numbers the invoice and stores it once file rendered and saved its document, a file
+	// that fails uses up no number
+	CreateInvoice(invoice *Invoice, file func(*Invoice) error) error
+	MoveInvoice(id uint, key string) error
+}
+
+type PriceStore interface {
+	GetPriceHistory(productID uint, cursor string, limit int) ([]PriceChange, *CursorPage, error)
+	GetScheduledPrices(filter ScheduledPriceFilter) ([]ScheduledPrice, error)
+	GetScheduledPriceByID(id uint) (*ScheduledPrice, error)
+	// CreateScheduledPrice refuses entries whose window overlaps another pending or running one of the product
+	CreateScheduledPrice(*ScheduledPrice) error
+	// CancelScheduledPrice drops a pending entry and ends a running one early, putting the previous price back
+	CancelScheduledPrice(*ScheduledPrice) error
+	// ApplyScheduledPrices ends the entries that are over and starts the ones that are due
+	ApplyScheduledPrices(now time.Time) (started int, ended int, err error)
+}
+
+type WishlistStore interface {
+	GetWishlist(userID int) ([]WishlistItem, error)
+	// AddToWishlist saves the product, saving it twice keeps the first item
+	AddToWishlist(*WishlistItem) error
+	RemoveFromWishlist(userID int, productID uint) error
+	GetStockSubscriptions(userID int) ([]StockSubscription, error)
+	// Subscribe asks for the next restock of the product, a subscription that was notified before waits again
+	Subscribe(*StockSubscription) error
+	Unsubscribe(userID int, productID uint) error
+	// GetWaitingSubscriptions lists the subscriptions of the product that weren't notified yet
+	GetWaitingSubscriptions(productID uint) ([]StockSubscription, error)
+	// MarkNotified claims the subscription for its mail, false means it was notified already
+	MarkNotified(id uint) (bool, error)
+}
+
+type BookingStore interface {
+	// GetCalendar loads the calendar of the professional with its rules and exceptions, creating it with
+	// the default settings the first time
+	GetCalendar(profileID uint) (*BookingCalendar, error)
+	UpdateCalendar(*BookingCalendar) error
+	ReplaceRules(calendarID uint, rules []AvailabilityRule) error
+	CreateException(*AvailabilityException) error
+	GetExceptionByID(id uint) (*AvailabilityException, error)
+	DeleteException(id uint) error
+	GetBookings(filter BookingFilter, cursor string, limit int) ([]Booking, *CursorPage, error)
+	// GetCalendarBookings lists the bookings of the professional in every status that overlap from and to
+	GetCalendarBookings(profileID uint, from, to time.Time) ([]Booking, error)
+	GetBookingByID(id uint) (*Booking, error)
+	// CreateBooking confirms the booking if its start is a free slot of the calendar
+	CreateBooking(*Booking) error
+	// RescheduleBooking moves the booking to another free slot
+	RescheduleBooking(booking *Booking, startsAt time.Time) error
+	CancelBooking(booking *Booking, reason string, userID int) error
+}
+
+type ProfessionalStore interface {
+	GetProfiles(filter ProfessionalFilter, cursor string, limit int) ([]ProfessionalProfile, *CursorPage, error)
+	GetProfileByID(id uint) (*ProfessionalProfile, error)
+	GetProfileByUserID(userID int) (*ProfessionalProfile, error)
+	CreateProfile(*ProfessionalProfile) error
+	// UpdateProfile stores the edited profile, it goes back to review
+	UpdateProfile(*ProfessionalProfile) error
+	// ReviewProfile approves, rejects or suspends the profile
+	ReviewProfile(profile *ProfessionalProfile, status, note string, userID int) error
+	GetServiceByID(id uint) (*ProfessionalService, error)
+	CreateService(*ProfessionalService) error
+	UpdateService(*ProfessionalService) error
+	DeleteService(id uint) error
+}
+
+type ReviewStore interface {
+	GetReviews(filter ReviewFilter, cursor string, limit int) ([]Review, *CursorPage, error)
+	GetReviewByID(id uint) (*Review, error)
+	// HasPurchased reports whether the user bought the product in an order that was paid and not given back
+	HasPurchased(userID int, productID uint) (bool, error)
+	CreateReview(*Review) error
+	// UpdateReview stores the edited text and rating, the review goes back to moderation
+	UpdateReview(*Review) error
+	DeleteReview(*Review) error
+	// ModerateReview approves or rejects the review and moves the rating of its product along
+	ModerateReview(review *Review, status, note string, userID int) error
+}
+
+type ReturnStore interface {
+	GetReturns(filter ReturnFilter) ([]Return, error)
+	GetReturnByID(id uint) (*Return, error)
+	// CreateReturn stores a requested return, a quantity of an order line is only returned once
+	CreateReturn(*Return) error
+	// TransitionReturn moves the return on from its current status and records the step. Received returns
+	// put their items marked Restock back into stock, refunded returns keep their Refunded amount.
+	TransitionReturn(r *Return, status, note string, userID *int) error
+}
+
+type WarehouseStore interface {
+	GetWarehouses() ([]Warehouse, error)
+	GetWarehouseByID(id uint) (*Warehouse, error)
+	GetWarehouseByCode(code string) (*Warehouse, error)
+	CreateWarehouse(*Warehouse) error
+	UpdateWarehouse(*Warehouse) error
+	GetStockLevels(warehouseID uint) ([]StockLevel, error)
+}
+
+type CurrencyStore interface {
+	GetExchangeRates() ([]ExchangeRate, error)
+	SaveExchangeRates([]ExchangeRate) error
+	Convert(m Money, currency string) (Money, error)
+	LocalizeProducts(products []Product, currency string) error
+}
+
+type TaxRateStore interface {
+	GetTaxRates() ([]TaxRate, error)
+	GetTaxRatesFor(country, region string) ([]TaxRate, error)
+	ReplaceTaxRates([]TaxRate) error
+}
+
+// TaxProvider calculates taxes, the built in one uses the tax rate table and an external tax service can take its place
+type TaxProvider interface {
+	Name() string
+	Calculate(request TaxRequest) (*TaxResult, error)
+}
+
+type AddressStore interface {
+	GetAddressesByUserID(userID int) ([]Address, error)
+	GetAddressByID(id uint) (*Address, error)
+	GetDefaultAddresses(userID int) (shipping, billing *Address, err error)
+	CreateAddress(*Address) error
+	UpdateAddress(*Address) error
+	DeleteAddress(id uint) error
+}
+
+type ShippingStore interface {
+	GetZones() ([]ShippingZone, error)
+	GetZoneByID(id uint) (*ShippingZone, error)
+	CreateZone(*ShippingZone) error
+	UpdateZone(*ShippingZone) error
+	DeleteZone(id uint) error
+	GetMethodByID(id uint) (*ShippingMethod, error)
+	CreateMethod(*ShippingMethod) error
+	UpdateMethod(*ShippingMethod) error
+	DeleteMethod(id uint) error
+	GetShipmentByID(id uint) (*Shipment, error)
+	GetShipmentsByOrderID(orderID uint) ([]Shipment, error)
+	GetShipmentsByStatus(statuses ...string) ([]Shipment, error)
+	CreateShipment(*Shipment) error
+	UpdateShipment(shipment *Shipment, update ShipmentUpdate) error
+}
+
+// ShippingEngine prices the shipping of carts, the cart totals call it after the taxes
+type ShippingEngine interface {
+	Quote(cart *Cart, country string) ([]ShippingQuote, error)
+	Check(cart *Cart, methodID uint) error
+	Apply(cart *Cart) error
+	Ready(cart *Cart) error
+}
+
+// Carrier hands shipments over to a delivery company. Track returns nil while nothing changed.
+type Carrier interface {
+	Name() string
+	CreateShipment(order Order, shipment Shipment) (*CarrierLabel, error)
+	Track(shipment Shipment) (*ShipmentUpdate, error)
+}
+
+// TaxEngine adds the taxes to a cart, the cart totals call it after the promotions
+type TaxEngine interface {
+	Apply(cart *Cart) error
+}
+
+type CategoryStore interface {
+	GetCategories() ([]Category, error)
+	GetCategoryByID(id uint) (*Category, error)
+	GetCategoryBySlug(slug string) (*Category, error)
+	CreateCategory(*Category) error
+	UpdateCategory(*Category) error
+	DeleteCategory(id uint) error
+}
+
+type CursorPage struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type ProductFilter struct {
+	Search     string   `json:"search,omitempty"`
+	SKU        string   `json:"sku,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	MinPrice   *int64   `json:"minPrice,omitempty"`
+	MaxPrice   *int64   `json:"maxPrice,omitempty"`
+	InStock    bool     `json:"inStock,omitempty"`
+	Sort       string   `json:"sort,omitempty"`
+	Order      string   `json:"order,omitempty"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+type PriceBucket struct {
+	Min   Money  `json:"min"`
+	Max   *Money `json:"max"`
+	Count int64  `json:"count"`
+}
+
+type ProductFacets struct {
+	Categories   []FacetCount  `json:"categories"`
+	PriceBuckets []PriceBucket `json:"priceBuckets"`
+}
+
+type ProductListQuery struct {
+	Page       int      `json:"page" validate:"min=1"`
+	Size       int      `json:"size" validate:"min=1,max=100"`
+	Search     string   `json:"search" validate:"max=100"`
+	SKU        string   `json:"sku" validate:"max=64"`
+	Categories []string `json:"category" validate:"max=20,dive,required,max=100"`
+	MinPrice   *int64   `json:"minPrice" validate:"omitempty,min=0"`
+	MaxPrice   *int64   `json:"maxPrice" validate:"omitempty,min=0"`
+	Currency   string   `json:"currency" validate:"omitempty,iso4217"`
+	InStock    bool     `json:"inStock"`
+	Sort       string   `json:"sort" validate:"omitempty,oneof=price name newest stock rating"`
+	Order      string   `json:"order" validate:"omitempty,oneof=asc desc"`
+	Cursor     *string  `json:"cursor"`
+}
+
+type ProductListResponse struct {
+	Data   []Product      `json:"data"`
+	Total  int64          `json:"total"`
+	Page   int            `json:"page"`
+	Size   int            `json:"size"`
+	Facets *ProductFacets `json:"facets"`
+}
+
+type ProductCursorResponse struct {
+	Data   []Product      `json:"data"`
+	Cursor *CursorPage    `json:"cursor"`
+	Facets *ProductFacets `json:"facets"`
+}
+
+type UserListResponse struct {
+	Data   []User      `json:"data"`
+	Cursor *CursorPage `json:"cursor"`
 }
 
 type RegisterUserPayload struct {
@@ -56,6 +1428,309 @@ type LoginUserPayload struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type ProductPayload struct {
+	Name              string                 `json:"name" validate:"required,max=200"`
+	Description       string                 `json:"description" validate:"max=5000"`
+	Price             MoneyPayload           `json:"price"`
+	Prices            []MoneyPayload         `json:"prices" validate:"max=50,unique=Currency,dive"`
+	LowStockThreshold int                    `json:"lowStockThreshold" validate:"min=0"`
+	CategoryID        *uint                  `json:"categoryId"`
+	TaxClass          string                 `json:"taxClass" validate:"max=32"`
+	Weight            int                    `json:"weight" validate:"min=0"`
+	Length            int                    `json:"length" validate:"min=0"`
+	Width             int                    `json:"width" validate:"min=0"`
+	Height            int                    `json:"height" validate:"min=0"`
+	ImageURL          string                 `json:"imageUrl" validate:"omitempty,url"`
+	SKU               string                 `json:"sku" validate:"max=64"`
+	Options           []ProductOptionPayload `json:"options" validate:"max=3,unique=Name,dive"`
+}
+
+type StockMovementPayload struct {
+	Type        string `json:"type" validate:"required,oneof=receipt adjustment"`
+	WarehouseID uint   `json:"warehouseId" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,ne=0"`
+	VariantID   *uint  `json:"variantId"`
+	Reference   string `json:"reference" validate:"max=100"`
+	Note        string `json:"note" validate:"max=500"`
+}
+
+type ProductImageOrderPayload struct {
+	ImageIDs []uint `json:"imageIds" validate:"required,min=1,unique"`
+}
+
+type ProductImagePayload struct {
+	Alt string `json:"alt" validate:"max=250"`
+}
+
+type StockTransferPayload struct {
+	FromWarehouseID uint   `json:"fromWarehouseId" validate:"required"`
+	ToWarehouseID   uint   `json:"toWarehouseId" validate:"required,nefield=FromWarehouseID"`
+	ProductID       uint   `json:"productId" validate:"required"`
+	VariantID       *uint  `json:"variantId"`
+	Quantity        int    `json:"quantity" validate:"required,min=1"`
+	Reference       string `json:"reference" validate:"max=100"`
+	Note            string `json:"note" validate:"max=500"`
+}
+
+type CartItemPayload struct {
+	ProductID uint  `json:"productId" validate:"required"`
+	VariantID *uint `json:"variantId"`
+	Quantity  int   `json:"quantity" validate:"required,min=1,max=999"`
+}
+
+type CartItemQuantityPayload struct {
+	Quantity int `json:"quantity" validate:"required,min=1,max=999"`
+}
+
+type PromotionPayload struct {
+	Name             string     `json:"name" validate:"required,max=100"`
+	Code             string     `json:"code" validate:"omitempty,max=32,printascii,excludesall= "`
+	Type             string     `json:"type" validate:"required,oneof=percentage fixed free_shipping buy_x_get_y"`
+	Percent          int        `json:"percent" validate:"required_if=Type percentage,min=0,max=100"`
+	Amount           int64      `json:"amount" validate:"required_if=Type fixed,min=0"`
+	BuyQuantity      int        `json:"buyQuantity" validate:"required_if=Type buy_x_get_y,min=0"`
+	GetQuantity      int        `json:"getQuantity" validate:"required_if=Type buy_x_get_y,min=0"`
+	ProductIDs       []uint     `json:"productIds" validate:"max=500,unique"`
+	CategoryIDs      []uint     `json:"categoryIds" validate:"max=100,unique"`
+	MinSubtotal      int64      `json:"minSubtotal" validate:"min=0"`
+	StartsAt         *time.Time `json:"startsAt"`
+	EndsAt           *time.Time `json:"endsAt"`
+	UsageLimit       *int       `json:"usageLimit" validate:"omitempty,min=1"`
+	PerCustomerLimit *int       `json:"perCustomerLimit" validate:"omitempty,min=1"`
+	Priority         int        `json:"priority"`
+	Active           *bool      `json:"active"`
+}
+
+type CartDestinationPayload struct {
+	Country string `json:"country" validate:"required,len=2,alpha"`
+	Region  string `json:"region" validate:"max=64"`
+}
+
+type CartShippingPayload struct {
+	MethodID uint `json:"methodId" validate:"required"`
+}
+
+type ShippingZonePayload struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Countries []string `json:"countries" validate:"max=250,unique,dive,len=2,alpha"`
+}
+
+type ShippingMethodPayload struct {
+	Name              string         `json:"name" validate:"required,max=100"`
+	Carrier           string         `json:"carrier" validate:"required,max=32"`
+	RateType          string         `json:"rateType" validate:"required,oneof=flat weight price"`
+	Price             int64          `json:"price" validate:"min=0"`
+	Rates             []ShippingRate `json:"rates" validate:"required_unless=RateType flat,max=100,dive"`
+	VolumetricDivisor int            `json:"volumetricDivisor" validate:"min=0"`
+	Position          int            `json:"position"`
+	Active            *bool          `json:"active"`
+}
+
+type ShipPayload struct {
+	TrackingNumber string `json:"trackingNumber" validate:"max=100"`
+}
+
+type ShipmentUpdatePayload struct {
+	Status  string `json:"status" validate:"required,oneof=in_transit delivered exception returned"`
+	Message string `json:"message" validate:"max=500"`
+}
+
+type CouponPayload struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type CheckoutPayload struct {
+	Note              string `json:"note" validate:"max=500"`
+	ShippingAddressID *uint  `json:"shippingAddressId"`
+	BillingAddressID  *uint  `json:"billingAddressId"`
+}
+
+type AddressPayload struct {
+	Name            string `json:"name" validate:"required,max=100"`
+	Company         string `json:"company" validate:"max=100"`
+	Line1           string `json:"line1" validate:"required,max=200"`
+	Line2           string `json:"line2" validate:"max=200"`
+	City            string `json:"city" validate:"required,max=100"`
+	Region          string `json:"region" validate:"max=64"`
+	PostalCode      string `json:"postalCode" validate:"max=16"`
+	Country         string `json:"country" validate:"required,len=2,alpha"`
+	Phone           string `json:"phone" validate:"omitempty,e164"`
+	DefaultShipping bool   `json:"defaultShipping"`
+	DefaultBilling  bool   `json:"defaultBilling"`
+}
+
+type BookingCalendarPayload struct {
+	TimeZone         string `json:"timeZone" validate:"required,timezone"`
+	SlotInterval     int    `json:"slotInterval" validate:"min=5,max=1440"`
+	Buffer           int    `json:"buffer" validate:"min=0,max=240"`
+	MinNotice        int    `json:"minNotice" validate:"min=0,max=43200"`
+	Horizon          int    `json:"horizon" validate:"min=1,max=365"`
+	CancelNotice     int    `json:"cancelNotice" validate:"min=0,max=43200"`
+	RescheduleNotice int    `json:"rescheduleNotice" validate:"min=0,max=43200"`
+	MaxReschedules   int    `json:"maxReschedules" validate:"min=0,max=10"`
+}
+
+type AvailabilityRulesPayload struct {
+	Rules []AvailabilityRulePayload `json:"rules" validate:"max=50,dive"`
+}
+
+type AvailabilityRulePayload struct {
+	Weekday   int    `json:"weekday" validate:"min=0,max=6"`
+	StartTime string `json:"startTime" validate:"required,len=5"`
+	EndTime   string `json:"endTime" validate:"required,len=5"`
+}
+
+type AvailabilityExceptionPayload struct {
+	StartDate string `json:"startDate" validate:"required,datetime=2006-01-02"`
+	EndDate   string `json:"endDate" validate:"omitempty,datetime=2006-01-02"`
+	Available bool   `json:"available"`
+	StartTime string `json:"startTime" validate:"required_with=EndTime,omitempty,len=5"`
+	EndTime   string `json:"endTime" validate:"required_with=StartTime,omitempty,len=5"`
+	Note      string `json:"note" validate:"max=500"`
+}
+
+type BookingPayload struct {
+	ServiceID uint      `json:"serviceId" validate:"required"`
+	StartsAt  time.Time `json:"startsAt" validate:"required"`
+	Note      string    `json:"note" validate:"max=1000"`
+}
+
+type BookingReschedulePayload struct {
+	StartsAt time.Time `json:"startsAt" validate:"required"`
+}
+
+type BookingCancelPayload struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type ProfessionalProfilePayload struct {
+	DisplayName string             `json:"displayName" validate:"required,max=100"`
+	Headline    string             `json:"headline" validate:"max=150"`
+	Bio         string             `json:"bio" validate:"max=5000"`
+	Skills      []string           `json:"skills" validate:"max=30,dive,required,max=50"`
+	ServiceArea ServiceAreaPayload `json:"serviceArea"`
+}
+
+type ServiceAreaPayload struct {
+	Country string `json:"country" validate:"required,len=2,alpha"`
+	City    string `json:"city" validate:"max=100"`
+	Radius  int    `json:"radius" validate:"min=0,max=1000"`
+	Remote  bool   `json:"remote"`
+}
+
+type ProfessionalServicePayload struct {
+	Name        string       `json:"name" validate:"required,max=150"`
+	Description string       `json:"description" validate:"max=2000"`
+	Duration    int          `json:"duration" validate:"required,min=5,max=1440"`
+	Price       MoneyPayload `json:"price"`
+	Active      *bool        `json:"active"`
+}
+
+type ProfessionalReviewPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type ScheduledPricePayload struct {
+	Price    MoneyPayload `json:"price"`
+	StartsAt time.Time    `json:"startsAt" validate:"required"`
+	EndsAt   *time.Time   `json:"endsAt" validate:"omitempty,gtfield=StartsAt"`
+	Note     string       `json:"note" validate:"max=500"`
+}
+
+type WishlistPayload struct {
+	ProductID uint `json:"productId" validate:"required"`
+}
+
+type ReviewPayload struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title" validate:"max=150"`
+	Body   string `json:"body" validate:"max=5000"`
+}
+
+type ReviewModerationPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type ReturnPayload struct {
+	Items []ReturnItemPayload `json:"items" validate:"required,min=1,max=100,dive"`
+	Note  string              `json:"note" validate:"max=500"`
+}
+
+type ReturnItemPayload struct {
+	OrderItemID uint   `json:"orderItemId" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,min=1"`
+	Reason      string `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+}
+
+type ReturnDecisionPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// ReturnReceivePayload lists the items that came back unsellable, every other item is restocked
+type ReturnReceivePayload struct {
+	DiscardItemIDs []uint `json:"discardItemIds" validate:"max=100,unique"`
+	Note           string `json:"note" validate:"max=500"`
+}
+
+// ReturnRefundPayload refunds the value of the returned lines unless Amount says otherwise, e.g. to
+// add the shipping or keep a restocking fee
+type ReturnRefundPayload struct {
+	Amount *int64 `json:"amount" validate:"omitempty,min=1"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+type OrderTransitionPayload struct {
+	Status string `json:"status" validate:"required,oneof=pending paid fulfilled delivered cancelled refunded"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+type CancelOrderPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type WarehousePayload struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Code      string   `json:"code" validate:"required,max=32,alphanum"`
+	Address   string   `json:"address" validate:"max=500"`
+	Country   string   `json:"country" validate:"omitempty,len=2,alpha"`
+	Region    string   `json:"region" validate:"max=64"`
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,latitude"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,longitude"`
+	Priority  int      `json:"priority" validate:"min=0"`
+	Active    *bool    `json:"active"`
+}
+
+type MoneyPayload struct {
+	Amount   int64  `json:"amount" validate:"min=0"`
+	Currency string `json:"currency" validate:"required,iso4217"`
+}
+
+type ProductOptionPayload struct {
+	Name   string   `json:"name" validate:"required,max=50"`
+	Values []string `json:"values" validate:"required,min=1,max=50,unique,dive,required,max=50"`
+}
+
+type ProductVariantPayload struct {
+	SKU           string `json:"sku" validate:"required,max=64"`
+	Barcode       string `json:"barcode" validate:"max=64"`
+	PriceOverride *int64 `json:"priceOverride" validate:"omitempty,min=0"`
+}
+
+type CategoryPayload struct {
+	Name       string                     `json:"name" validate:"required,max=100"`
+	Slug       string                     `json:"slug" validate:"omitempty,max=100"`
+	ParentID   *uint                      `json:"parentId"`
+	Position   int                        `json:"position"`
+	Attributes []CategoryAttributePayload `json:"attributes" validate:"max=50,dive"`
+}
+
+type CategoryAttributePayload struct {
+	Name     string   `json:"name" validate:"required,max=100"`
+	Type     string   `json:"type" validate:"required,oneof=text number boolean select"`
+	Options  []string `json:"options" validate:"required_if=Type select,dive,required,max=100"`
+	Required bool     `json:"required"`
+}
+
 type ResetPasswordPayload struct {
 	Email string `json:"email" validate:"required,email"`
 }